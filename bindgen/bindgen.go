@@ -0,0 +1,233 @@
+package bindgen
+
+import (
+	"errors"
+	"fmt"
+	dwarf "sym-exposer/dwarf"
+	elf "sym-exposer/elf"
+)
+
+// MAX_TYPE_DEPTH bounds the walks of type chains, which only loop in broken DWARF
+const MAX_TYPE_DEPTH = 64
+
+// Function is an exposed function with the DW_TAG_subprogram describing it
+type Function struct {
+	Symbol string
+	Entry  *dwarf.Entry
+	File   string // DW_AT_decl_file as named in the line table
+	Line   uint64
+	Rust   bool // in a Rust unit, with the types and the calling convention of Rust
+}
+
+// Program holds the exposed functions of an object and the DWARF their types are read from
+type Program struct {
+	Path    string
+	Debug   *dwarf.DebugInfo
+	Funcs   []Function
+	Missing []string // exposed functions without debug information
+}
+
+// Param is a parameter of a function or a function type, Type is nil for void
+type Param struct {
+	Name       string
+	Type       *dwarf.Entry
+	Artificial bool // the this parameter of a C++ member function
+}
+
+// Signature is the prototype of a function or a function type, Return is nil for void
+type Signature struct {
+	Return     *dwarf.Entry
+	Params     []Param
+	Variadic   bool
+	Prototyped bool // false for a C function declared without parameter types
+}
+
+// Load finds the DWARF functions of symbols in elfObj, symbols named twice are taken once
+func Load(elfObj elf.ElfObject, symbols []string) (*Program, error) {
+	path := elfObj.GetPath()
+	if !elfObj.HasSection(".debug_info") {
+		msg := fmt.Sprintf("%s: not found .debug_info section", path)
+		return nil, errors.New(msg)
+	}
+	cuInfos, err := dwarf.ReadElfDebugInfo(elfObj)
+	if err != nil {
+		return nil, err
+	}
+	debug, err := dwarf.ReadElfDebugInfoTree(elfObj)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range symbols {
+		wanted[name] = true
+	}
+	funcs := map[string]Function{}
+	for _, f := range elfObj.GetFuncsInfos() {
+		if _, exist := funcs[f.Name]; exist || !wanted[f.Name] {
+			continue
+		}
+		offset, dbgFunc, found := dwarf.FindFunc(cuInfos, f)
+		entry := debug.EntryAt(offset)
+		if !found || entry == nil {
+			continue
+		}
+		function := Function{Symbol: f.Name, Entry: entry, File: dbgFunc.DeclFile, Line: dbgFunc.DeclLine}
+		for i := range cuInfos {
+			if _, exist := cuInfos[i].FuncsByOffset[offset]; exist {
+				function.Rust = cuInfos[i].IsRust()
+			}
+		}
+		funcs[f.Name] = function
+	}
+
+	p := &Program{Path: path, Debug: debug, Funcs: []Function{}, Missing: []string{}}
+	taken := map[string]bool{}
+	for _, name := range symbols {
+		if taken[name] {
+			continue
+		}
+		taken[name] = true
+		if f, exist := funcs[name]; exist {
+			p.Funcs = append(p.Funcs, f)
+		} else {
+			p.Missing = append(p.Missing, name)
+		}
+	}
+	return p, nil
+}
+
+// origins returns e followed by the entries it completes: the abstract instance
+// of an out-of-line copy of an inlined function and the declaration of a definition
+func (p *Program) origins(e *dwarf.Entry) []*dwarf.Entry {
+	chain := []*dwarf.Entry{e}
+	seen := map[*dwarf.Entry]bool{e: true}
+	for {
+		last := chain[len(chain)-1]
+		next := p.Debug.Ref(last, dwarf.DW_AT_abstract_origin)
+		if next == nil {
+			next = p.Debug.Ref(last, dwarf.DW_AT_specification)
+		}
+		if next == nil || seen[next] {
+			return chain
+		}
+		seen[next] = true
+		chain = append(chain, next)
+	}
+}
+
+// Signature returns the prototype of a DW_TAG_subprogram or DW_TAG_subroutine_type entry.
+// An out-of-line copy of an inlined function has the types in its abstract instance,
+// the parameters are taken from the entry of the chain listing the most of them.
+func (p *Program) Signature(e *dwarf.Entry) Signature {
+	sig := Signature{Params: []Param{}}
+	chain := p.origins(e)
+	for _, origin := range chain {
+		if _, exist := origin.Attr(dwarf.DW_AT_type); exist && sig.Return == nil {
+			sig.Return = p.Debug.Ref(origin, dwarf.DW_AT_type)
+		}
+		sig.Prototyped = sig.Prototyped || origin.Flag(dwarf.DW_AT_prototyped)
+	}
+
+	var paramsOf *dwarf.Entry
+	numParams := -1
+	for _, origin := range chain {
+		n := 0
+		for _, child := range origin.Children {
+			switch child.Tag {
+			case dwarf.DW_TAG_formal_parameter:
+				n++
+			case dwarf.DW_TAG_unspecified_parameters:
+				sig.Variadic = true
+			}
+		}
+		if numParams < n {
+			paramsOf, numParams = origin, n
+		}
+	}
+	for _, child := range paramsOf.Children {
+		if child.Tag != dwarf.DW_TAG_formal_parameter {
+			continue
+		}
+		param := Param{}
+		for _, origin := range p.origins(child) {
+			if param.Name == "" {
+				param.Name = origin.Name()
+			}
+			if param.Type == nil {
+				param.Type = p.Debug.Ref(origin, dwarf.DW_AT_type)
+			}
+			param.Artificial = param.Artificial || origin.Flag(dwarf.DW_AT_artificial)
+		}
+		sig.Params = append(sig.Params, param)
+	}
+	return sig
+}
+
+// typeOf returns the type entry of e, nil for void
+func (p *Program) typeOf(e *dwarf.Entry) *dwarf.Entry {
+	return p.Debug.Ref(e, dwarf.DW_AT_type)
+}
+
+// arrayDims returns the element counts of the subranges of an array type,
+// -1 for a dimension without a constant bound such as a flexible array member
+func arrayDims(t *dwarf.Entry) []int64 {
+	dims := []int64{}
+	for _, child := range t.Children {
+		if child.Tag != dwarf.DW_TAG_subrange_type {
+			continue
+		}
+		var lower uint64
+		if a, exist := child.Attr(dwarf.DW_AT_lower_bound); exist && a.IsConstant() {
+			lower = a.Val
+		}
+		count := int64(-1)
+		if a, exist := child.Attr(dwarf.DW_AT_count); exist && a.IsConstant() {
+			count = int64(a.Val)
+		} else if a, exist := child.Attr(dwarf.DW_AT_upper_bound); exist && a.IsConstant() {
+			// gcc gives a zero length array an upper bound of -1
+			count = int64(a.Val-lower) + 1
+		}
+		dims = append(dims, count)
+	}
+	return dims
+}
+
+// enumValue returns the value of an enumerator, signed reports whether it is negative
+func enumValue(enumerator *dwarf.Entry, signedType bool) (uint64, bool) {
+	a, exist := enumerator.Attr(dwarf.DW_AT_const_value)
+	if !exist {
+		return 0, false
+	}
+	// DW_FORM_sdata is sign extended when read, dataN forms have the type's signedness
+	bits := 0
+	switch a.Form {
+	case dwarf.DW_FORM_sdata, dwarf.DW_FORM_implicit_const:
+		return a.Val, int64(a.Val) < 0
+	case dwarf.DW_FORM_data1:
+		bits = 8
+	case dwarf.DW_FORM_data2:
+		bits = 16
+	case dwarf.DW_FORM_data4:
+		bits = 32
+	case dwarf.DW_FORM_data8:
+		bits = 64
+	}
+	if !signedType || bits == 0 {
+		return a.Val, false
+	}
+	val := uint64(int64(a.Val<<(64-bits)) >> (64 - bits))
+	return val, int64(val) < 0
+}
+
+// isSigned reports whether the base type under typedefs and qualifiers is signed
+func (p *Program) isSigned(t *dwarf.Entry) bool {
+	for i := 0; t != nil && i < MAX_TYPE_DEPTH; i++ {
+		if t.Tag == dwarf.DW_TAG_base_type {
+			enc, _ := t.Val(dwarf.DW_AT_encoding)
+			return enc == dwarf.DW_ATE_signed || enc == dwarf.DW_ATE_signed_char
+		}
+		t = p.typeOf(t)
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package bindgen
+
+import (
+	dwarf "sym-exposer/dwarf"
+	"testing"
+)
+
+// newLangFunction returns a function in a unit of the language lang
+func newLangFunction(lang uint64) Function {
+	root := &dwarf.Entry{Tag: dwarf.DW_TAG_compile_unit}
+	root.Attributes = []dwarf.Attribute{{Attr: dwarf.DW_AT_language, Form: dwarf.DW_FORM_data1, Val: lang}}
+	unit := &dwarf.Unit{Root: root}
+	root.Unit = unit
+	entry := &dwarf.Entry{Tag: dwarf.DW_TAG_subprogram, Parent: root, Unit: unit}
+	root.Children = []*dwarf.Entry{entry}
+	return Function{Symbol: "f", Entry: entry}
+}
+
+func TestIsCPlusPlus(t *testing.T) {
+	tests := []struct {
+		name string
+		lang uint64
+		want bool
+	}{
+		{"C89", dwarf.DW_LANG_C89, false},
+		{"C99", dwarf.DW_LANG_C99, false},
+		{"C11", dwarf.DW_LANG_C11, false},
+		{"C++", dwarf.DW_LANG_C_plus_plus, true},
+		{"C++03", dwarf.DW_LANG_C_plus_plus_03, true},
+		{"C++11", dwarf.DW_LANG_C_plus_plus_11, true},
+		{"C++14", dwarf.DW_LANG_C_plus_plus_14, true},
+		{"Rust", dwarf.DW_LANG_Rust, false},
+	}
+	for _, test := range tests {
+		p := &Program{Funcs: []Function{newLangFunction(test.lang)}}
+		if got := p.IsCPlusPlus(); got != test.want {
+			t.Errorf("%s (0x%x): IsCPlusPlus() = %v, want %v", test.name, test.lang, got, test.want)
+		}
+	}
+
+	// one C++ function makes the header C++
+	p := &Program{Funcs: []Function{newLangFunction(dwarf.DW_LANG_C11), newLangFunction(dwarf.DW_LANG_C_plus_plus_14)}}
+	if !p.IsCPlusPlus() {
+		t.Errorf("C11 and C++14 units: IsCPlusPlus() = false, want true")
+	}
+}
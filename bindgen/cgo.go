@@ -0,0 +1,355 @@
+package bindgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	dwarf "sym-exposer/dwarf"
+)
+
+// cgoTypeMap names the cgo types of the C base types of gcc, cgo has none for long double and __int128
+var cgoTypeMap = map[string]string{
+	"char":                   "C.char",
+	"signed char":            "C.schar",
+	"unsigned char":          "C.uchar",
+	"short int":              "C.short",
+	"short unsigned int":     "C.ushort",
+	"int":                    "C.int",
+	"unsigned int":           "C.uint",
+	"long int":               "C.long",
+	"long unsigned int":      "C.ulong",
+	"long long int":          "C.longlong",
+	"long long unsigned int": "C.ulonglong",
+	"float":                  "C.float",
+	"double":                 "C.double",
+	"_Bool":                  "C._Bool",
+	"complex float":          "C.complexfloat",
+	"complex double":         "C.complexdouble",
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true,
+	"var": true,
+	// the packages the wrappers refer to
+	"C": true, "unsafe": true,
+}
+
+// goType is how a wrapper passes a C type: the Go type of its parameter or
+// result and the cgo type it is converted to and from
+type goType struct {
+	name string
+	cgo  string
+	str  bool // a const char * passed as a Go string
+}
+
+func (t goType) toC(v string) string {
+	if t.name == t.cgo {
+		return v
+	}
+	return t.cgo + "(" + v + ")"
+}
+
+func (t goType) fromC(v string) string {
+	if t.str {
+		return "C.GoString(" + v + ")"
+	}
+	if t.name == t.cgo {
+		return v
+	}
+	return t.name + "(" + v + ")"
+}
+
+// WriteGo writes a Go file of package pkg declaring the functions of p in its
+// cgo preamble, with an exported wrapper converting between Go and C types for
+// each function cgo can call. ldflags, when set, is the #cgo LDFLAGS directive
+// linking the exposed object. The cgo types are local to the package, so the
+// functions taking or returning C structures or unions, also through pointers,
+// get no wrapper. C++ functions are left out of the preamble, cgo calls C only.
+func (p *Program) WriteGo(w io.Writer, pkg string, ldflags string) {
+	cProg := *p
+	cProg.Funcs = []Function{}
+	for _, f := range p.Funcs {
+		if !strings.HasPrefix(f.Symbol, "_Z") {
+			cProg.Funcs = append(cProg.Funcs, f)
+		}
+	}
+	var decls strings.Builder
+	cProg.writeCDecls(&decls)
+	wrappers := []string{}
+	taken := map[string]bool{}
+	usesStrings, usesUnsafe, usesComplex := false, false, false
+	for _, f := range p.Funcs {
+		wrapper := p.goWrapper(f, taken)
+		usesStrings = usesStrings || strings.Contains(wrapper, "C.CString(")
+		usesComplex = usesComplex || strings.Contains(wrapper, "C.complex")
+		usesUnsafe = usesUnsafe || strings.Contains(wrapper, "unsafe.")
+		wrappers = append(wrappers, wrapper)
+	}
+
+	fmt.Fprintf(w, "// Code generated by sym-exposer gen-go from %s. DO NOT EDIT.\n\n", p.Path)
+	fmt.Fprintf(w, "package %s\n\n", pkg)
+	if ldflags != "" {
+		fmt.Fprintf(w, "// #cgo LDFLAGS: %s\n", ldflags)
+	}
+	if usesStrings {
+		// for free
+		fmt.Fprintln(w, "// #include <stdlib.h>")
+	}
+	if usesComplex {
+		// cgo spells the complex types of its calls with the macro
+		fmt.Fprintln(w, "// #include <complex.h>")
+	}
+	// line comments, a C comment in the declarations would end a block comment
+	for _, line := range strings.Split(strings.TrimRight(decls.String(), "\n"), "\n") {
+		fmt.Fprintln(w, strings.TrimRight("// "+line, " "))
+	}
+	fmt.Fprintln(w, "import \"C\"")
+	if usesUnsafe {
+		fmt.Fprintln(w, "\nimport \"unsafe\"")
+	}
+	for _, wrapper := range wrappers {
+		fmt.Fprint(w, "\n"+wrapper)
+	}
+}
+
+// goName makes an exported Go identifier of a C name, add_point is AddPoint
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	if b.Len() == 0 || ('0' <= b.String()[0] && b.String()[0] <= '9') {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// goWrapper returns the wrapper of f named uniquely among taken,
+// or a comment telling why cgo cannot call f
+func (p *Program) goWrapper(f Function, taken map[string]bool) string {
+	sig := p.Signature(f.Entry)
+	if strings.HasPrefix(f.Symbol, "_Z") {
+		return fmt.Sprintf("// %s: cgo cannot call C++ functions\n", f.Symbol)
+	}
+	if sig.Variadic {
+		return fmt.Sprintf("// %s: cgo cannot call variadic functions\n", f.Symbol)
+	}
+
+	params := []string{}
+	args := []string{}
+	body := []string{}
+	locals := map[string]bool{}
+	for i, param := range sig.Params {
+		name := param.Name
+		if goKeywords[name] {
+			name += "_"
+		}
+		if name == "" || locals[name] {
+			name = fmt.Sprintf("p%d", i)
+		}
+		locals[name] = true
+		typ, ok := p.goTypeOf(param.Type)
+		if !ok {
+			return fmt.Sprintf("// %s: cgo has no type for the parameter %s\n", f.Symbol, name)
+		}
+		if p.hasCgoRecord(param.Type) {
+			return fmt.Sprintf("// %s: the parameter %s is of type %s, which other packages cannot use\n", f.Symbol, name, typ.cgo)
+		}
+		params = append(params, name+" "+typ.name)
+		if !typ.str {
+			args = append(args, typ.toC(name))
+			continue
+		}
+		cstr := "c" + strings.ToUpper(name[:1]) + name[1:]
+		for locals[cstr] {
+			cstr += "_"
+		}
+		locals[cstr] = true
+		body = append(body, fmt.Sprintf("%s := C.CString(%s)", cstr, name))
+		body = append(body, fmt.Sprintf("defer C.free(unsafe.Pointer(%s))", cstr))
+		args = append(args, cstr)
+	}
+	call := "C." + f.Symbol + "(" + strings.Join(args, ", ") + ")"
+	result := ""
+	if sig.Return == nil {
+		body = append(body, call)
+	} else {
+		typ, ok := p.goTypeOf(sig.Return)
+		if !ok {
+			return fmt.Sprintf("// %s: cgo has no type for the return value\n", f.Symbol)
+		}
+		if p.hasCgoRecord(sig.Return) {
+			return fmt.Sprintf("// %s: the return value is of type %s, which other packages cannot use\n", f.Symbol, typ.cgo)
+		}
+		result = " " + typ.name
+		body = append(body, "return "+typ.fromC(call))
+	}
+
+	name := goName(f.Symbol)
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s%d", goName(f.Symbol), n)
+	}
+	taken[name] = true
+	var b strings.Builder
+	if f.File != "" {
+		fmt.Fprintf(&b, "// %s calls %s of %s:%d\n", name, f.Symbol, f.File, f.Line)
+	} else {
+		fmt.Fprintf(&b, "// %s calls %s\n", name, f.Symbol)
+	}
+	fmt.Fprintf(&b, "func %s(%s)%s {\n", name, strings.Join(params, ", "), result)
+	for _, line := range body {
+		b.WriteString("\t" + line + "\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// goTypeOf returns how a wrapper passes the C type t. Numbers are passed as
+// Go numbers and const char * as a string, other types keep their cgo type.
+func (p *Program) goTypeOf(t *dwarf.Entry) (goType, bool) {
+	cgo := p.cgoType(t, 0)
+	if cgo == "" {
+		return goType{}, false
+	}
+	if p.isCString(t) {
+		return goType{name: "string", cgo: cgo, str: true}, true
+	}
+	if scalar := p.goScalar(t); scalar != "" {
+		return goType{name: scalar, cgo: cgo}, true
+	}
+	return goType{name: cgo, cgo: cgo}, true
+}
+
+// cgoType returns the name cgo gives the C type t, "" when it has none
+func (p *Program) cgoType(t *dwarf.Entry, depth int) string {
+	if t == nil || MAX_TYPE_DEPTH < depth {
+		return ""
+	}
+	switch t.Tag {
+	case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type:
+		return p.cgoType(p.typeOf(t), depth+1)
+	case dwarf.DW_TAG_pointer_type:
+		target := p.unqualified(p.typeOf(t))
+		if target == nil {
+			return "unsafe.Pointer"
+		}
+		if target.Tag == dwarf.DW_TAG_subroutine_type {
+			return "*[0]byte"
+		}
+		if elem := p.cgoType(target, depth+1); elem != "" {
+			return "*" + elem
+		}
+	case dwarf.DW_TAG_typedef:
+		if !strings.HasPrefix(t.Name(), "__builtin_") {
+			return "C." + t.Name()
+		}
+	case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_union_type, dwarf.DW_TAG_enumeration_type:
+		kind := map[uint64]string{
+			dwarf.DW_TAG_structure_type:   "struct_",
+			dwarf.DW_TAG_union_type:       "union_",
+			dwarf.DW_TAG_enumeration_type: "enum_",
+		}[t.Tag]
+		if t.Name() != "" {
+			return "C." + kind + t.Name()
+		}
+	case dwarf.DW_TAG_array_type:
+		elem := p.cgoType(p.typeOf(t), depth+1)
+		dims := arrayDims(t)
+		for i := len(dims) - 1; 0 <= i && elem != ""; i-- {
+			if dims[i] < 0 {
+				return ""
+			}
+			elem = fmt.Sprintf("[%d]%s", dims[i], elem)
+		}
+		return elem
+	case dwarf.DW_TAG_base_type:
+		return cgoTypeMap[t.Name()]
+	}
+	return ""
+}
+
+// hasCgoRecord reports whether t is a structure or union, or reaches one through
+// typedefs, pointers and arrays. Its cgo type, C.struct_X, is local to the package.
+func (p *Program) hasCgoRecord(t *dwarf.Entry) bool {
+	for i := 0; t != nil && i <= MAX_TYPE_DEPTH; i++ {
+		switch t.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type,
+			dwarf.DW_TAG_typedef, dwarf.DW_TAG_pointer_type, dwarf.DW_TAG_array_type:
+			t = p.typeOf(t)
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_union_type:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// unqualified returns t without its const, volatile and restrict qualifiers
+func (p *Program) unqualified(t *dwarf.Entry) *dwarf.Entry {
+	for i := 0; t != nil && i <= MAX_TYPE_DEPTH; i++ {
+		switch t.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type:
+			t = p.typeOf(t)
+		default:
+			return t
+		}
+	}
+	return t
+}
+
+// isCString reports whether t is a const char *, a C string the function does not write to
+func (p *Program) isCString(t *dwarf.Entry) bool {
+	t = p.unqualified(t)
+	if t == nil || t.Tag != dwarf.DW_TAG_pointer_type {
+		return false
+	}
+	target := p.typeOf(t)
+	if target == nil || target.Tag != dwarf.DW_TAG_const_type {
+		return false
+	}
+	char := p.unqualified(target)
+	return char != nil && char.Tag == dwarf.DW_TAG_base_type && char.Name() == "char"
+}
+
+// goScalar returns the Go number type of a C number or enumeration under typedefs, "" for other types
+func (p *Program) goScalar(t *dwarf.Entry) string {
+	for i := 0; t != nil && i <= MAX_TYPE_DEPTH; i++ {
+		switch t.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_typedef:
+			t = p.typeOf(t)
+			continue
+		case dwarf.DW_TAG_enumeration_type:
+			size, _ := t.Val(dwarf.DW_AT_byte_size)
+			if size == 0 || 8 < size {
+				return ""
+			}
+			if p.isSigned(p.typeOf(t)) {
+				return fmt.Sprintf("int%d", size*8)
+			}
+			return fmt.Sprintf("uint%d", size*8)
+		case dwarf.DW_TAG_base_type:
+			size, _ := t.Val(dwarf.DW_AT_byte_size)
+			enc, _ := t.Val(dwarf.DW_AT_encoding)
+			switch {
+			case enc == dwarf.DW_ATE_boolean:
+				return "bool"
+			case enc == dwarf.DW_ATE_float && (size == 4 || size == 8):
+				return fmt.Sprintf("float%d", size*8)
+			case enc == dwarf.DW_ATE_complex_float && (size == 8 || size == 16):
+				return fmt.Sprintf("complex%d", size*8)
+			case (enc == dwarf.DW_ATE_signed || enc == dwarf.DW_ATE_signed_char) && 0 < size && size <= 8:
+				return fmt.Sprintf("int%d", size*8)
+			case (enc == dwarf.DW_ATE_unsigned || enc == dwarf.DW_ATE_unsigned_char) && 0 < size && size <= 8:
+				return fmt.Sprintf("uint%d", size*8)
+			}
+		}
+		return ""
+	}
+	return ""
+}
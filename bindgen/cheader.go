@@ -0,0 +1,314 @@
+package bindgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	dwarf "sym-exposer/dwarf"
+)
+
+// cHeader collects the declarations of a C header. Types are defined when a
+// declaration needs them complete, after the types they need themselves, so
+// defs is in dependency order. Structures and unions are forward declared
+// for the declarations that only point to them.
+type cHeader struct {
+	p        *Program
+	forwards []string
+	defs     []string
+	done     map[string]bool // "struct point", "typedef point_t", ... once defined
+	busy     map[*dwarf.Entry]bool
+	depth    int // of decl, types referring to themselves are broken DWARF
+}
+
+// WriteCHeader writes a C header declaring the functions of p with the
+// structures, unions, enumerations and typedefs they use. guard is the
+// include guard macro.
+func (p *Program) WriteCHeader(w io.Writer, guard string) {
+	fmt.Fprintf(w, "/* declarations of the functions exposed from %s */\n", p.Path)
+	fmt.Fprintf(w, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(w, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+	p.writeCDecls(w)
+	fmt.Fprintf(w, "\n#ifdef __cplusplus\n}\n#endif\n\n#endif /* %s */\n", guard)
+}
+
+// writeCDecls writes the declarations of the C header of p between its guards
+func (p *Program) writeCDecls(w io.Writer) {
+	h := cHeader{p: p, done: map[string]bool{}, busy: map[*dwarf.Entry]bool{}}
+	protos := []string{}
+	for _, f := range p.Funcs {
+		proto := "extern " + h.prototype(f.Symbol, p.Signature(f.Entry)) + ";"
+		if f.File != "" {
+			proto += fmt.Sprintf(" /* %s:%d */", f.File, f.Line)
+		}
+		protos = append(protos, proto)
+	}
+
+	if 0 < len(h.forwards) {
+		for _, forward := range h.forwards {
+			fmt.Fprintf(w, "%s;\n", forward)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, def := range h.defs {
+		fmt.Fprintf(w, "%s;\n\n", def)
+	}
+	for _, name := range p.Missing {
+		fmt.Fprintf(w, "/* %s: no debug information */\n", name)
+	}
+	for _, proto := range protos {
+		fmt.Fprintln(w, proto)
+	}
+}
+
+// HeaderGuard makes an include guard macro of a file name
+func HeaderGuard(name string) string {
+	guard := []byte(strings.ToUpper(name))
+	for i, c := range guard {
+		if !('A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			guard[i] = '_'
+		}
+	}
+	if len(guard) == 0 || ('0' <= guard[0] && guard[0] <= '9') {
+		return "SYM_" + string(guard)
+	}
+	return string(guard)
+}
+
+// declarator joins a type and the declarator applied to it
+func declarator(typ string, decl string) string {
+	if decl == "" {
+		return typ
+	}
+	return typ + " " + decl
+}
+
+// prototype declares name as a function of sig, the types of the parameters and
+// the return value and the types they point to are complete so the function can be called
+func (h *cHeader) prototype(name string, sig Signature) string {
+	proto := h.decl(sig.Return, name+"("+h.params(sig, true)+")", true)
+	h.definePointee(sig.Return)
+	for _, param := range sig.Params {
+		h.definePointee(param.Type)
+	}
+	return proto
+}
+
+// definePointee defines the type t points to. Types reserved for the
+// implementation, like struct _IO_FILE of FILE *, are left incomplete.
+func (h *cHeader) definePointee(t *dwarf.Entry) {
+	for i := 0; t != nil && t.Tag != dwarf.DW_TAG_pointer_type; i++ {
+		if MAX_TYPE_DEPTH < i {
+			return
+		}
+		switch t.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_atomic_type, dwarf.DW_TAG_typedef:
+			t = h.p.typeOf(t)
+		default:
+			return
+		}
+	}
+	if t == nil {
+		return
+	}
+	pointee := h.p.typeOf(t)
+	target := pointee
+	for i := 0; target != nil && i <= MAX_TYPE_DEPTH; i++ {
+		switch target.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_typedef:
+			target = h.p.typeOf(target)
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			if !strings.HasPrefix(target.Name(), "_") {
+				h.decl(pointee, "", true)
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (h *cHeader) params(sig Signature, complete bool) string {
+	params := []string{}
+	for _, param := range sig.Params {
+		params = append(params, h.decl(param.Type, param.Name, complete))
+	}
+	if sig.Variadic {
+		params = append(params, "...")
+	}
+	if len(params) == 0 && sig.Prototyped {
+		return "void"
+	}
+	return strings.Join(params, ", ")
+}
+
+// decl declares name as the type t, defining the types it needs.
+// complete is set where the type is used by value.
+func (h *cHeader) decl(t *dwarf.Entry, name string, complete bool) string {
+	if t == nil || MAX_TYPE_DEPTH < h.depth {
+		return declarator("void", name)
+	}
+	h.depth++
+	defer func() { h.depth-- }()
+	switch t.Tag {
+	case dwarf.DW_TAG_pointer_type, dwarf.DW_TAG_reference_type, dwarf.DW_TAG_rvalue_reference_type:
+		target := h.p.typeOf(t)
+		name = "*" + name
+		if target != nil && (target.Tag == dwarf.DW_TAG_array_type || target.Tag == dwarf.DW_TAG_subroutine_type) {
+			name = "(" + name + ")"
+		}
+		return h.decl(target, name, false)
+	case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_atomic_type:
+		qualifier := map[uint64]string{
+			dwarf.DW_TAG_const_type:    "const",
+			dwarf.DW_TAG_volatile_type: "volatile",
+			dwarf.DW_TAG_restrict_type: "restrict",
+			dwarf.DW_TAG_atomic_type:   "_Atomic",
+		}[t.Tag]
+		target := h.p.typeOf(t)
+		// a qualified pointer is qualified after the *
+		if target != nil && target.Tag == dwarf.DW_TAG_pointer_type {
+			return h.decl(target, declarator(qualifier, name), complete)
+		}
+		return qualifier + " " + h.decl(target, name, complete)
+	case dwarf.DW_TAG_array_type:
+		for _, dim := range arrayDims(t) {
+			if dim < 0 {
+				name += "[]"
+			} else {
+				name += fmt.Sprintf("[%d]", dim)
+			}
+		}
+		return h.decl(h.p.typeOf(t), name, true)
+	case dwarf.DW_TAG_subroutine_type:
+		sig := h.p.Signature(t)
+		return h.decl(sig.Return, name+"("+h.params(sig, false)+")", false)
+	case dwarf.DW_TAG_typedef:
+		h.defineTypedef(t, complete)
+		return declarator(t.Name(), name)
+	case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+		if t.Name() == "" {
+			return declarator(h.recordBody(t), name)
+		}
+		h.forward(recordKind(t) + " " + t.Name())
+		if complete {
+			h.defineRecord(t)
+		}
+		return declarator(recordKind(t)+" "+t.Name(), name)
+	case dwarf.DW_TAG_enumeration_type:
+		if t.Name() == "" {
+			return declarator(h.enumBody(t), name)
+		}
+		// C has no incomplete enumerations
+		h.defineEnum(t)
+		return declarator("enum "+t.Name(), name)
+	case dwarf.DW_TAG_base_type:
+		return declarator(cBaseTypeName(t.Name()), name)
+	}
+	// DW_TAG_unspecified_type and types C has no way to write
+	return declarator("void", name)
+}
+
+func recordKind(t *dwarf.Entry) string {
+	if t.Tag == dwarf.DW_TAG_union_type {
+		return "union"
+	}
+	return "struct"
+}
+
+// cBaseTypeName returns the C spelling of a base type name of gcc
+func cBaseTypeName(name string) string {
+	if strings.HasPrefix(name, "complex ") {
+		return "_Complex " + strings.TrimPrefix(name, "complex ")
+	}
+	return name
+}
+
+func (h *cHeader) forward(key string) {
+	if h.done["forward "+key] {
+		return
+	}
+	h.done["forward "+key] = true
+	h.forwards = append(h.forwards, key)
+}
+
+// defineTypedef defines the typedef t, and the type it names when complete is set
+func (h *cHeader) defineTypedef(t *dwarf.Entry, complete bool) {
+	if h.busy[t] {
+		return
+	}
+	h.busy[t] = true
+	target := h.p.typeOf(t)
+	key := "typedef " + t.Name()
+	// the compiler declares __builtin_va_list and the like itself
+	if !h.done[key] && !strings.HasPrefix(t.Name(), "__builtin_") {
+		def := "typedef " + h.decl(target, t.Name(), false)
+		h.done[key] = true
+		h.defs = append(h.defs, def)
+	}
+	if complete && target != nil {
+		h.decl(target, "", true)
+	}
+	h.busy[t] = false
+}
+
+func (h *cHeader) defineRecord(t *dwarf.Entry) {
+	key := recordKind(t) + " " + t.Name()
+	if h.done[key] || h.busy[t] || t.Flag(dwarf.DW_AT_declaration) {
+		return
+	}
+	h.busy[t] = true
+	def := h.recordBody(t)
+	h.busy[t] = false
+	h.done[key] = true
+	h.defs = append(h.defs, def)
+}
+
+func (h *cHeader) defineEnum(t *dwarf.Entry) {
+	key := "enum " + t.Name()
+	if h.done[key] || t.Flag(dwarf.DW_AT_declaration) {
+		return
+	}
+	h.done[key] = true
+	h.defs = append(h.defs, h.enumBody(t))
+}
+
+// recordBody returns the definition of a structure or union, the members of
+// nested anonymous types are indented with their type
+func (h *cHeader) recordBody(t *dwarf.Entry) string {
+	var b strings.Builder
+	b.WriteString(declarator(recordKind(t), t.Name()))
+	b.WriteString(" {\n")
+	for _, member := range t.Children {
+		// C++ static members are declarations
+		if member.Tag != dwarf.DW_TAG_member || member.Flag(dwarf.DW_AT_declaration) {
+			continue
+		}
+		line := h.decl(h.p.typeOf(member), member.Name(), true)
+		if bits, exist := member.Val(dwarf.DW_AT_bit_size); exist {
+			line += fmt.Sprintf(" : %d", bits)
+		}
+		b.WriteString("\t" + strings.ReplaceAll(line, "\n", "\n\t") + ";\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (h *cHeader) enumBody(t *dwarf.Entry) string {
+	signed := h.p.isSigned(h.p.typeOf(t))
+	var b strings.Builder
+	b.WriteString(declarator("enum", t.Name()))
+	b.WriteString(" {\n")
+	for _, enumerator := range t.Children {
+		if enumerator.Tag != dwarf.DW_TAG_enumerator {
+			continue
+		}
+		val, negative := enumValue(enumerator, signed)
+		if negative {
+			fmt.Fprintf(&b, "\t%s = %d,\n", enumerator.Name(), int64(val))
+		} else {
+			fmt.Fprintf(&b, "\t%s = %d,\n", enumerator.Name(), val)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
@@ -0,0 +1,653 @@
+package bindgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	dwarf "sym-exposer/dwarf"
+)
+
+// cppItem is a declaration of a C++ header with the namespaces around it
+// from the outermost, "" names an anonymous namespace
+type cppItem struct {
+	scope []string
+	text  string
+}
+
+// cppHeader collects the declarations of a C++ header like cHeader does for C.
+// Types nested in a class cannot be declared outside of it, so they are
+// defined with the outermost class.
+type cppHeader struct {
+	p        *Program
+	forwards []cppItem
+	defs     []cppItem
+	done     map[string]bool
+	busy     map[*dwarf.Entry]bool
+	depth    int
+	methods  map[string][]Function // exposed member functions declared in their class, by class name
+	shims    map[string]bool
+}
+
+// IsCPlusPlus reports whether one of the functions of p is in a C++ unit
+func (p *Program) IsCPlusPlus() bool {
+	for _, f := range p.Funcs {
+		lang, _ := f.Entry.Unit.Root.Val(dwarf.DW_AT_language)
+		switch lang {
+		case dwarf.DW_LANG_C_plus_plus, dwarf.DW_LANG_C_plus_plus_03, dwarf.DW_LANG_C_plus_plus_11, dwarf.DW_LANG_C_plus_plus_14:
+			return true
+		}
+	}
+	return false
+}
+
+// WriteCppHeader writes a C++ header declaring the functions of p in their
+// namespaces and classes, with the types they use. A function whose mangled
+// name cannot be declared in source, one with internal linkage or in an
+// anonymous namespace, gets an extern "C" shim bound to the symbol by an asm
+// label. The this parameter of a member function shim is named self.
+func (p *Program) WriteCppHeader(w io.Writer, guard string) {
+	h := cppHeader{p: p, done: map[string]bool{}, busy: map[*dwarf.Entry]bool{}, methods: map[string][]Function{}, shims: map[string]bool{}}
+	// the member functions are known before their classes are defined
+	spelled := map[string]bool{}
+	for _, f := range p.Funcs {
+		if !strings.HasPrefix(f.Symbol, "_Z") || !h.spellable(f) {
+			continue
+		}
+		spelled[f.Symbol] = true
+		decl := h.declaration(f)
+		if _, class := cppScope(decl); class != nil && decl.Parent == class {
+			h.methods[cppName(class)] = append(h.methods[cppName(class)], f)
+		}
+	}
+
+	decls := []cppItem{}
+	shims := []string{}
+	for _, f := range p.Funcs {
+		sig := p.Signature(f.Entry)
+		comment := ""
+		if f.File != "" {
+			comment = fmt.Sprintf(" // %s:%d", f.File, f.Line)
+		}
+		decl := h.declaration(f)
+		switch {
+		case !strings.HasPrefix(f.Symbol, "_Z"):
+			// declared extern "C" in the source
+			shims = append(shims, "extern \"C\" "+h.prototype(f.Symbol, sig, false)+";"+comment)
+		case spelled[f.Symbol]:
+			scope, class := cppScope(decl)
+			if class != nil && decl.Parent == class {
+				// declared in the class
+				h.requireRecord(class, true)
+				continue
+			}
+			decls = append(decls, cppItem{scope, h.prototype(decl.Name(), sig, false) + ";" + comment})
+		default:
+			name := h.shimName(cppName(decl))
+			shim := fmt.Sprintf("extern \"C\" %s __asm__(\"%s\");%s", h.prototype(name, sig, true), f.Symbol, comment)
+			shims = append(shims, shim)
+		}
+	}
+
+	fmt.Fprintf(w, "// declarations of the functions exposed from %s\n", p.Path)
+	fmt.Fprintf(w, "#ifndef %s\n#define %s\n\n", guard, guard)
+	writeCppItems(w, h.forwards, ";\n")
+	writeCppItems(w, h.defs, ";\n\n")
+	writeCppItems(w, decls, "\n")
+	for _, name := range p.Missing {
+		fmt.Fprintf(w, "// %s: no debug information\n", name)
+	}
+	for _, shim := range shims {
+		fmt.Fprintln(w, shim)
+	}
+	fmt.Fprintf(w, "\n#endif // %s\n", guard)
+}
+
+// writeCppItems writes items with sep after each, opening their namespaces
+// once for each run of items in the same namespaces
+func writeCppItems(w io.Writer, items []cppItem, sep string) {
+	for i := 0; i < len(items); {
+		scope := items[i].scope
+		j := i
+		for j < len(items) && strings.Join(items[j].scope, "::") == strings.Join(scope, "::") {
+			j++
+		}
+		for _, ns := range scope {
+			if ns == "" {
+				fmt.Fprintln(w, "namespace {")
+			} else {
+				fmt.Fprintf(w, "namespace %s {\n", ns)
+			}
+		}
+		for _, item := range items[i:j] {
+			fmt.Fprint(w, item.text+sep)
+		}
+		for range scope {
+			fmt.Fprintln(w, "}")
+		}
+		if !strings.HasSuffix(sep, "\n\n") || 0 < len(scope) {
+			fmt.Fprintln(w)
+		}
+		i = j
+	}
+}
+
+// cppScope returns the namespaces around e, from the outermost with "" for an
+// anonymous namespace, and the outermost class e is nested in
+func cppScope(e *dwarf.Entry) ([]string, *dwarf.Entry) {
+	scope := []string{}
+	var class *dwarf.Entry
+	for parent := e.ScopeParent(); parent != nil; parent = parent.Parent {
+		switch parent.Tag {
+		case dwarf.DW_TAG_namespace:
+			scope = append([]string{parent.Name()}, scope...)
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			class = parent
+		}
+	}
+	return scope, class
+}
+
+// cppName returns the qualified name of e, a member of an anonymous namespace
+// is found without naming the namespace
+func cppName(e *dwarf.Entry) string {
+	return strings.ReplaceAll(e.QualifiedName(), "(anonymous namespace)::", "")
+}
+
+// templateName returns the name of the template of an instance like Box<int, 3>
+func templateName(name string) (string, bool) {
+	idx := strings.Index(name, "<")
+	if idx <= 0 || strings.HasPrefix(name, "operator") {
+		return name, false
+	}
+	return name[:idx], true
+}
+
+// declaration returns the entry declaring f, the one the definition completes
+func (h *cppHeader) declaration(f Function) *dwarf.Entry {
+	chain := h.p.origins(f.Entry)
+	return chain[len(chain)-1]
+}
+
+// spellable reports whether a declaration of f in source has the mangled name of f:
+// it has external linkage and neither it nor its types are in an anonymous namespace
+func (h *cppHeader) spellable(f Function) bool {
+	chain := h.p.origins(f.Entry)
+	decl := chain[len(chain)-1]
+	external := false
+	for _, e := range chain {
+		external = external || e.Flag(dwarf.DW_AT_external)
+	}
+	// function templates are instantiated, not declared
+	if _, isTemplate := templateName(decl.Name()); !external || isTemplate {
+		return false
+	}
+	for scope := decl.Parent; scope != nil; scope = scope.Parent {
+		switch scope.Tag {
+		case dwarf.DW_TAG_namespace, dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			if scope.Name() == "" {
+				return false
+			}
+		case dwarf.DW_TAG_subprogram, dwarf.DW_TAG_lexical_block:
+			return false
+		}
+	}
+	sig := h.p.Signature(f.Entry)
+	if h.internal(sig.Return, 0) {
+		return false
+	}
+	for _, param := range sig.Params {
+		if !param.Artificial && h.internal(param.Type, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// internal reports whether the type t has internal linkage, which a function
+// with t in its mangled name gets too. A typedef is mangled as the type it names.
+func (h *cppHeader) internal(t *dwarf.Entry, depth int) bool {
+	for ; t != nil && depth <= MAX_TYPE_DEPTH; depth++ {
+		switch t.Tag {
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type, dwarf.DW_TAG_enumeration_type:
+			if t.Name() == "" || strings.Contains(t.QualifiedName(), "(anonymous namespace)") {
+				return true
+			}
+			for parent := t.Parent; parent != nil; parent = parent.Parent {
+				if parent.Tag == dwarf.DW_TAG_subprogram {
+					return true
+				}
+			}
+			return false
+		case dwarf.DW_TAG_subroutine_type:
+			sig := h.p.Signature(t)
+			for _, param := range sig.Params {
+				if h.internal(param.Type, depth+1) {
+					return true
+				}
+			}
+			t = sig.Return
+		case dwarf.DW_TAG_ptr_to_member_type:
+			if h.internal(h.p.Debug.Ref(t, dwarf.DW_AT_containing_type), depth+1) {
+				return true
+			}
+			t = h.p.typeOf(t)
+		default:
+			t = h.p.typeOf(t)
+		}
+	}
+	return false
+}
+
+// shimName makes an identifier of a qualified name, numbered from 2 when it is taken
+func (h *cppHeader) shimName(qualifiedName string) string {
+	ident := []byte(strings.ReplaceAll(qualifiedName, "::", "_"))
+	for i, c := range ident {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			ident[i] = '_'
+		}
+	}
+	name := strings.TrimRight(string(ident), "_")
+	for n := 2; h.shims[name]; n++ {
+		name = fmt.Sprintf("%s_%d", strings.TrimRight(string(ident), "_"), n)
+	}
+	h.shims[name] = true
+	return name
+}
+
+// prototype declares name as a function of sig like cHeader.prototype, withThis
+// keeps the this parameter of a member function as self
+func (h *cppHeader) prototype(name string, sig Signature, withThis bool) string {
+	proto := h.decl(sig.Return, name+"("+h.params(sig, true, withThis)+")", true)
+	h.definePointee(sig.Return)
+	for _, param := range sig.Params {
+		h.definePointee(param.Type)
+	}
+	return proto
+}
+
+// definePointee defines the class t points or refers to, unless it is reserved for the implementation
+func (h *cppHeader) definePointee(t *dwarf.Entry) {
+	for i := 0; t != nil; i++ {
+		if MAX_TYPE_DEPTH < i {
+			return
+		}
+		switch t.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_atomic_type, dwarf.DW_TAG_typedef:
+			t = h.p.typeOf(t)
+			continue
+		case dwarf.DW_TAG_pointer_type, dwarf.DW_TAG_reference_type, dwarf.DW_TAG_rvalue_reference_type:
+		default:
+			return
+		}
+		break
+	}
+	if t == nil {
+		return
+	}
+	pointee := h.p.typeOf(t)
+	target := pointee
+	for i := 0; target != nil && i <= MAX_TYPE_DEPTH; i++ {
+		switch target.Tag {
+		case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_typedef:
+			target = h.p.typeOf(target)
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			if !strings.HasPrefix(target.Name(), "_") {
+				h.decl(pointee, "", true)
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (h *cppHeader) params(sig Signature, complete bool, withThis bool) string {
+	params := []string{}
+	for _, param := range sig.Params {
+		if param.Artificial && !withThis {
+			continue
+		}
+		name := param.Name
+		if param.Artificial && name == "this" {
+			name = "self"
+		}
+		params = append(params, h.decl(param.Type, name, complete))
+	}
+	if sig.Variadic {
+		params = append(params, "...")
+	}
+	return strings.Join(params, ", ")
+}
+
+// decl declares name as the type t like cHeader.decl does
+func (h *cppHeader) decl(t *dwarf.Entry, name string, complete bool) string {
+	if t == nil || MAX_TYPE_DEPTH < h.depth {
+		return declarator("void", name)
+	}
+	h.depth++
+	defer func() { h.depth-- }()
+
+	switch t.Tag {
+	case dwarf.DW_TAG_pointer_type, dwarf.DW_TAG_reference_type, dwarf.DW_TAG_rvalue_reference_type:
+		target := h.p.typeOf(t)
+		name = map[uint64]string{
+			dwarf.DW_TAG_pointer_type:          "*",
+			dwarf.DW_TAG_reference_type:        "&",
+			dwarf.DW_TAG_rvalue_reference_type: "&&",
+		}[t.Tag] + name
+		if target != nil && (target.Tag == dwarf.DW_TAG_array_type || target.Tag == dwarf.DW_TAG_subroutine_type) {
+			name = "(" + name + ")"
+		}
+		return h.decl(target, name, false)
+	case dwarf.DW_TAG_ptr_to_member_type:
+		target := h.p.typeOf(t)
+		class := h.p.Debug.Ref(t, dwarf.DW_AT_containing_type)
+		if class != nil {
+			h.requireRecord(class, false)
+			name = cppName(class) + "::*" + name
+		}
+		if target != nil && (target.Tag == dwarf.DW_TAG_array_type || target.Tag == dwarf.DW_TAG_subroutine_type) {
+			name = "(" + name + ")"
+		}
+		return h.decl(target, name, false)
+	case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_atomic_type:
+		qualifier := map[uint64]string{
+			dwarf.DW_TAG_const_type:    "const",
+			dwarf.DW_TAG_volatile_type: "volatile",
+			dwarf.DW_TAG_restrict_type: "__restrict",
+		}[t.Tag]
+		target := h.p.typeOf(t)
+		if qualifier == "" {
+			// C++ has std::atomic instead
+			return h.decl(target, name, complete)
+		}
+		if target != nil && (target.Tag == dwarf.DW_TAG_pointer_type || target.Tag == dwarf.DW_TAG_ptr_to_member_type) {
+			return h.decl(target, declarator(qualifier, name), complete)
+		}
+		return qualifier + " " + h.decl(target, name, complete)
+	case dwarf.DW_TAG_array_type:
+		for _, dim := range arrayDims(t) {
+			if dim < 0 {
+				name += "[]"
+			} else {
+				name += fmt.Sprintf("[%d]", dim)
+			}
+		}
+		return h.decl(h.p.typeOf(t), name, true)
+	case dwarf.DW_TAG_subroutine_type:
+		sig := h.p.Signature(t)
+		return h.decl(sig.Return, name+"("+h.params(sig, false, false)+")", false)
+	case dwarf.DW_TAG_typedef:
+		h.defineTypedef(t, complete)
+		return declarator(cppName(t), name)
+	case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+		if t.Name() == "" {
+			return declarator(h.recordBody(t), name)
+		}
+		h.requireRecord(t, complete)
+		return declarator(cppRecordKind(t)+" "+cppName(t), name)
+	case dwarf.DW_TAG_enumeration_type:
+		if t.Name() == "" {
+			return declarator(h.enumBody(t), name)
+		}
+		h.defineEnum(t)
+		return declarator("enum "+cppName(t), name)
+	case dwarf.DW_TAG_base_type, dwarf.DW_TAG_unspecified_type:
+		if t.Name() != "" {
+			return declarator(cppBaseTypeName(t.Name()), name)
+		}
+	}
+	return declarator("void", name)
+}
+
+// cppBaseTypeName returns the C++ spelling of a base type name of gcc, which may come from a C unit
+func cppBaseTypeName(name string) string {
+	if name == "_Bool" {
+		return "bool"
+	}
+	return cBaseTypeName(name)
+}
+
+func cppRecordKind(t *dwarf.Entry) string {
+	if t.Tag == dwarf.DW_TAG_class_type {
+		return "class"
+	}
+	return recordKind(t)
+}
+
+// templateHead returns the template parameter list of the template of the
+// instance t, like template <typename T0, int T1>
+func (h *cppHeader) templateHead(t *dwarf.Entry) string {
+	params := []string{}
+	for _, child := range t.Children {
+		name := fmt.Sprintf("T%d", len(params))
+		switch child.Tag {
+		case dwarf.DW_TAG_template_type_parameter:
+			params = append(params, "typename "+name)
+		case dwarf.DW_TAG_template_value_parameter:
+			params = append(params, h.decl(h.p.typeOf(child), name, false))
+		case dwarf.DW_TAG_GNU_template_parameter_pack:
+			params = append(params, "typename... "+name)
+		}
+	}
+	return "template <" + strings.Join(params, ", ") + ">"
+}
+
+func (h *cppHeader) forward(t *dwarf.Entry) {
+	key := "forward " + cppName(t)
+	if h.done[key] {
+		return
+	}
+	h.done[key] = true
+	scope, _ := cppScope(t)
+	kind := cppRecordKind(t)
+	if base, isTemplate := templateName(t.Name()); isTemplate {
+		templateKey := "template " + strings.Join(scope, "::") + "::" + base
+		if !h.done[templateKey] {
+			h.done[templateKey] = true
+			h.forwards = append(h.forwards, cppItem{scope, h.templateHead(t) + " " + kind + " " + base})
+		}
+		kind = "template <> " + kind
+	}
+	h.forwards = append(h.forwards, cppItem{scope, kind + " " + t.Name()})
+}
+
+// requireRecord declares the structure, class or union t, and defines it
+// when complete is set. A nested type is defined with its outermost class.
+func (h *cppHeader) requireRecord(t *dwarf.Entry, complete bool) {
+	if _, class := cppScope(t); class != nil {
+		h.defineRecord(class)
+		return
+	}
+	h.forward(t)
+	if complete {
+		h.defineRecord(t)
+	}
+}
+
+func (h *cppHeader) defineRecord(t *dwarf.Entry) {
+	key := "define " + cppName(t)
+	if h.done[key] || h.busy[t] || t.Flag(dwarf.DW_AT_declaration) {
+		return
+	}
+	h.busy[t] = true
+	h.forward(t)
+	def := h.recordBody(t)
+	if _, isTemplate := templateName(t.Name()); isTemplate {
+		def = "template <> " + def
+	}
+	h.busy[t] = false
+	h.done[key] = true
+	scope, _ := cppScope(t)
+	h.defs = append(h.defs, cppItem{scope, def})
+}
+
+// defineTypedef defines the typedef t in its namespace, and the type it names when complete is set
+func (h *cppHeader) defineTypedef(t *dwarf.Entry, complete bool) {
+	if h.busy[t] {
+		return
+	}
+	h.busy[t] = true
+	target := h.p.typeOf(t)
+	scope, class := cppScope(t)
+	if class != nil {
+		h.defineRecord(class)
+	} else if key := "typedef " + cppName(t); !h.done[key] && !strings.HasPrefix(t.Name(), "__builtin_") {
+		def := "typedef " + h.decl(target, t.Name(), false)
+		h.done[key] = true
+		h.defs = append(h.defs, cppItem{scope, def})
+	}
+	if complete && target != nil {
+		h.decl(target, "", true)
+	}
+	h.busy[t] = false
+}
+
+func (h *cppHeader) defineEnum(t *dwarf.Entry) {
+	scope, class := cppScope(t)
+	if class != nil {
+		h.defineRecord(class)
+		return
+	}
+	key := "enum " + cppName(t)
+	if h.done[key] || t.Flag(dwarf.DW_AT_declaration) {
+		return
+	}
+	h.done[key] = true
+	h.defs = append(h.defs, cppItem{scope, h.enumBody(t)})
+}
+
+// recordBody returns the definition of a structure, class or union with its
+// nested types, data members and the exposed member functions that can be declared.
+// The virtual table pointer is kept as a data member so the layout stays the same.
+func (h *cppHeader) recordBody(t *dwarf.Entry) string {
+	lines := []string{}
+	bases := []string{}
+	for _, child := range t.Children {
+		switch child.Tag {
+		case dwarf.DW_TAG_inheritance:
+			base := h.p.typeOf(child)
+			if base == nil {
+				continue
+			}
+			h.decl(base, "", true)
+			access := "public "
+			if virtuality, _ := child.Val(dwarf.DW_AT_virtuality); virtuality != 0 {
+				access = "virtual public "
+			}
+			bases = append(bases, access+cppName(base))
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			if child.Name() != "" && !child.Flag(dwarf.DW_AT_declaration) {
+				lines = append(lines, h.recordBody(child)+";")
+			}
+		case dwarf.DW_TAG_enumeration_type:
+			if child.Name() != "" && !child.Flag(dwarf.DW_AT_declaration) {
+				lines = append(lines, h.enumBody(child)+";")
+			}
+		case dwarf.DW_TAG_typedef:
+			lines = append(lines, "typedef "+h.decl(h.p.typeOf(child), child.Name(), false)+";")
+		}
+	}
+	for _, member := range t.Children {
+		// static data members are declarations
+		if member.Tag != dwarf.DW_TAG_member || member.Flag(dwarf.DW_AT_declaration) {
+			continue
+		}
+		if member.Flag(dwarf.DW_AT_artificial) {
+			lines = append(lines, "void *"+strings.NewReplacer(".", "_", "$", "_").Replace(member.Name())+";")
+			continue
+		}
+		line := h.decl(h.p.typeOf(member), member.Name(), true)
+		if bits, exist := member.Val(dwarf.DW_AT_bit_size); exist {
+			line += fmt.Sprintf(" : %d", bits)
+		}
+		lines = append(lines, line+";")
+	}
+	for _, f := range h.methods[cppName(t)] {
+		lines = append(lines, h.method(f, t))
+	}
+
+	var b strings.Builder
+	b.WriteString(declarator(cppRecordKind(t), t.Name()))
+	if 0 < len(bases) {
+		b.WriteString(" : " + strings.Join(bases, ", "))
+	}
+	b.WriteString(" {\n")
+	if t.Tag == dwarf.DW_TAG_class_type {
+		b.WriteString("public:\n")
+	}
+	for _, line := range lines {
+		b.WriteString("\t" + strings.ReplaceAll(line, "\n", "\n\t") + "\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// method declares the member function f in the body of its class
+func (h *cppHeader) method(f Function, class *dwarf.Entry) string {
+	sig := h.p.Signature(f.Entry)
+	name := h.declaration(f).Name()
+	static := len(sig.Params) == 0 || !sig.Params[0].Artificial
+	qualifiers := ""
+	if !static {
+		// the qualifiers of the class this points to, this itself is const in a definition
+		this := sig.Params[0].Type
+		for i := 0; this != nil && this.Tag != dwarf.DW_TAG_pointer_type && i < MAX_TYPE_DEPTH; i++ {
+			this = h.p.typeOf(this)
+		}
+		for target := this; target != nil; {
+			if target = h.p.typeOf(target); target == nil {
+				break
+			} else if target.Tag == dwarf.DW_TAG_const_type {
+				qualifiers += " const"
+			} else if target.Tag == dwarf.DW_TAG_volatile_type {
+				qualifiers += " volatile"
+			} else {
+				break
+			}
+		}
+	}
+	proto := name + "(" + h.params(sig, true, false) + ")" + qualifiers
+	className, _ := templateName(class.Name())
+	// constructors and destructors have no return type
+	if name != className && name != "~"+className {
+		proto = h.decl(sig.Return, proto, true)
+	}
+	if static {
+		proto = "static " + proto
+	}
+	if f.File != "" {
+		return fmt.Sprintf("%s; // %s:%d", proto, f.File, f.Line)
+	}
+	return proto + ";"
+}
+
+func (h *cppHeader) enumBody(t *dwarf.Entry) string {
+	underlying := h.p.typeOf(t)
+	signed := h.p.isSigned(underlying)
+	var b strings.Builder
+	b.WriteString("enum")
+	if t.Flag(dwarf.DW_AT_enum_class) {
+		b.WriteString(" class")
+	}
+	if t.Name() != "" {
+		b.WriteString(" " + t.Name())
+	}
+	if underlying != nil {
+		b.WriteString(" : " + h.decl(underlying, "", false))
+	}
+	b.WriteString(" {\n")
+	for _, enumerator := range t.Children {
+		if enumerator.Tag != dwarf.DW_TAG_enumerator {
+			continue
+		}
+		val, negative := enumValue(enumerator, signed)
+		if negative {
+			fmt.Fprintf(&b, "\t%s = %d,\n", enumerator.Name(), int64(val))
+		} else {
+			fmt.Fprintf(&b, "\t%s = %d,\n", enumerator.Name(), val)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
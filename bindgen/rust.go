@@ -0,0 +1,406 @@
+package bindgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	dwarf "sym-exposer/dwarf"
+)
+
+// rsTypeMap names the Rust types of the C base types of gcc, Rust has none for long double and complex types
+var rsTypeMap = map[string]string{
+	"char":                   "c_char",
+	"signed char":            "c_schar",
+	"unsigned char":          "c_uchar",
+	"short int":              "c_short",
+	"short unsigned int":     "c_ushort",
+	"int":                    "c_int",
+	"unsigned int":           "c_uint",
+	"long int":               "c_long",
+	"long unsigned int":      "c_ulong",
+	"long long int":          "c_longlong",
+	"long long unsigned int": "c_ulonglong",
+	"float":                  "f32",
+	"double":                 "f64",
+	"_Bool":                  "bool",
+	"__int128":               "i128",
+	"__int128 unsigned":      "u128",
+}
+
+var rsKeywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true, "continue": true,
+	"dyn": true, "else": true, "enum": true, "extern": true, "false": true, "fn": true,
+	"for": true, "gen": true, "if": true, "impl": true, "in": true, "let": true, "loop": true,
+	"match": true, "mod": true, "move": true, "mut": true, "pub": true, "ref": true,
+	"return": true, "static": true, "struct": true, "trait": true, "true": true, "type": true,
+	"unsafe": true, "use": true, "where": true, "while": true, "abstract": true, "become": true,
+	"box": true, "do": true, "final": true, "macro": true, "override": true, "priv": true,
+	"try": true, "typeof": true, "unsized": true, "virtual": true, "yield": true,
+}
+
+// rsIdent returns a C name as a Rust identifier, a keyword is a raw identifier
+func rsIdent(name string) string {
+	switch {
+	case name == "self" || name == "Self" || name == "super" || name == "crate" || name == "_":
+		return name + "_"
+	case rsKeywords[name]:
+		return "r#" + name
+	}
+	return name
+}
+
+// rsFile collects the type definitions of a Rust file. Rust items are not
+// ordered, so types are kept in the order they are met. A structure only
+// pointed to is opaque until a declaration needs it complete.
+type rsFile struct {
+	p     *Program
+	items []string
+	index map[string]int  // of the item defining a type
+	full  map[string]bool // of the types defined with their members
+	raw   map[string]bool // the std::os::raw types used
+	depth int
+}
+
+// WriteRust writes a Rust module declaring the functions of p in an extern
+// "C" block, with #[repr(C)] structures and unions and type aliases of the
+// types they use. Enumerations are integer aliases with a constant per
+// enumerator, as a C function may return any value. link, when set, is the
+// library the extern block links.
+func (p *Program) WriteRust(w io.Writer, link string) {
+	r := rsFile{p: p, index: map[string]int{}, full: map[string]bool{}, raw: map[string]bool{}}
+	decls := []string{}
+	for _, f := range p.Funcs {
+		decls = append(decls, r.function(f))
+	}
+
+	fmt.Fprintf(w, "// declarations of the functions exposed from %s\n", p.Path)
+	fmt.Fprintln(w, "#![allow(non_camel_case_types, non_upper_case_globals, non_snake_case, dead_code)]")
+	fmt.Fprintln(w)
+	if 0 < len(r.raw) {
+		raw := []string{}
+		for name := range r.raw {
+			raw = append(raw, name)
+		}
+		sort.Strings(raw)
+		fmt.Fprintf(w, "use std::os::raw::{%s};\n\n", strings.Join(raw, ", "))
+	}
+	for _, item := range r.items {
+		fmt.Fprintf(w, "%s\n\n", item)
+	}
+	if link != "" {
+		fmt.Fprintf(w, "#[link(name = \"%s\")]\n", link)
+	}
+	fmt.Fprintln(w, "extern \"C\" {")
+	for _, name := range p.Missing {
+		fmt.Fprintf(w, "    // %s: no debug information\n", name)
+	}
+	for _, decl := range decls {
+		fmt.Fprintf(w, "    %s\n", decl)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// function returns the declaration of f in the extern block, or a comment
+// telling why it has none
+func (r *rsFile) function(f Function) string {
+	if f.Rust {
+		return fmt.Sprintf("// %s: in a Rust unit, call it through its crate", f.Symbol)
+	}
+	if strings.HasPrefix(f.Symbol, "_Z") {
+		return fmt.Sprintf("// %s: a C++ function", f.Symbol)
+	}
+	sig := r.p.Signature(f.Entry)
+	params := []string{}
+	taken := map[string]bool{}
+	for i, param := range sig.Params {
+		name := rsIdent(param.Name)
+		if name == "" || taken[name] {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		taken[name] = true
+		typ := r.rsType(param.Type, true)
+		if typ == "" {
+			return fmt.Sprintf("// %s: Rust has no type for the parameter %s", f.Symbol, name)
+		}
+		params = append(params, name+": "+typ)
+	}
+	if sig.Variadic {
+		params = append(params, "...")
+	}
+	decl := "pub fn " + rsIdent(f.Symbol) + "(" + strings.Join(params, ", ") + ")"
+	if sig.Return != nil {
+		typ := r.rsType(sig.Return, true)
+		if typ == "" {
+			return fmt.Sprintf("// %s: Rust has no type for the return value", f.Symbol)
+		}
+		decl += " -> " + typ
+	}
+	if f.File != "" {
+		return fmt.Sprintf("%s; // %s:%d", decl, f.File, f.Line)
+	}
+	return decl + ";"
+}
+
+// rsType returns the Rust type of the C type t, defining the types it needs,
+// "" when Rust has none. complete is set where the type is used by value,
+// otherwise structures reserved for the implementation stay opaque.
+func (r *rsFile) rsType(t *dwarf.Entry, complete bool) string {
+	if t == nil || MAX_TYPE_DEPTH < r.depth {
+		return ""
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+
+	switch t.Tag {
+	case dwarf.DW_TAG_const_type, dwarf.DW_TAG_volatile_type, dwarf.DW_TAG_restrict_type, dwarf.DW_TAG_atomic_type:
+		return r.rsType(r.p.typeOf(t), complete)
+	case dwarf.DW_TAG_pointer_type, dwarf.DW_TAG_reference_type, dwarf.DW_TAG_rvalue_reference_type:
+		target := r.p.typeOf(t)
+		kind := "*mut "
+		if target != nil && target.Tag == dwarf.DW_TAG_const_type {
+			kind = "*const "
+		}
+		if unqualified := r.p.unqualified(target); unqualified != nil && unqualified.Tag == dwarf.DW_TAG_subroutine_type {
+			// a C function pointer may be null
+			if fn := r.fnType(unqualified); fn != "" {
+				return "Option<" + fn + ">"
+			}
+		} else if elem := r.rsType(target, false); elem != "" {
+			return kind + elem
+		}
+		// a pointer to a type Rust cannot write is passed untyped
+		r.raw["c_void"] = true
+		return kind + "c_void"
+	case dwarf.DW_TAG_typedef:
+		return r.defineTypedef(t, complete)
+	case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+		if t.Name() == "" {
+			return ""
+		}
+		r.defineRecord(t, rsIdent(t.Name()), complete || !strings.HasPrefix(t.Name(), "_"))
+		return rsIdent(t.Name())
+	case dwarf.DW_TAG_enumeration_type:
+		if t.Name() == "" {
+			return ""
+		}
+		return r.defineEnum(t, rsIdent(t.Name()))
+	case dwarf.DW_TAG_array_type:
+		elem := r.rsType(r.p.typeOf(t), true)
+		dims := arrayDims(t)
+		for i := len(dims) - 1; 0 <= i && elem != ""; i-- {
+			// a flexible array member takes no room
+			elem = fmt.Sprintf("[%s; %d]", elem, max(dims[i], 0))
+		}
+		return elem
+	case dwarf.DW_TAG_subroutine_type:
+		return ""
+	case dwarf.DW_TAG_base_type:
+		typ := rsTypeMap[t.Name()]
+		if strings.HasPrefix(typ, "c_") {
+			r.raw[typ] = true
+		}
+		return typ
+	}
+	return ""
+}
+
+// fnType returns the Rust function pointer type of a subroutine type, "" when Rust has none
+func (r *rsFile) fnType(t *dwarf.Entry) string {
+	sig := r.p.Signature(t)
+	params := []string{}
+	for _, param := range sig.Params {
+		typ := r.rsType(param.Type, true)
+		if typ == "" {
+			return ""
+		}
+		params = append(params, typ)
+	}
+	if sig.Variadic {
+		params = append(params, "...")
+	}
+	fn := "unsafe extern \"C\" fn(" + strings.Join(params, ", ") + ")"
+	if sig.Return != nil {
+		typ := r.rsType(sig.Return, true)
+		if typ == "" {
+			return ""
+		}
+		fn += " -> " + typ
+	}
+	return fn
+}
+
+// define sets the item of the type name, the first one met keeps its place
+func (r *rsFile) define(name string, item string) {
+	if idx, exist := r.index[name]; exist {
+		r.items[idx] = item
+		return
+	}
+	r.index[name] = len(r.items)
+	r.items = append(r.items, item)
+}
+
+// defineTypedef defines the typedef t as an alias, a typedef naming an
+// anonymous structure, union or enumeration names the type itself
+func (r *rsFile) defineTypedef(t *dwarf.Entry, complete bool) string {
+	name := rsIdent(t.Name())
+	// the compiler declares __builtin_va_list and the like itself
+	if strings.HasPrefix(t.Name(), "__builtin_") {
+		return ""
+	}
+	target := r.p.typeOf(t)
+	if target != nil && target.Name() == "" {
+		switch target.Tag {
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			r.defineRecord(target, name, true)
+			return name
+		case dwarf.DW_TAG_enumeration_type:
+			return r.defineEnum(target, name)
+		}
+	}
+	// typedef struct node node names the structure
+	if target != nil && target.Name() == t.Name() {
+		return r.rsType(target, complete)
+	}
+	if _, exist := r.index[name]; exist {
+		if complete {
+			r.rsType(target, true)
+		}
+		return name
+	}
+	// reserved before the aliased type is met for a typedef referring to itself through a pointer
+	r.define(name, "")
+	typ := r.rsType(target, complete)
+	if typ == "" {
+		r.define(name, fmt.Sprintf("// %s: Rust has no type for it", name))
+		return ""
+	}
+	r.define(name, fmt.Sprintf("pub type %s = %s;", name, typ))
+	return name
+}
+
+// defineRecord defines the structure or union t as name, with its members
+// when full is set and opaque otherwise
+func (r *rsFile) defineRecord(t *dwarf.Entry, name string, full bool) {
+	if r.full[name] {
+		return
+	}
+	if !full || t.Flag(dwarf.DW_AT_declaration) {
+		if _, exist := r.index[name]; !exist {
+			r.define(name, fmt.Sprintf("#[repr(C)]\npub struct %s {\n    _private: [u8; 0],\n}", name))
+		}
+		return
+	}
+	r.full[name] = true
+	r.define(name, "")
+	r.define(name, r.recordBody(t, name))
+}
+
+// recordBody returns the definition of a structure or union. Rust has no
+// bitfields, a run of them is kept as the bytes from its first bit to the
+// next member. Nested anonymous types are named after their member.
+func (r *rsFile) recordBody(t *dwarf.Entry, name string) string {
+	kind := "struct"
+	if t.Tag == dwarf.DW_TAG_union_type {
+		kind = "union"
+	}
+	lines := []string{}
+	bitfields := []string{}
+	bitStart := uint64(0)
+	runs := 0
+	endBitfields := func(end uint64) {
+		if len(bitfields) == 0 {
+			return
+		}
+		runs++
+		lines = append(lines, "// "+strings.Join(bitfields, ", "))
+		lines = append(lines, fmt.Sprintf("pub _bitfield_%d: [u8; %d],", runs, end-bitStart))
+		bitfields = []string{}
+	}
+	anonymous := 0
+	for _, member := range t.Children {
+		if member.Tag != dwarf.DW_TAG_member || member.Flag(dwarf.DW_AT_declaration) {
+			continue
+		}
+		offset, _ := member.Val(dwarf.DW_AT_data_member_location)
+		if bits, exist := member.Val(dwarf.DW_AT_bit_size); exist {
+			if len(bitfields) == 0 {
+				bitStart = offset
+				if bitOffset, exist := member.Val(dwarf.DW_AT_data_bit_offset); exist {
+					bitStart = bitOffset / 8
+				}
+			}
+			bitfields = append(bitfields, fmt.Sprintf("%s: %d bits", member.Name(), bits))
+			continue
+		}
+		endBitfields(offset)
+
+		field := rsIdent(member.Name())
+		if field == "" {
+			anonymous++
+			field = fmt.Sprintf("anon_%d", anonymous)
+		}
+		typ := r.memberType(r.p.typeOf(member), name+"_"+strings.TrimPrefix(field, "r#"))
+		if typ == "" {
+			typ = "[u8; 0]"
+			lines = append(lines, "// "+member.Name()+": Rust has no type for it")
+		}
+		lines = append(lines, fmt.Sprintf("pub %s: %s,", field, typ))
+	}
+	size, _ := t.Val(dwarf.DW_AT_byte_size)
+	endBitfields(size)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#[repr(C)]\n#[derive(Copy, Clone)]\npub %s %s {\n", kind, name)
+	for _, line := range lines {
+		b.WriteString("    " + line + "\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// memberType returns the Rust type of a member, an anonymous structure,
+// union or enumeration is defined as name
+func (r *rsFile) memberType(t *dwarf.Entry, name string) string {
+	if t != nil && t.Name() == "" {
+		switch t.Tag {
+		case dwarf.DW_TAG_structure_type, dwarf.DW_TAG_class_type, dwarf.DW_TAG_union_type:
+			r.defineRecord(t, name, true)
+			return name
+		case dwarf.DW_TAG_enumeration_type:
+			return r.defineEnum(t, name)
+		}
+	}
+	return r.rsType(t, true)
+}
+
+// defineEnum defines the enumeration t as an integer type name with a constant per enumerator
+func (r *rsFile) defineEnum(t *dwarf.Entry, name string) string {
+	if _, exist := r.index[name]; exist {
+		return name
+	}
+	size, _ := t.Val(dwarf.DW_AT_byte_size)
+	if size == 0 || 8 < size {
+		size = 4
+	}
+	signed := r.p.isSigned(r.p.typeOf(t))
+	typ := fmt.Sprintf("u%d", size*8)
+	if signed {
+		typ = fmt.Sprintf("i%d", size*8)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "pub type %s = %s;", name, typ)
+	for _, enumerator := range t.Children {
+		if enumerator.Tag != dwarf.DW_TAG_enumerator {
+			continue
+		}
+		val, negative := enumValue(enumerator, signed)
+		if negative {
+			fmt.Fprintf(&b, "\npub const %s: %s = %d;", rsIdent(enumerator.Name()), name, int64(val))
+		} else {
+			fmt.Fprintf(&b, "\npub const %s: %s = %d;", rsIdent(enumerator.Name()), name, val)
+		}
+	}
+	r.define(name, b.String())
+	return name
+}
@@ -1,107 +1,122 @@
 package bintutil
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 )
 
-func FromLeToUInt16(buf []byte) (uint16, error) {
-	var val uint16
-	if len(buf) < 2 {
-		return val, errors.New("buf is too short")
+var ErrTooShort = errors.New("buf is too short")
+
+// ParseError reports a structure that could not be read from a binary file
+type ParseError struct {
+	Struct string // name of the structure being read
+	Offset uint64 // file offset of the structure
+	Msg    string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset 0x%x: %s", err.Struct, err.Offset, err.Msg)
+}
+
+func NewParseError(structName string, offset uint64, format string, a ...interface{}) error {
+	return &ParseError{structName, offset, fmt.Sprintf(format, a...)}
+}
+
+// CheckRange returns a ParseError when size bytes at offset are not inside bin
+func CheckRange(bin []byte, structName string, offset uint64, size uint64) error {
+	binSize := uint64(len(bin))
+	if binSize < offset || binSize-offset < size {
+		return NewParseError(structName, offset, "0x%x bytes exceed the end of data (size 0x%x)", size, binSize)
 	}
+	return nil
+}
 
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+// FromLeToUInt16 reads a little endian uint16 at the start of buf
+//
+// Deprecated: use Cursor.ReadU16, which reads the values of a structure in order.
+func FromLeToUInt16(buf []byte) (uint16, error) {
+	c := NewLeCursor("uint16", buf)
+	val := c.ReadU16()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
 
+// FromLeToInt16 reads a little endian int16 at the start of buf
+//
+// Deprecated: use Cursor.ReadS16, which reads the values of a structure in order.
 func FromLeToInt16(buf []byte) (int16, error) {
-	var val int16
-	if len(buf) < 2 {
-		return val, errors.New("buf is too short")
-	}
-
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+	c := NewLeCursor("int16", buf)
+	val := c.ReadS16()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
 
+// FromLeToUInt32 reads a little endian uint32 at the start of buf
+//
+// Deprecated: use Cursor.ReadU32, which reads the values of a structure in order.
 func FromLeToUInt32(buf []byte) (uint32, error) {
-	var val uint32
-	if len(buf) < 4 {
-		return val, errors.New("buf is too short")
-	}
-
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+	c := NewLeCursor("uint32", buf)
+	val := c.ReadU32()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
-func FromLeToInt32(buf []byte) (int32, error) {
-	var val int32
-	if len(buf) < 4 {
-		return val, errors.New("buf is too short")
-	}
 
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+// FromLeToInt32 reads a little endian int32 at the start of buf
+//
+// Deprecated: use Cursor.ReadS32, which reads the values of a structure in order.
+func FromLeToInt32(buf []byte) (int32, error) {
+	c := NewLeCursor("int32", buf)
+	val := c.ReadS32()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
 
+// FromLeToUInt64 reads a little endian uint64 at the start of buf
+//
+// Deprecated: use Cursor.ReadU64, which reads the values of a structure in order.
 func FromLeToUInt64(buf []byte) (uint64, error) {
-	var val uint64
-	if len(buf) < 8 {
-		return val, errors.New("buf is too short")
-	}
-
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+	c := NewLeCursor("uint64", buf)
+	val := c.ReadU64()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
 
+// FromLeToInt64 reads a little endian int64 at the start of buf
+//
+// Deprecated: use Cursor.ReadS64, which reads the values of a structure in order.
 func FromLeToInt64(buf []byte) (int64, error) {
-	var val int64
-	if len(buf) < 8 {
-		return val, errors.New("buf is too short")
-	}
-
-	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &val)
-	if err != nil {
-		return val, err
+	c := NewLeCursor("int64", buf)
+	val := c.ReadS64()
+	if c.Err() != nil {
+		return val, ErrTooShort
 	}
 	return val, nil
 }
 
+// GetString reads a NUL terminated string, the string ends at the end of bin if it is not terminated
 func GetString(bin []byte, offset uint64) string {
-	str := ""
-	for bin[offset] != 0 {
-		str += string(bin[offset])
-		offset++
+	if uint64(len(bin)) <= offset {
+		return ""
 	}
-	return str
+	return string(cString(bin[offset:]))
 }
 func GetCoffString(bin []byte, offset uint64) string {
-	str := ""
-	var cnt uint64 = 0
-	for cnt < 8 {
-		if bin[offset+cnt] == 0 {
-			break
-		}
-		str += string(bin[offset+cnt])
-		cnt++
+	if uint64(len(bin)) <= offset {
+		return ""
 	}
-	return str
+	end := min(offset+8, uint64(len(bin)))
+	return string(cString(bin[offset:end]))
 }
 
 func FromUint32ToLeBytes(val uint32) []byte {
@@ -109,3 +124,9 @@ func FromUint32ToLeBytes(val uint32) []byte {
 	binary.LittleEndian.PutUint32(buf, val)
 	return buf
 }
+
+func FromUint64ToLeBytes(val uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, val)
+	return buf
+}
@@ -0,0 +1,223 @@
+package bintutil
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Cursor reads values from a byte slice in order, advancing its position.
+// The first read that does not fit sets a sticky ParseError: later reads
+// return zero values without moving, so a parser may read a whole structure
+// and check Err once.
+type Cursor struct {
+	bin   []byte
+	pos   uint64
+	order binary.ByteOrder
+	name  string // structure name for errors
+	err   error
+}
+
+func NewCursor(name string, bin []byte, order binary.ByteOrder) *Cursor {
+	return &Cursor{bin: bin, order: order, name: name}
+}
+
+// NewLeCursor returns a little endian Cursor
+func NewLeCursor(name string, bin []byte) *Cursor {
+	return NewCursor(name, bin, binary.LittleEndian)
+}
+
+func (c *Cursor) Pos() uint64 {
+	return c.pos
+}
+
+// Len returns the number of bytes after the position
+func (c *Cursor) Len() uint64 {
+	if uint64(len(c.bin)) <= c.pos {
+		return 0
+	}
+	return uint64(len(c.bin)) - c.pos
+}
+
+func (c *Cursor) Size() uint64 {
+	return uint64(len(c.bin))
+}
+
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+func (c *Cursor) SetName(name string) {
+	c.name = name
+}
+
+// Seek moves to pos, a position past the end is an error
+func (c *Cursor) Seek(pos uint64) {
+	if c.err != nil {
+		return
+	}
+	if uint64(len(c.bin)) < pos {
+		c.fail(pos, 0)
+		return
+	}
+	c.pos = pos
+}
+
+func (c *Cursor) Skip(n uint64) {
+	c.next(n)
+}
+
+func (c *Cursor) fail(pos uint64, n uint64) {
+	c.err = NewParseError(c.name, pos, "0x%x bytes exceed the end of data (size 0x%x)", n, len(c.bin))
+}
+
+// next returns the n bytes at the position and moves past them
+func (c *Cursor) next(n uint64) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if c.Len() < n {
+		c.fail(c.pos, n)
+		return nil
+	}
+	b := c.bin[c.pos : c.pos+n : c.pos+n]
+	c.pos += n
+	return b
+}
+
+func (c *Cursor) ReadU8() uint8 {
+	b := c.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (c *Cursor) ReadU16() uint16 {
+	b := c.next(2)
+	if b == nil {
+		return 0
+	}
+	return c.order.Uint16(b)
+}
+
+func (c *Cursor) ReadU32() uint32 {
+	b := c.next(4)
+	if b == nil {
+		return 0
+	}
+	return c.order.Uint32(b)
+}
+
+func (c *Cursor) ReadU64() uint64 {
+	b := c.next(8)
+	if b == nil {
+		return 0
+	}
+	return c.order.Uint64(b)
+}
+
+func (c *Cursor) ReadS8() int8 {
+	return int8(c.ReadU8())
+}
+
+func (c *Cursor) ReadS16() int16 {
+	return int16(c.ReadU16())
+}
+
+func (c *Cursor) ReadS32() int32 {
+	return int32(c.ReadU32())
+}
+
+func (c *Cursor) ReadS64() int64 {
+	return int64(c.ReadU64())
+}
+
+// ReadUint reads a 1, 2, 4 or 8 byte unsigned value, other sizes are skipped and read as 0
+func (c *Cursor) ReadUint(size int) uint64 {
+	switch size {
+	case 1:
+		return uint64(c.ReadU8())
+	case 2:
+		return uint64(c.ReadU16())
+	case 4:
+		return uint64(c.ReadU32())
+	case 8:
+		return c.ReadU64()
+	}
+	c.Skip(uint64(size))
+	return 0
+}
+
+// ReadUleb128 reads an unsigned LEB128 value, bits past 64 are dropped
+func (c *Cursor) ReadUleb128() uint64 {
+	var val uint64
+	var shift uint
+	start := c.pos
+	for c.err == nil {
+		if c.Len() == 0 {
+			c.fail(start, c.pos-start+1)
+			return 0
+		}
+		b := c.bin[c.pos]
+		c.pos++
+		if shift < 64 {
+			val |= uint64(b&0x7f) << shift
+		}
+		shift += 7
+		if b&0x80 == 0 {
+			return val
+		}
+	}
+	return 0
+}
+
+// ReadSleb128 reads a signed LEB128 value, bits past 64 are dropped
+func (c *Cursor) ReadSleb128() int64 {
+	var val int64
+	var shift uint
+	start := c.pos
+	for c.err == nil {
+		if c.Len() == 0 {
+			c.fail(start, c.pos-start+1)
+			return 0
+		}
+		b := c.bin[c.pos]
+		c.pos++
+		if shift < 64 {
+			val |= int64(b&0x7f) << shift
+		}
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				val |= -1 << shift
+			}
+			return val
+		}
+	}
+	return 0
+}
+
+// ReadCString reads a NUL terminated string and moves past the NUL
+func (c *Cursor) ReadCString() string {
+	if c.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(c.bin[min(c.pos, uint64(len(c.bin))):], 0)
+	if end < 0 {
+		c.fail(c.pos, c.Len()+1)
+		return ""
+	}
+	s := string(c.bin[c.pos : c.pos+uint64(end)])
+	c.pos += uint64(end) + 1
+	return s
+}
+
+// ReadFixedString reads an n byte field holding a string padded with NULs
+func (c *Cursor) ReadFixedString(n uint64) string {
+	return string(cString(c.next(n)))
+}
+
+// ReadBytes returns the next n bytes, the slice shares memory with the cursor's data
+func (c *Cursor) ReadBytes(n uint64) []byte {
+	return c.next(n)
+}
@@ -0,0 +1,78 @@
+package bintutil
+
+import "bytes"
+
+// StrTab is a table of NUL terminated strings such as .strtab or .debug_str.
+// Each string is converted once and cached by its offset, so names referenced
+// by many symbols or DIEs share one string. A nil StrTab is empty, a StrTab
+// is not safe for concurrent use.
+type StrTab struct {
+	bin   []byte
+	cache map[uint64]string
+}
+
+func NewStrTab(bin []byte) *StrTab {
+	return &StrTab{bin: bin, cache: map[uint64]string{}}
+}
+
+// Get returns the string at offset, "" when offset is out of range
+func (tab *StrTab) Get(offset uint64) string {
+	if tab == nil {
+		return ""
+	}
+	str, exist := tab.cache[offset]
+	if exist {
+		return str
+	}
+	str = GetString(tab.bin, offset)
+	tab.cache[offset] = str
+	return str
+}
+
+// Bytes returns the raw table
+func (tab *StrTab) Bytes() []byte {
+	if tab == nil {
+		return nil
+	}
+	return tab.bin
+}
+
+// cString returns bin up to the first NUL, or all of bin if there is none
+func cString(bin []byte) []byte {
+	end := bytes.IndexByte(bin, 0)
+	if end < 0 {
+		return bin
+	}
+	return bin[:end]
+}
+
+// StrTabBuilder builds a string table starting with an empty string,
+// equal strings share one entry
+type StrTabBuilder struct {
+	bin     []byte
+	offsets map[string]uint32
+}
+
+func NewStrTabBuilder() *StrTabBuilder {
+	b := StrTabBuilder{}
+	b.bin = []byte{0}
+	b.offsets = map[string]uint32{"": 0}
+	return &b
+}
+
+// Add returns the offset of str, appending it if it is not in the table yet
+func (b *StrTabBuilder) Add(str string) uint32 {
+	if offset, exist := b.offsets[str]; exist {
+		return offset
+	}
+	offset := uint32(len(b.bin))
+	b.bin = append(b.bin, str...)
+	b.bin = append(b.bin, 0)
+	b.offsets[str] = offset
+	return offset
+}
+
+// Bytes returns the table built so far
+func (b *StrTabBuilder) Bytes() []byte {
+	return b.bin
+}
@@ -0,0 +1,174 @@
+package bintutil
+
+import "encoding/binary"
+
+// Writer is the counterpart of Cursor, it writes values at its position and
+// advances. Writing past the end grows the buffer, writing before the end
+// overwrites the bytes there, so a caller may lay out a file by seeking to
+// the offset of each structure.
+type Writer struct {
+	bin   []byte
+	pos   uint64
+	order binary.ByteOrder
+}
+
+func NewWriter(order binary.ByteOrder) *Writer {
+	return &Writer{order: order}
+}
+
+// NewLeWriter returns a little endian Writer
+func NewLeWriter() *Writer {
+	return NewWriter(binary.LittleEndian)
+}
+
+func (w *Writer) Pos() uint64 {
+	return w.pos
+}
+
+// Len returns the size of the written data
+func (w *Writer) Len() uint64 {
+	return uint64(len(w.bin))
+}
+
+// Bytes returns the written data, the slice shares memory with the writer
+func (w *Writer) Bytes() []byte {
+	return w.bin
+}
+
+// Seek moves to pos, the data is padded with zeroes up to a position past the end
+func (w *Writer) Seek(pos uint64) {
+	w.grow(pos)
+	w.pos = pos
+}
+
+// grow extends the data with zeroes to size bytes
+func (w *Writer) grow(size uint64) {
+	if size <= uint64(len(w.bin)) {
+		return
+	}
+	if size <= uint64(cap(w.bin)) {
+		old := len(w.bin)
+		w.bin = w.bin[:size]
+		clear(w.bin[old:])
+		return
+	}
+	w.bin = append(w.bin, make([]byte, size-uint64(len(w.bin)))...)
+}
+
+// next returns the n bytes at the position to be written and moves past them
+func (w *Writer) next(n uint64) []byte {
+	w.grow(w.pos + n)
+	b := w.bin[w.pos : w.pos+n]
+	w.pos += n
+	return b
+}
+
+func (w *Writer) PutU8(val uint8) {
+	w.next(1)[0] = val
+}
+
+func (w *Writer) PutU16(val uint16) {
+	w.order.PutUint16(w.next(2), val)
+}
+
+func (w *Writer) PutU32(val uint32) {
+	w.order.PutUint32(w.next(4), val)
+}
+
+func (w *Writer) PutU64(val uint64) {
+	w.order.PutUint64(w.next(8), val)
+}
+
+func (w *Writer) PutS8(val int8) {
+	w.PutU8(uint8(val))
+}
+
+func (w *Writer) PutS16(val int16) {
+	w.PutU16(uint16(val))
+}
+
+func (w *Writer) PutS32(val int32) {
+	w.PutU32(uint32(val))
+}
+
+func (w *Writer) PutS64(val int64) {
+	w.PutU64(uint64(val))
+}
+
+// PutUint writes a 1, 2, 4 or 8 byte unsigned value, other sizes are written as zeroes
+func (w *Writer) PutUint(size int, val uint64) {
+	switch size {
+	case 1:
+		w.PutU8(uint8(val))
+	case 2:
+		w.PutU16(uint16(val))
+	case 4:
+		w.PutU32(uint32(val))
+	case 8:
+		w.PutU64(val)
+	default:
+		w.Pad(uint64(size))
+	}
+}
+
+// PutUleb128 writes an unsigned LEB128 value in as few bytes as possible
+func (w *Writer) PutUleb128(val uint64) {
+	for {
+		b := uint8(val & 0x7f)
+		val >>= 7
+		if val == 0 {
+			w.PutU8(b)
+			return
+		}
+		w.PutU8(b | 0x80)
+	}
+}
+
+// PutSleb128 writes a signed LEB128 value in as few bytes as possible
+func (w *Writer) PutSleb128(val int64) {
+	for {
+		b := uint8(val & 0x7f)
+		val >>= 7
+		if (val == 0 && b&0x40 == 0) || (val == -1 && b&0x40 != 0) {
+			w.PutU8(b)
+			return
+		}
+		w.PutU8(b | 0x80)
+	}
+}
+
+func (w *Writer) PutBytes(b []byte) {
+	copy(w.next(uint64(len(b))), b)
+}
+
+// PutCString writes str followed by a NUL
+func (w *Writer) PutCString(str string) {
+	b := w.next(uint64(len(str)) + 1)
+	copy(b, str)
+	b[len(str)] = 0
+}
+
+// PutFixedBytes writes an n byte field, b is truncated or padded with zeroes to fit
+func (w *Writer) PutFixedBytes(b []byte, n uint64) {
+	field := w.next(n)
+	clear(field[copy(field, b):])
+}
+
+// PutFixedString writes an n byte field holding str padded with NULs, like a COFF section name
+func (w *Writer) PutFixedString(str string, n uint64) {
+	field := w.next(n)
+	clear(field[copy(field, str):])
+}
+
+// Pad writes n zeroes
+func (w *Writer) Pad(n uint64) {
+	clear(w.next(n))
+}
+
+// Align pads with zeroes up to a multiple of align, an align of 0 or 1 does nothing
+func (w *Writer) Align(align uint64) {
+	if align <= 1 {
+		return
+	}
+	w.Pad((align - w.pos%align) % align)
+}
@@ -2,9 +2,52 @@ package coff
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	binutil "sym-exposer/binutil"
 	"time"
-	"unsafe"
+)
+
+const (
+	COFF_HEADER_SIZE    = 20
+	SECTION_HEADER_SIZE = 40
+	SYMBOL_SIZE         = 18
+	RELOCATION_SIZE     = 10
+)
+
+// Symbol record field offsets
+const (
+	OFFSET_SYMBOL_VALUE          = 8
+	OFFSET_SYMBOL_SECTION_NUMBER = 12
+	OFFSET_SYMBOL_TYPE           = 14
+	OFFSET_SYMBOL_STORAGE_CLASS  = 16
+	OFFSET_SYMBOL_NUM_AUX        = 17
+)
+
+// Section Number Values
+const (
+	IMAGE_SYM_UNDEFINED = 0
+	IMAGE_SYM_ABSOLUTE  = -1
+	IMAGE_SYM_DEBUG     = -2
+)
+
+// Storage Class
+const (
+	IMAGE_SYM_CLASS_EXTERNAL      = 2
+	IMAGE_SYM_CLASS_STATIC        = 3
+	IMAGE_SYM_CLASS_LABEL         = 6
+	IMAGE_SYM_CLASS_FUNCTION      = 101
+	IMAGE_SYM_CLASS_FILE          = 103
+	IMAGE_SYM_CLASS_SECTION       = 104
+	IMAGE_SYM_CLASS_WEAK_EXTERNAL = 105
+)
+
+// Type Representation(MSB)
+const (
+	IMAGE_SYM_DTYPE_NULL     = 0
+	IMAGE_SYM_DTYPE_POINTER  = 1
+	IMAGE_SYM_DTYPE_FUNCTION = 2
+	IMAGE_SYM_DTYPE_ARRAY    = 3
 )
 
 type COFFHeader struct {
@@ -30,86 +73,200 @@ type SectionHeader struct {
 	Characteristics      uint32
 }
 
+type Symbol struct {
+	Name               string
+	Value              uint32
+	SectionNumber      int16
+	Type               uint16
+	StorageClass       uint8
+	NumberOfAuxSymbols uint8
+	Index              uint32 // index in the symbol table, aux records included
+}
+
+type Relocation struct {
+	VirtualAddress   uint32
+	SymbolTableIndex uint32
+	Type             uint16
+}
+
+type CoffObject struct {
+	Path           string
+	Bin            []byte
+	CoffHdr        COFFHeader
+	SecHdrs        []SectionHeader
+	Symbols        []Symbol
+	SymbolIndexMap map[uint32]int
+	strtbl         *binutil.StrTab
+}
+
 func IsCoffX64(bin []byte) bool {
-	return (bin[0] == 0x64 && bin[1] == 0x86)
+	return 2 <= len(bin) && bin[0] == 0x64 && bin[1] == 0x86
 }
 
 func ParseCoffHeader(bin []byte) (COFFHeader, error) {
-	offset := 0
 	var coffHdr = COFFHeader{}
-	var err error = nil
-	coffHdr.Machine, err = binutil.FromLeToUInt16(bin[offset:])
-	if err != nil {
-		return coffHdr, err
+	cur := binutil.NewLeCursor("COFFHeader", bin)
+	coffHdr.Machine = cur.ReadU16()
+	coffHdr.NumberOfSections = cur.ReadU16()
+	coffHdr.TimeDateStamp = cur.ReadU32()
+	coffHdr.PointerToSymbolTable = cur.ReadU32()
+	coffHdr.NumberOfSymbols = cur.ReadU32()
+	coffHdr.SizeOfOptionalHeader = cur.ReadU16()
+	coffHdr.Characteristics = cur.ReadU16()
+	return coffHdr, cur.Err()
+}
+
+func ParseSections(bin []byte, coffHdr *COFFHeader) ([]SectionHeader, error) {
+	secHdrs := []SectionHeader{}
+	offset := uint64(COFF_HEADER_SIZE) + uint64(coffHdr.SizeOfOptionalHeader)
+	for i := 0; i < int(coffHdr.NumberOfSections); i++ {
+		err := binutil.CheckRange(bin, "SectionHeader", offset, SECTION_HEADER_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		secHdr := parseSection(bin[offset:])
+		offset += SECTION_HEADER_SIZE
+		secHdrs = append(secHdrs, secHdr)
 	}
+	return secHdrs, nil
+}
 
-	offset += 2
-	coffHdr.NumberOfSections, _ = binutil.FromLeToUInt16(bin[offset:])
+// parseSection reads a section header, the caller checks that bin holds SECTION_HEADER_SIZE bytes
+func parseSection(bin []byte) SectionHeader {
+	var secHdr SectionHeader
+	cur := binutil.NewLeCursor("SectionHeader", bin)
+	secHdr.Name = cur.ReadFixedString(8)
+	secHdr.VirtualSize = cur.ReadU32()
+	secHdr.VirtualAddress = cur.ReadU32()
+	secHdr.SizeOfRawData = cur.ReadU32()
+	secHdr.PointerToRawData = cur.ReadU32()
+	secHdr.PointerToRelocations = cur.ReadU32()
+	secHdr.PointerToLineNumbers = cur.ReadU32()
+	secHdr.NumberOfRelocations = cur.ReadU16()
+	secHdr.NumberOfLineNumbers = cur.ReadU16()
+	secHdr.Characteristics = cur.ReadU32()
+	return secHdr
+}
+
+func NewCoff(path string, bin []byte) (*CoffObject, error) {
+	coffObj := CoffObject{}
+	coffHdr, err := ParseCoffHeader(bin)
 	if err != nil {
-		return coffHdr, err
+		return nil, err
 	}
-
-	offset += 2
-	coffHdr.TimeDateStamp, _ = binutil.FromLeToUInt32(bin[offset:])
+	coffObj.Path = path
+	coffObj.Bin = bin
+	coffObj.CoffHdr = coffHdr
+	coffObj.SecHdrs, err = ParseSections(bin, &coffHdr)
 	if err != nil {
-		return coffHdr, err
+		return nil, err
+	}
+	for i, secHdr := range coffObj.SecHdrs {
+		if secHdr.PointerToRawData != 0 && 0 < secHdr.SizeOfRawData {
+			err = binutil.CheckRange(bin, fmt.Sprintf("section [%d] data", i+1), uint64(secHdr.PointerToRawData), uint64(secHdr.SizeOfRawData))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if 0 < secHdr.NumberOfRelocations {
+			relocsSize := uint64(secHdr.NumberOfRelocations) * RELOCATION_SIZE
+			err = binutil.CheckRange(bin, fmt.Sprintf("section [%d] relocations", i+1), uint64(secHdr.PointerToRelocations), relocsSize)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	offset += 4
-	coffHdr.PointerToSymbolTable, _ = binutil.FromLeToUInt32(bin[offset:])
-	if err != nil {
-		return coffHdr, err
+	// string table follows the symbol table, first 4 bytes are its size
+	symTblEnd := uint64(coffHdr.PointerToSymbolTable) + uint64(coffHdr.NumberOfSymbols)*SYMBOL_SIZE
+	if coffHdr.PointerToSymbolTable != 0 {
+		err = binutil.CheckRange(bin, "symbol table", uint64(coffHdr.PointerToSymbolTable), uint64(coffHdr.NumberOfSymbols)*SYMBOL_SIZE)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if coffHdr.PointerToSymbolTable != 0 && symTblEnd+4 <= uint64(len(bin)) {
+		strTblSize := binutil.NewLeCursor("string table", bin[symTblEnd:]).ReadU32()
+		strTblEnd := symTblEnd + uint64(strTblSize)
+		if strTblEnd <= uint64(len(bin)) {
+			coffObj.strtbl = binutil.NewStrTab(bin[symTblEnd:strTblEnd])
+		}
 	}
 
-	offset += 4
-	coffHdr.NumberOfSymbols, _ = binutil.FromLeToUInt32(bin[offset:])
-	if err != nil {
-		return coffHdr, err
+	for i, secHdr := range coffObj.SecHdrs {
+		// long section name "/<offset in string table>"
+		if strings.HasPrefix(secHdr.Name, "/") {
+			strOffset, err := strconv.ParseUint(secHdr.Name[1:], 10, 32)
+			if err == nil {
+				coffObj.SecHdrs[i].Name = coffObj.getString(strOffset)
+			}
+		}
 	}
 
-	offset += 2
-	coffHdr.SizeOfOptionalHeader, _ = binutil.FromLeToUInt16(bin[offset:])
-	if err != nil {
-		return coffHdr, err
+	coffObj.Symbols = []Symbol{}
+	coffObj.SymbolIndexMap = map[uint32]int{}
+	var idx uint32 = 0
+	for coffHdr.PointerToSymbolTable != 0 && idx < coffHdr.NumberOfSymbols {
+		offset := uint64(coffHdr.PointerToSymbolTable) + uint64(idx)*SYMBOL_SIZE
+		sym := coffObj.parseSymbol(bin[offset:])
+		sym.Index = idx
+		coffObj.SymbolIndexMap[idx] = len(coffObj.Symbols)
+		coffObj.Symbols = append(coffObj.Symbols, sym)
+		idx += 1 + uint32(sym.NumberOfAuxSymbols)
 	}
+	return &coffObj, nil
+}
 
-	offset += 2
-	coffHdr.Characteristics, _ = binutil.FromLeToUInt16(bin[offset:])
-	if err != nil {
-		return coffHdr, err
+// parseSymbol reads a symbol record, NewCoff checks that the whole symbol table is in the file
+func (coffObj *CoffObject) parseSymbol(bin []byte) Symbol {
+	sym := Symbol{}
+	cur := binutil.NewLeCursor("Symbol", bin)
+	zeroes := cur.ReadU32()
+	if zeroes == 0 {
+		strOffset := cur.ReadU32()
+		sym.Name = coffObj.getString(uint64(strOffset))
+	} else {
+		sym.Name = binutil.GetCoffString(bin, 0)
+		cur.Skip(4)
 	}
-	return coffHdr, err
+	sym.Value = cur.ReadU32()
+	sym.SectionNumber = cur.ReadS16()
+	sym.Type = cur.ReadU16()
+	sym.StorageClass = cur.ReadU8()
+	sym.NumberOfAuxSymbols = cur.ReadU8()
+	return sym
 }
 
-func ParseSections(bin []byte, coffHdr *COFFHeader) []SectionHeader {
-	secHdrs := []SectionHeader{}
-	offset := unsafe.Sizeof(COFFHeader{})
-	for i := 0; i < int(coffHdr.NumberOfSections); i++ {
-		secHdr := parseSection(bin[offset:])
-		offset += unsafe.Sizeof(secHdr)
-		secHdr.Show()
-		secHdrs = append(secHdrs, secHdr)
+func (coffObj *CoffObject) getString(offset uint64) string {
+	return coffObj.strtbl.Get(offset)
+}
+
+func (coffObj *CoffObject) GetRelocations(secIdx int) []Relocation {
+	secHdr := coffObj.SecHdrs[secIdx]
+	relocs := []Relocation{}
+	cur := binutil.NewLeCursor("Relocation", coffObj.Bin)
+	cur.Seek(uint64(secHdr.PointerToRelocations))
+	for i := 0; i < int(secHdr.NumberOfRelocations); i++ {
+		reloc := Relocation{}
+		reloc.VirtualAddress = cur.ReadU32()
+		reloc.SymbolTableIndex = cur.ReadU32()
+		reloc.Type = cur.ReadU16()
+		relocs = append(relocs, reloc)
 	}
-	return secHdrs
+	return relocs
 }
 
-func parseSection(bin []byte) SectionHeader {
-	var secHdr SectionHeader
-	var offset uint64 = 0
-	/*
-		Name                 [8]byte
-		VirtualSize          uint32
-		VirtualAddress       uint32
-		SizeOfRawData        uint32
-		PointerToRawData     uint32
-		PointerToRelocations uint32
-		PointerToLineNumbers uint32
-		NumberOfRelocations  uint16
-		NumberOfLineNumbers  uint16
-		Characteristics      uint32
-	*/
-	secHdr.Name = binutil.GetCoffString(bin, offset)
-	return secHdr
+// GetSymbolByIndex looks up a symbol by its raw symbol table index
+func (coffObj *CoffObject) GetSymbolByIndex(idx uint32) *Symbol {
+	i, exist := coffObj.SymbolIndexMap[idx]
+	if !exist {
+		return nil
+	}
+	return &coffObj.Symbols[i]
+}
+
+func (sym *Symbol) IsFunction() bool {
+	return (sym.Type>>4)&0x0F == IMAGE_SYM_DTYPE_FUNCTION
 }
 
 func (coffHdr *COFFHeader) Show() {
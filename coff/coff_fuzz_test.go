@@ -0,0 +1,32 @@
+package coff
+
+import (
+	"os"
+	"testing"
+)
+
+func FuzzParseCoffHeader(f *testing.F) {
+	bin, err := os.ReadFile("../examples/func.obj")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(bin)
+	f.Add(bin[:COFF_HEADER_SIZE])
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		coffHdr, err := ParseCoffHeader(bin)
+		if err != nil {
+			return
+		}
+		ParseSections(bin, &coffHdr)
+
+		coffObj, err := NewCoff("fuzz", bin)
+		if err != nil {
+			return
+		}
+		for i := range coffObj.SecHdrs {
+			for _, reloc := range coffObj.GetRelocations(i) {
+				coffObj.GetSymbolByIndex(reloc.SymbolTableIndex)
+			}
+		}
+	})
+}
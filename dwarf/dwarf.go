@@ -1,13 +1,13 @@
 package dwarf
 
 import (
-	binutil "covme/binutil"
-	elf "covme/elf"
-	logger "covme/logger"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
-	"unsafe"
+	binutil "sym-exposer/binutil"
+	elf "sym-exposer/elf"
+	logger "sym-exposer/logger"
 )
 
 // DWARF format(size)
@@ -91,9 +91,23 @@ const (
 	DW_TAG_type_unit                = 0x41
 	DW_TAG_rvalue_reference_type    = 0x42
 	DW_TAG_template_alias           = 0x43
+	DW_TAG_coarray_type             = 0x44 // DWARF5～
+	DW_TAG_generic_subrange         = 0x45
+	DW_TAG_dynamic_type             = 0x46
+	DW_TAG_atomic_type              = 0x47
+	DW_TAG_call_site                = 0x48
+	DW_TAG_call_site_parameter      = 0x49
+	DW_TAG_skeleton_unit            = 0x4a
+	DW_TAG_immutable_type           = 0x4b
 
 	DW_TAG_lo_user = 0x4080
 	DW_TAG_hi_user = 0xffff
+
+	// GNU extensions
+	DW_TAG_GNU_template_parameter_pack = 0x4107
+	DW_TAG_GNU_formal_parameter_pack   = 0x4108
+	DW_TAG_GNU_call_site               = 0x4109
+	DW_TAG_GNU_call_site_parameter     = 0x410a
 )
 
 // ============================================================================
@@ -194,6 +208,37 @@ const (
 	DW_AT_linkage_name         = 0x6e   // string
 	DW_AT_lo_user              = 0x2000 // ---
 
+	// DWARF5～
+	DW_AT_string_length_bit_size  = 0x6f // constant
+	DW_AT_string_length_byte_size = 0x70 // constant
+	DW_AT_rank                    = 0x71 // constant, exprloc
+	DW_AT_str_offsets_base        = 0x72 // stroffsetsptr
+	DW_AT_addr_base               = 0x73 // addrptr
+	DW_AT_rnglists_base           = 0x74 // rnglistsptr
+	DW_AT_dwo_name                = 0x76 // string
+	DW_AT_reference               = 0x77 // flag
+	DW_AT_rvalue_reference        = 0x78 // flag
+	DW_AT_macros                  = 0x79 // macptr
+	DW_AT_call_all_calls          = 0x7a // flag
+	DW_AT_call_all_source_calls   = 0x7b // flag
+	DW_AT_call_all_tail_calls     = 0x7c // flag
+	DW_AT_call_return_pc          = 0x7d // address
+	DW_AT_call_value              = 0x7e // exprloc
+	DW_AT_call_origin             = 0x7f // reference
+	DW_AT_call_parameter          = 0x80 // reference
+	DW_AT_call_pc                 = 0x81 // address
+	DW_AT_call_tail_call          = 0x82 // flag
+	DW_AT_call_target             = 0x83 // exprloc
+	DW_AT_call_target_clobbered   = 0x84 // exprloc
+	DW_AT_call_data_location      = 0x85 // exprloc
+	DW_AT_call_data_value         = 0x86 // exprloc
+	DW_AT_noreturn                = 0x87 // flag
+	DW_AT_alignment               = 0x88 // constant
+	DW_AT_export_symbols          = 0x89 // flag
+	DW_AT_deleted                 = 0x8a // flag
+	DW_AT_defaulted               = 0x8b // constant
+	DW_AT_loclists_base           = 0x8c // loclistsptr
+
 	// see https://sourceware.org/elfutils/DwarfExtensions
 	DW_AT_MIPS_linkage_name = 0x2007
 	// GNU Extensions
@@ -276,6 +321,12 @@ const (
 	DW_FORM_addrx2         = 0x2a // address
 	DW_FORM_addrx3         = 0x2b // address
 	DW_FORM_addrx4         = 0x2c // address
+
+	// GNU extensions
+	DW_FORM_GNU_addr_index = 0x1f01 // address, split DWARF 4
+	DW_FORM_GNU_str_index  = 0x1f02 // string, split DWARF 4
+	DW_FORM_GNU_ref_alt    = 0x1f20 // reference into the .gnu_debugaltlink file
+	DW_FORM_GNU_strp_alt   = 0x1f21 // string in the .gnu_debugaltlink file
 )
 
 var TagNameMap = map[uint64]string{
@@ -339,8 +390,21 @@ var TagNameMap = map[uint64]string{
 	DW_TAG_type_unit:                "DW_TAG_type_unit",
 	DW_TAG_rvalue_reference_type:    "DW_TAG_rvalue_reference_type",
 	DW_TAG_template_alias:           "DW_TAG_template_alias",
+	DW_TAG_coarray_type:             "DW_TAG_coarray_type",
+	DW_TAG_generic_subrange:         "DW_TAG_generic_subrange",
+	DW_TAG_dynamic_type:             "DW_TAG_dynamic_type",
+	DW_TAG_atomic_type:              "DW_TAG_atomic_type",
+	DW_TAG_call_site:                "DW_TAG_call_site",
+	DW_TAG_call_site_parameter:      "DW_TAG_call_site_parameter",
+	DW_TAG_skeleton_unit:            "DW_TAG_skeleton_unit",
+	DW_TAG_immutable_type:           "DW_TAG_immutable_type",
 	DW_TAG_lo_user:                  "TAG_lo_user",
 	DW_TAG_hi_user:                  "TAG_hi_user",
+
+	DW_TAG_GNU_template_parameter_pack: "DW_TAG_GNU_template_parameter_pack",
+	DW_TAG_GNU_formal_parameter_pack:   "DW_TAG_GNU_formal_parameter_pack",
+	DW_TAG_GNU_call_site:               "DW_TAG_GNU_call_site",
+	DW_TAG_GNU_call_site_parameter:     "DW_TAG_GNU_call_site_parameter",
 }
 
 var AttrNameMap = map[uint64]string{
@@ -438,6 +502,36 @@ var AttrNameMap = map[uint64]string{
 	DW_AT_linkage_name:         "DW_AT_linkage_name",
 	DW_AT_lo_user:              "DW_AT_lo_user",
 	DW_AT_hi_user:              "DW_AT_hi_user",
+
+	DW_AT_string_length_bit_size:  "DW_AT_string_length_bit_size",
+	DW_AT_string_length_byte_size: "DW_AT_string_length_byte_size",
+	DW_AT_rank:                    "DW_AT_rank",
+	DW_AT_str_offsets_base:        "DW_AT_str_offsets_base",
+	DW_AT_addr_base:               "DW_AT_addr_base",
+	DW_AT_rnglists_base:           "DW_AT_rnglists_base",
+	DW_AT_dwo_name:                "DW_AT_dwo_name",
+	DW_AT_reference:               "DW_AT_reference",
+	DW_AT_rvalue_reference:        "DW_AT_rvalue_reference",
+	DW_AT_macros:                  "DW_AT_macros",
+	DW_AT_call_all_calls:          "DW_AT_call_all_calls",
+	DW_AT_call_all_source_calls:   "DW_AT_call_all_source_calls",
+	DW_AT_call_all_tail_calls:     "DW_AT_call_all_tail_calls",
+	DW_AT_call_return_pc:          "DW_AT_call_return_pc",
+	DW_AT_call_value:              "DW_AT_call_value",
+	DW_AT_call_origin:             "DW_AT_call_origin",
+	DW_AT_call_parameter:          "DW_AT_call_parameter",
+	DW_AT_call_pc:                 "DW_AT_call_pc",
+	DW_AT_call_tail_call:          "DW_AT_call_tail_call",
+	DW_AT_call_target:             "DW_AT_call_target",
+	DW_AT_call_target_clobbered:   "DW_AT_call_target_clobbered",
+	DW_AT_call_data_location:      "DW_AT_call_data_location",
+	DW_AT_call_data_value:         "DW_AT_call_data_value",
+	DW_AT_noreturn:                "DW_AT_noreturn",
+	DW_AT_alignment:               "DW_AT_alignment",
+	DW_AT_export_symbols:          "DW_AT_export_symbols",
+	DW_AT_deleted:                 "DW_AT_deleted",
+	DW_AT_defaulted:               "DW_AT_defaulted",
+	DW_AT_loclists_base:           "DW_AT_loclists_base",
 }
 
 var FormNameMap = map[uint64]string{
@@ -466,6 +560,30 @@ var FormNameMap = map[uint64]string{
 	DW_FORM_exprloc:      "DW_FORM_exprloc",
 	DW_FORM_flag_present: "DW_FORM_flag_present",
 	DW_FORM_ref_sig8:     "DW_FORM_ref_sig8",
+
+	DW_FORM_strx:           "DW_FORM_strx",
+	DW_FORM_addrx:          "DW_FORM_addrx",
+	DW_FORM_ref_sup4:       "DW_FORM_ref_sup4",
+	DW_FORM_strp_sup:       "DW_FORM_strp_sup",
+	DW_FORM_data16:         "DW_FORM_data16",
+	DW_FORM_line_strp:      "DW_FORM_line_strp",
+	DW_FORM_implicit_const: "DW_FORM_implicit_const",
+	DW_FORM_loclistx:       "DW_FORM_loclistx",
+	DW_FORM_rnglistx:       "DW_FORM_rnglistx",
+	DW_FORM_ref_sup8:       "DW_FORM_ref_sup8",
+	DW_FORM_strx1:          "DW_FORM_strx1",
+	DW_FORM_strx2:          "DW_FORM_strx2",
+	DW_FORM_strx3:          "DW_FORM_strx3",
+	DW_FORM_strx4:          "DW_FORM_strx4",
+	DW_FORM_addrx1:         "DW_FORM_addrx1",
+	DW_FORM_addrx2:         "DW_FORM_addrx2",
+	DW_FORM_addrx3:         "DW_FORM_addrx3",
+	DW_FORM_addrx4:         "DW_FORM_addrx4",
+
+	DW_FORM_GNU_addr_index: "DW_FORM_GNU_addr_index",
+	DW_FORM_GNU_str_index:  "DW_FORM_GNU_str_index",
+	DW_FORM_GNU_ref_alt:    "DW_FORM_GNU_ref_alt",
+	DW_FORM_GNU_strp_alt:   "DW_FORM_GNU_strp_alt",
 }
 
 const (
@@ -631,6 +749,8 @@ const (
 	DW_OP_bit_piece           = 0x9d
 	DW_OP_implicit_value      = 0x9e
 	DW_OP_stack_value         = 0x9f
+	DW_OP_addrx               = 0xa1 // DWARF5～
+	DW_OP_constx              = 0xa2 // DWARF5～
 	DW_OP_lo_user             = 0xe0
 	DW_OP_hi_user             = 0xff
 )
@@ -837,6 +957,8 @@ var OpNameMap = map[byte]string{
 	DW_OP_bit_piece:           "DW_OP_bit_piece",
 	DW_OP_implicit_value:      "DW_OP_implicit_value",
 	DW_OP_stack_value:         "DW_OP_stack_value",
+	DW_OP_addrx:               "DW_OP_addrx",
+	DW_OP_constx:              "DW_OP_constx",
 	DW_OP_lo_user:             "DW_OP_lo_user",
 	DW_OP_hi_user:             "DW_OP_hi_user",
 }
@@ -911,6 +1033,8 @@ var OpSizeMap = map[byte]int{
 	DW_OP_bit_piece:           2,
 	DW_OP_implicit_value:      2,
 	DW_OP_stack_value:         0,
+	DW_OP_addrx:               1,
+	DW_OP_constx:              1,
 	DW_OP_lo_user:             0,
 	DW_OP_hi_user:             0,
 }
@@ -1001,6 +1125,31 @@ var CFANameMap = map[byte]string{
 	DW_CFA_GNU_negative_offset_extended: "DW_CFA_GNU_negative_offset_extended",
 }
 
+// DWARF5 P225 Table 7.11
+// Base type encoding values
+const (
+	DW_ATE_address         = 0x01
+	DW_ATE_boolean         = 0x02
+	DW_ATE_complex_float   = 0x03
+	DW_ATE_float           = 0x04
+	DW_ATE_signed          = 0x05
+	DW_ATE_signed_char     = 0x06
+	DW_ATE_unsigned        = 0x07
+	DW_ATE_unsigned_char   = 0x08
+	DW_ATE_imaginary_float = 0x09
+	DW_ATE_packed_decimal  = 0x0a
+	DW_ATE_numeric_string  = 0x0b
+	DW_ATE_edited          = 0x0c
+	DW_ATE_signed_fixed    = 0x0d
+	DW_ATE_unsigned_fixed  = 0x0e
+	DW_ATE_decimal_float   = 0x0f
+	DW_ATE_UTF             = 0x10
+	DW_ATE_UCS             = 0x11
+	DW_ATE_ASCII           = 0x12
+	DW_ATE_lo_user         = 0x80
+	DW_ATE_hi_user         = 0xff
+)
+
 // Language name        Value       Default Lower Bound
 const (
 	DW_LANG_C89            = 0x0001 // 0
@@ -1171,12 +1320,38 @@ type Dwarf32LineInfoHdr struct {
 	Files                     []FileNameInfo
 }
 
+// FileName returns the name of file number idx of DW_AT_decl_file, "" when it is out of range.
+// DWARF 5 numbers the files from 0, earlier versions from 1.
+func (hdr Dwarf32LineInfoHdr) FileName(idx uint64) string {
+	if hdr.Version < 5 {
+		if idx == 0 {
+			return ""
+		}
+		idx--
+	}
+	if uint64(len(hdr.Files)) <= idx {
+		return ""
+	}
+	return hdr.Files[idx].Name
+}
+
 type Dwarf32FuncInfo struct {
 	SrcFilePath string
 	Name        string
 	LinkageName string
 	Addr        uint64
 	Size        uint32
+	DeclFile    string // DW_AT_decl_file as named in the line table
+	DeclLine    uint64
+	External    bool // false for static functions
+}
+
+// SymbolName returns the name of the function in the symbol table
+func (f Dwarf32FuncInfo) SymbolName() string {
+	if f.LinkageName != "" {
+		return f.LinkageName
+	}
+	return f.Name
 }
 
 type Dwarf32CuDebugInfo struct {
@@ -1184,7 +1359,65 @@ type Dwarf32CuDebugInfo struct {
 	Producer   string
 	Language   string
 	CompileDir string
-	Funcs      map[uint64]Dwarf32FuncInfo
+	// by DIE offset, the functions of a relocatable object may share an address.
+	// It was Funcs keyed by address, FuncsAt looks functions up that way.
+	FuncsByOffset map[uint64]Dwarf32FuncInfo
+}
+
+// FuncsAt returns the functions of the unit at addr in DIE order
+func (d *Dwarf32CuDebugInfo) FuncsAt(addr uint64) []Dwarf32FuncInfo {
+	offsets := []uint64{}
+	for offset, dbgFunc := range d.FuncsByOffset {
+		if dbgFunc.Addr == addr {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	funcs := []Dwarf32FuncInfo{}
+	for _, offset := range offsets {
+		funcs = append(funcs, d.FuncsByOffset[offset])
+	}
+	return funcs
+}
+
+// FindFunc returns the DIE offset and the DWARF function of the symbol f. Static
+// functions of different units may share a name, so the one at the address of f
+// is preferred. gcc gives C++ functions with internal linkage no DW_AT_linkage_name,
+// those are matched by address and size when no other function has them.
+func FindFunc(cuInfos []Dwarf32CuDebugInfo, f elf.ElfFunctionInfo) (uint64, Dwarf32FuncInfo, bool) {
+	var candidateOffset uint64
+	candidate, found := Dwarf32FuncInfo{}, false
+	atAddrOffsets := []uint64{}
+	atAddr := []Dwarf32FuncInfo{}
+	for _, cuInfo := range cuInfos {
+		// in DIE order, the first of several candidates is kept
+		offsets := []uint64{}
+		for offset := range cuInfo.FuncsByOffset {
+			offsets = append(offsets, offset)
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		for _, offset := range offsets {
+			dbgFunc := cuInfo.FuncsByOffset[offset]
+			sameRange := dbgFunc.Addr == f.Addr && uint64(dbgFunc.Size) == f.Size
+			if dbgFunc.SymbolName() != f.Name {
+				if sameRange && dbgFunc.LinkageName == "" {
+					atAddrOffsets = append(atAddrOffsets, offset)
+					atAddr = append(atAddr, dbgFunc)
+				}
+				continue
+			}
+			if dbgFunc.Addr == f.Addr {
+				return offset, dbgFunc, true
+			}
+			if !found {
+				candidateOffset, candidate, found = offset, dbgFunc, true
+			}
+		}
+	}
+	if !found && len(atAddr) == 1 {
+		return atAddrOffsets[0], atAddr[0], true
+	}
+	return candidateOffset, candidate, found
 }
 
 func (d *Dwarf32CuDebugInfo) IsRust() bool {
@@ -1196,60 +1429,98 @@ func (d *Dwarf32CuDebugInfo) FilePath() string {
 	return srcFilePath
 }
 
-func ReadAranges(bin []byte) map[uint32]Dwarf32ArangeInfo {
-	dbgArangesLen := uint64(len(bin))
-	var offset uint64 = 0
+// parseFail aborts the current parse, the exported Read functions return it as their error
+func parseFail(structName string, offset uint64, format string, a ...interface{}) {
+	panic(binutil.NewParseError(structName, offset, format, a...))
+}
+
+// recoverParseError turns the ParseError a parse aborted with by panic into its error,
+// other panics are bugs and are raised again
+func recoverParseError(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	parseErr, ok := r.(*binutil.ParseError)
+	if !ok {
+		panic(r)
+	}
+	*err = parseErr
+}
+
+// checkCursor aborts the current parse when a read through cur ran past the end of its data
+func checkCursor(cur *binutil.Cursor) {
+	if cur.Err() != nil {
+		panic(cur.Err())
+	}
+}
+
+// readInitialLength reads unit_length, a value of 0xffffff00 or more selects the 64-bit DWARF format.
+// It returns the length, the DWARF format and the offset of the end of the unit.
+func readInitialLength(cur *binutil.Cursor, structName string) (uint64, uint8, uint64) {
+	unitTop := cur.Pos()
+	var unitLength uint64 = 0
+	var dwarfFormat uint8 = DWARF_32BIT_FORMAT
+	tmp := cur.ReadU32()
+	if tmp < 0xffffff00 {
+		unitLength = uint64(tmp)
+	} else {
+		unitLength = cur.ReadU64()
+		dwarfFormat = DWARF_64BIT_FORMAT
+	}
+	checkCursor(cur)
+	unitEnd := cur.Pos() + unitLength
+	if unitEnd < cur.Pos() {
+		parseFail(structName, unitTop, "unit_length 0x%x overflows", unitLength)
+	}
+	return unitLength, dwarfFormat, unitEnd
+}
+
+// readSecOffset reads a section offset, 4 bytes in the 32-bit DWARF format and 8 bytes in the 64-bit format
+func readSecOffset(cur *binutil.Cursor, dwarfFormat uint8) uint64 {
+	if dwarfFormat == DWARF_32BIT_FORMAT {
+		return uint64(cur.ReadU32())
+	}
+	return cur.ReadU64()
+}
+
+func ReadAranges(bin []byte) (arangeInfos map[uint32]Dwarf32ArangeInfo, err error) {
+	cur := binutil.NewLeCursor(".debug_aranges", bin)
 	var headerTop uint64 = 0
+	defer recoverParseError(&err)
 
-	var arangeInfos = map[uint32]Dwarf32ArangeInfo{}
-	for offset < dbgArangesLen {
-		headerTop = offset
-		var nextHdrTop uint64 = 0
-		var hdrSize uint64 = 0
+	arangeInfos = map[uint32]Dwarf32ArangeInfo{}
+	for 0 < cur.Len() {
+		headerTop = cur.Pos()
 		arangeInfo := Dwarf32ArangeInfo{}
 		arangeInfoHdr := Dwarf32ArangeInfoHdr{}
 
-		// TODO 4 or 8
 		// unit_length initial length(4 or 8 bytes)
-		tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-		offset += 4
-		hdrSize += 4
-		if tmp < 0xFFFFFF00 {
-			// 32-bit DWARF Format
-			arangeInfoHdr.UnitLength = uint64(tmp)
-			arangeInfoHdr.DwarfFormat = DWARF_32BIT_FORMAT
-		} else {
-			// 64-bit DWARF Format
-			arangeInfoHdr.UnitLength, _ = binutil.FromLeToUInt64(bin[offset:])
-			arangeInfoHdr.DwarfFormat = DWARF_64BIT_FORMAT
-			offset += 8
-			hdrSize += 8
-		}
-		nextHdrTop = headerTop + hdrSize + arangeInfoHdr.UnitLength
+		var nextHdrTop uint64 = 0
+		arangeInfoHdr.UnitLength, arangeInfoHdr.DwarfFormat, nextHdrTop = readInitialLength(cur, ".debug_aranges")
 
 		// version uhalf
-		arangeInfoHdr.Version, _ = binutil.FromLeToUInt16(bin[offset:])
-		offset += 2
+		arangeInfoHdr.Version = cur.ReadU16()
 
 		// header_length 32bit-DWARF/64bit-DWARF
-		arangeInfoHdr.DebugInfoOffset, _ = binutil.FromLeToUInt32(bin[offset:])
-		offset += 4
+		arangeInfoHdr.DebugInfoOffset = cur.ReadU32()
 
 		// address_size ubyte
 		// The size of an address in bytes on the target architecture.
-		arangeInfoHdr.AddressSize = bin[offset]
-		offset += 1
+		arangeInfoHdr.AddressSize = cur.ReadU8()
 
 		// segment_size ubyte
 		// The size of a segment selector in bytes on the target architecture.
 		// If the target system uses a flat address space, this value is 0.
-		arangeInfoHdr.SegmentSize = bin[offset]
-		offset += 1
+		arangeInfoHdr.SegmentSize = cur.ReadU8()
+		checkCursor(cur)
 
 		// header size must be devided by (AddressSize x 2)
 		alighnmentSize := uint64(arangeInfoHdr.AddressSize)
-		paddingSize := alighnmentSize - (offset-headerTop)%alighnmentSize
-		offset += paddingSize
+		if alighnmentSize == 0 {
+			parseFail(".debug_aranges", headerTop, "address_size is 0")
+		}
+		cur.Skip(alighnmentSize - (cur.Pos()-headerTop)%alighnmentSize)
 
 		arangeInfo.Header = arangeInfoHdr
 		arangeInfo.Segments = []Dwarf32SegmentInfo{}
@@ -1259,38 +1530,25 @@ func ReadAranges(bin []byte) map[uint32]Dwarf32ArangeInfo {
 		for {
 			seg := Dwarf32SegmentInfo{}
 			if arangeInfoHdr.AddressSize == 8 {
-				address, _ := binutil.FromLeToUInt64(bin[offset:])
-				offset += 8
-				length, _ := binutil.FromLeToUInt64(bin[offset:])
-				offset += 8
-				logger.TLog("address:0x%x, length: 0x%x\n", address, length)
-				if address == 0 && length == 0 {
-					break
-				}
-				seg.Address = address
-				seg.Length = length
+				seg.Address = cur.ReadU64()
+				seg.Length = cur.ReadU64()
 			} else {
 				// TODO 32bit address
-				address, _ := binutil.FromLeToUInt32(bin[offset:])
-				offset += 4
-				length, _ := binutil.FromLeToUInt32(bin[offset:])
-				offset += 4
-
-				logger.TLog("address:0x%x, length: 0x%x\n", address, length)
-				if address == 0 && length == 0 {
-					break
-				}
-				seg.Address = uint64(address)
-				seg.Length = uint64(length)
+				seg.Address = uint64(cur.ReadU32())
+				seg.Length = uint64(cur.ReadU32())
+			}
+			checkCursor(cur)
+			logger.TLog("address:0x%x, length: 0x%x\n", seg.Address, seg.Length)
+			if seg.Address == 0 && seg.Length == 0 {
+				break
 			}
 			arangeInfo.Segments = append(arangeInfo.Segments, seg)
 		}
-		if nextHdrTop != offset {
-			offset += nextHdrTop - offset
-		}
+		// a unit_length past the end of the section ends the table
+		cur.Seek(min(nextHdrTop, cur.Size()))
 		arangeInfos[arangeInfo.Header.DebugInfoOffset] = arangeInfo
 	}
-	return arangeInfos
+	return arangeInfos, nil
 }
 
 type EncodedValue struct {
@@ -1299,289 +1557,202 @@ type EncodedValue struct {
 	sVal   int64
 }
 
-func readExceptionHeaderEncodedField(enc uint8, bin []byte) (EncodedValue, int) {
-	var offset uint64 = 0
+func readExceptionHeaderEncodedField(enc uint8, cur *binutil.Cursor) EncodedValue {
 	var encVal EncodedValue
 	encVal.signed = false
 
 	switch enc & 0x0F {
 	case DW_EH_PE_uleb128:
-		tmp, size := ReaduLEB128(bin[offset:])
-		encVal.uVal = tmp
-		offset += uint64(size)
+		encVal.uVal = cur.ReadUleb128()
 	case DW_EH_PE_data2:
-		tmp, _ := binutil.FromLeToUInt16(bin[offset:])
-		encVal.uVal = uint64(tmp)
-		offset += 2
+		encVal.uVal = uint64(cur.ReadU16())
 	case DW_EH_PE_data4:
-		tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-		encVal.uVal = uint64(tmp)
-		offset += 4
+		encVal.uVal = uint64(cur.ReadU32())
 	case DW_EH_PE_data8:
-		tmp, _ := binutil.FromLeToUInt64(bin[offset:])
-		encVal.uVal = uint64(tmp)
+		encVal.uVal = cur.ReadU64()
 	case DW_EH_PE_sleb128:
-		tmp, size := ReadsLEB128(bin[offset:])
 		encVal.signed = true
-		encVal.sVal = tmp
-		offset += uint64(size)
-		offset += 8
+		encVal.sVal = cur.ReadSleb128()
 	case DW_EH_PE_sdata2:
-		tmp, _ := binutil.FromLeToInt16(bin[offset:])
 		encVal.signed = true
-		encVal.sVal = int64(tmp)
-		offset += 2
+		encVal.sVal = int64(cur.ReadS16())
 	case DW_EH_PE_sdata4:
-		tmp, _ := binutil.FromLeToInt32(bin[offset:])
 		encVal.signed = true
-		encVal.sVal = int64(tmp)
-		offset += 4
+		encVal.sVal = int64(cur.ReadS32())
 	case DW_EH_PE_sdata8:
-		tmp, _ := binutil.FromLeToInt64(bin[offset:])
 		encVal.signed = true
-		encVal.sVal = int64(tmp)
-		offset += 8
+		encVal.sVal = cur.ReadS64()
 	default:
-		panic("Unexpected Encoding")
+		parseFail("encoded value", cur.Pos(), "unexpected encoding 0x%x", enc)
 	}
-	return encVal, int(offset)
+	checkCursor(cur)
+	return encVal
 }
 
-func ReadFrameHdr(bin []byte) {
+func ReadFrameHdr(bin []byte) (err error) {
 	// .eh_frame_hdr
 
-	var offset uint64 = 0
+	cur := binutil.NewLeCursor(".eh_frame_hdr", bin)
+	defer recoverParseError(&err)
 	// version ubyte
-	version := uint8(bin[offset])
-	offset += 1
+	version := cur.ReadU8()
 	logger.DLog("version:%d\n", version)
 
 	// eh_frame_ptr_enc ubyte
-	ehFramePtrEnc := uint8(bin[offset])
-	offset += 1
+	ehFramePtrEnc := cur.ReadU8()
 	logger.DLog("eh_frame_ptr_enc:0x%0X\n", ehFramePtrEnc)
 
 	// fde_count_enc ubyte
-	fdeCountEnc := uint8(bin[offset])
-	offset += 1
+	fdeCountEnc := cur.ReadU8()
 	logger.DLog("fde_count_enc:0x%0X\n", fdeCountEnc)
 
 	// table_enc ubyte
-	tableEnc := uint8(bin[offset])
-	offset += 1
+	tableEnc := cur.ReadU8()
 	logger.DLog("table_enc:0x%0X\n", tableEnc)
+	checkCursor(cur)
 
 	// eh_frame_ptr
-	ehFramePtr, size := readExceptionHeaderEncodedField(ehFramePtrEnc, bin[offset:])
-	offset += uint64(size)
+	ehFramePtr := readExceptionHeaderEncodedField(ehFramePtrEnc, cur)
 	logger.DLog("uVal:%d, sVal%d", ehFramePtr.uVal, ehFramePtr.sVal)
 
 	// fde_count
-	fdeCount, size := readExceptionHeaderEncodedField(fdeCountEnc, bin[offset:])
-	offset += uint64(size)
+	fdeCount := readExceptionHeaderEncodedField(fdeCountEnc, cur)
 
 	var tblCnt uint64 = fdeCount.uVal
 	logger.DLog("uVal:%d", fdeCount.uVal)
 	if fdeCount.signed {
 		tblCnt = uint64(fdeCount.sVal)
 	}
-	offset += readBinarySearchTable(tableEnc, bin[offset:], tblCnt)
+	readBinarySearchTable(tableEnc, cur, tblCnt)
+	return nil
 }
 
-func readBinarySearchTable(tableEnc uint8, bin []byte, fdeCount uint64) uint64 {
-	var offset uint64 = 0
+func readBinarySearchTable(tableEnc uint8, cur *binutil.Cursor, fdeCount uint64) {
 	// binary search table
-	for i := 0; i < int(fdeCount); i++ {
+	for i := uint64(0); i < fdeCount; i++ {
 		// initial location(Program Counter)
-		_, size := readExceptionHeaderEncodedField(tableEnc, bin[offset:])
-		//fmt.Printf("initial location: 0x%x\n", uint32(initialLocationField.sVal))
-		offset += uint64(size)
+		readExceptionHeaderEncodedField(tableEnc, cur)
 
 		// address(offset of FDE)
-		_, size = readExceptionHeaderEncodedField(tableEnc, bin[offset:])
-		//fmt.Printf("address: 0x%x\n", addressField.sVal)
-		offset += uint64(size)
+		readExceptionHeaderEncodedField(tableEnc, cur)
 	}
-
-	return offset
 }
 
-func readCfaOperand(cfaOpcode uint8, dwarfFormat uint64, bin []uint8) uint64 {
-	var offset uint64 = 0
+func readCfaOperand(cfaOpcode uint8, dwarfFormat uint8, cur *binutil.Cursor) {
 	hi2bits := (cfaOpcode & 0xC0) >> 6
 	low6bits := cfaOpcode & 0x3F
 	if hi2bits == DW_CFA_advance_loc {
-		// TODO
-		return offset
+		// the delta is in the low 6 bits
+		return
 	}
 	if hi2bits == DW_CFA_offset {
-		op1Offset, size := ReaduLEB128(bin[offset:])
-		fmt.Println(op1Offset)
-		offset += uint64(size)
-		return offset
+		// the register is in the low 6 bits, operand offset
+		cur.ReadUleb128()
+		checkCursor(cur)
+		return
 	}
 	if hi2bits == DW_CFA_restore {
-		// TODO
-		return offset
+		// the register is in the low 6 bits
+		return
 	}
 	switch low6bits {
-	case DW_CFA_nop:
+	case DW_CFA_nop, DW_CFA_remember_state, DW_CFA_restore_state:
 		// no operand
-		break
 	case DW_CFA_set_loc:
-		// no operand
+		// operand address
 		if dwarfFormat == DWARF_32BIT_FORMAT {
-			offset += 4
+			cur.Skip(4)
 		} else {
-			offset += 8
+			cur.Skip(8)
 		}
 	case DW_CFA_advance_loc1:
-		// TODO
-		offset += 1
+		cur.Skip(1)
 	case DW_CFA_advance_loc2:
-		// TODO
-		offset += 2
+		cur.Skip(2)
 	case DW_CFA_advance_loc4:
-		// TODO
-		offset += 4
-	case DW_CFA_offset_extended:
-		// TODO
-		// Operand1 register
-		_, size := ReaduLEB128(bin[offset:])
-		//fmt.Println(reg)
-		offset += uint64(size)
-
-		// Operand2 offset
-		_, size = ReaduLEB128(bin[offset:])
-		//fmt.Println(op2Offset)
-		offset += uint64(size)
-	case DW_CFA_restore_extended:
-	case DW_CFA_undefined:
-	case DW_CFA_same_value:
-	case DW_CFA_register:
-	case DW_CFA_remember_state:
-	case DW_CFA_restore_state:
-	case DW_CFA_def_cfa:
-		// TODO
-		// Operand1 register
-		reg, size := ReaduLEB128(bin[offset:])
-		fmt.Println(reg)
-		offset += uint64(size)
-
-		// Operand2 offset
-		Op2Offset, size := ReaduLEB128(bin[offset:])
-		fmt.Println(Op2Offset)
-		offset += uint64(size)
-	case DW_CFA_def_cfa_register:
+		cur.Skip(4)
+	case DW_CFA_restore_extended, DW_CFA_undefined, DW_CFA_same_value,
+		DW_CFA_def_cfa_register, DW_CFA_GNU_args_size:
+		// operand register, or size for DW_CFA_GNU_args_size
+		cur.ReadUleb128()
 	case DW_CFA_def_cfa_offset:
-		fmt.Println("!!!!")
-	case DW_CFA_def_cfa_expression:
-	case DW_CFA_expression:
-	case DW_CFA_offset_extended_sf:
-	case DW_CFA_def_cfa_sf:
+		// operand offset
+		cur.ReadUleb128()
 	case DW_CFA_def_cfa_offset_sf:
-	case DW_CFA_val_offset:
-	case DW_CFA_val_offset_sf:
-	case DW_CFA_val_expression:
-	case DW_CFA_lo_user:
-	case DW_CFA_hi_user:
-	case DW_CFA_GNU_args_size:
-	case DW_CFA_GNU_negative_offset_extended:
+		// operand factored offset
+		cur.ReadSleb128()
+	case DW_CFA_offset_extended, DW_CFA_register, DW_CFA_def_cfa,
+		DW_CFA_val_offset, DW_CFA_GNU_negative_offset_extended:
+		// operand1 register, operand2 offset or register
+		cur.ReadUleb128()
+		cur.ReadUleb128()
+	case DW_CFA_offset_extended_sf, DW_CFA_def_cfa_sf, DW_CFA_val_offset_sf:
+		// operand1 register, operand2 factored offset
+		cur.ReadUleb128()
+		cur.ReadSleb128()
+	case DW_CFA_def_cfa_expression:
+		// operand block
+		cur.Skip(cur.ReadUleb128())
+	case DW_CFA_expression, DW_CFA_val_expression:
+		// operand1 register, operand2 block
+		cur.ReadUleb128()
+		cur.Skip(cur.ReadUleb128())
+	case DW_CFA_lo_user, DW_CFA_hi_user:
+		// no operand
 	default:
-		panic("unexpected cfa")
+		parseFail("call frame instruction", cur.Pos(), "unexpected opcode 0x%x", cfaOpcode)
 	}
-
-	return offset
+	checkCursor(cur)
 }
 
-func ReadFrameInfo(bin []byte) {
+func ReadFrameInfo(bin []byte) (err error) {
 	// .eh_frame
-	var offset uint64 = 0
-	unitLengthSize := 4
+	cur := binutil.NewLeCursor(".eh_frame", bin)
+	var entryOffset uint64 = 0
+	defer recoverParseError(&err)
 	// unit_length initial length(4 or 8 bytes)
-	tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-	offset += 4
-
-	// initial length
-	var initialLength uint64 = 0
-	var dwarfFormat uint64 = 0
-
-	if tmp < 0xffffff00 {
-		// 32-bit DWARF Format
-		initialLength = uint64(tmp)
-		dwarfFormat = DWARF_32BIT_FORMAT
-	} else {
-		// 64-bit DWARF Format
-		initialLength, _ = binutil.FromLeToUInt64(bin[offset:])
-		dwarfFormat = DWARF_64BIT_FORMAT
-		offset += 8
-		unitLengthSize += 8
-	}
-
+	initialLength, dwarfFormat, cieEnd := readInitialLength(cur, "CIE")
 	logger.DLog("%d", initialLength)
 
 	// CIE id
-	var cieId uint64 = 0
-	if dwarfFormat == DWARF_32BIT_FORMAT {
-		tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-		cieId = uint64(tmp)
-		offset += 4
-	} else {
-		cieId, _ = binutil.FromLeToUInt64(bin[offset:])
-		offset += 8
-	}
+	cieId := readSecOffset(cur, dwarfFormat)
 	logger.DLog("%d", cieId)
 
 	// version ubyte
-	version := uint8(bin[offset])
-	offset += 1
+	version := cur.ReadU8()
 	logger.DLog("%d", version)
 
 	// augmentation
 	// NULL terminated UTF-8 string
-	augmentation := binutil.GetString(bin, offset)
-	offset += uint64(len(augmentation) + 1)
+	augmentation := cur.ReadCString()
 
 	// "eh" If the Augmentation string has the value "eh", then the EH Data field shall be present.
 	if augmentation == "eh" {
-		var ehData uint64 = 0
-		if dwarfFormat == DWARF_32BIT_FORMAT {
-			tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-			ehData = uint64(tmp)
-			offset += 4
-		} else {
-			tmp, _ := binutil.FromLeToUInt64(bin[offset:])
-			ehData = uint64(tmp)
-			offset += 8
-		}
-
+		ehData := readSecOffset(cur, dwarfFormat)
 		logger.DLog("%d", ehData)
 	}
 
 	// code_alignment_factor
-	codeAlignmentFactor, size := ReaduLEB128(bin[offset:])
+	codeAlignmentFactor := cur.ReadUleb128()
 	logger.DLog("%d", codeAlignmentFactor)
-	offset += uint64(size)
 
 	// data_alignment_factor
-	dataAlignmentFactor, size := ReadsLEB128(bin[offset:])
+	dataAlignmentFactor := cur.ReadSleb128()
 	logger.DLog("%d", dataAlignmentFactor)
-	offset += uint64(size)
 
 	// return_address_register
-	return_address_register, size := ReaduLEB128(bin[offset:])
+	return_address_register := cur.ReadUleb128()
 	logger.DLog("%d", return_address_register)
-	offset += uint64(size)
 
 	// Augmentation Length
 	// An unsigned LEB128 encoded value indicating the length in bytes of the Augmentation Data.
 	// This field is only present if the Augmentation String contains the character 'z'.
 	var AugmentationLength uint64 = 0
 	if strings.Contains(augmentation, "z") {
-		AugmentationLength, size = ReaduLEB128(bin[offset:])
-		offset += uint64(size)
+		AugmentationLength = cur.ReadUleb128()
 		logger.DLog("%d", AugmentationLength)
 	}
+	checkCursor(cur)
 
 	// Augmentation Data
 	// A block of data whose contents are defined by the contents of the Augmentation String as described below.
@@ -1595,74 +1766,49 @@ func ReadFrameInfo(bin []byte) {
 				// A 'R' may be present at any position after the first character of the string.
 				// This character may only be present if 'z' is the first character of the string.
 				// If present, The Augmentation Data shall include a 1 byte argument that represents the pointer encoding for the address pointers used in the FDE.
-				ptrEnc = bin[offset]
-				offset += 1
+				ptrEnc = cur.ReadU8()
+				checkCursor(cur)
 				augDataPos += 1
 				logger.DLog("%d", ptrEnc)
 			} else {
-				panic("Unexpected augmentation")
+				parseFail("CIE", entryOffset, "unexpected augmentation %q", augmentation)
 			}
 		}
 	}
 
 	// initial_instructions
 	// array of DW_CFA_xxx
-	insPos := 0
-	insLen := unitLengthSize + int(initialLength) - int(offset)
-	for insPos < insLen {
-		initialIns := bin[offset]
-		offset++
-		insPos++
-		insSize := readCfaOperand(initialIns, dwarfFormat, bin[offset:])
-		offset += uint64(insSize)
-		insPos += int(insSize)
+	for cur.Pos() < cieEnd {
+		initialIns := cur.ReadU8()
+		checkCursor(cur)
+		readCfaOperand(initialIns, dwarfFormat, cur)
 	}
-	logger.DLog("%d", offset)
+	logger.DLog("%d", cur.Pos())
 
 	// FDE Frame Description Entry Format
 	// unit_length initial length(4 or 8 bytes)
-	tmp, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += 4
-
-	// initial length
-	var fdeInitialLength uint64 = 0
-
-	if tmp < 0xffffff00 {
-		// 32-bit DWARF Format
-		fdeInitialLength = uint64(tmp)
-		dwarfFormat = DWARF_32BIT_FORMAT
-	} else {
-		// 64-bit DWARF Format
-		fdeInitialLength, _ = binutil.FromLeToUInt64(bin[offset:])
-		dwarfFormat = DWARF_64BIT_FORMAT
-		offset += 8
-	}
-
+	entryOffset = cur.Pos()
+	fdeInitialLength, _, _ := readInitialLength(cur, "FDE")
 	logger.DLog("%d", fdeInitialLength)
 
-	var fdeOffset = offset
-
 	// CIE Pointer
-	ciePointer, _ := binutil.FromLeToUInt32(bin[offset:])
-	offset += 4
+	ciePointer := cur.ReadU32()
+	checkCursor(cur)
 	logger.DLog("ciePointer: 0x%X\n", ciePointer)
 
 	// PC Begin
-	pcBegin, size := readExceptionHeaderEncodedField(ptrEnc, bin[offset:])
-	offset += uint64(size)
+	pcBegin := readExceptionHeaderEncodedField(ptrEnc, cur)
 	logger.DLog("pcBegin: 0x%X\n", uint32(pcBegin.sVal))
 
 	// PC Range
-	_, size = readExceptionHeaderEncodedField(ptrEnc, bin[offset:])
-	//fmt.Println(pcRange)
-	offset += uint64(size)
+	readExceptionHeaderEncodedField(ptrEnc, cur)
 
 	// Augmentation Length
 	// An unsigned LEB128 encoded value indicating the length in bytes of the Augmentation Data.
 	// This field is only present if the Augmentation String contains the character 'z'.
 	if strings.Contains(augmentation, "z") {
-		AugmentationLength, size = ReaduLEB128(bin[offset:])
-		offset += uint64(size)
+		AugmentationLength = cur.ReadUleb128()
+		checkCursor(cur)
 	}
 
 	// Augmentation Data
@@ -1677,812 +1823,525 @@ func ReadFrameInfo(bin []byte) {
 				// This character may only be present if 'z' is the first character of the string.
 				// If present, The Augmentation Data shall include a 1 byte argument that represents the pointer encoding for the address pointers used in the FDE.
 				// TODO
-				//ptrEnc = bin[offset]
-				offset += 1
+				cur.Skip(1)
+				checkCursor(cur)
 				augDataPos += 1
-				//fmt.Println(ptrEnc)
 			} else {
-				panic("Unexpected augmentation")
+				parseFail("FDE", entryOffset, "unexpected augmentation %q", augmentation)
 			}
 		}
 	}
 
 	// Call Frame Instructions
-	cfiLength := offset - fdeOffset
-	for i := 0; i < int(cfiLength); i++ {
-		//cfi := bin[offset]
-		offset += 1
-		//fmt.Println(CFANameMap[cfi])
-	}
+	// TODO not decoded
+	return nil
+}
 
+// ReadElfDebugInfo reads .debug_aranges, .debug_line and .debug_info of the object.
+// The sections of a relocatable object are read with their relocations applied,
+// the references to other sections are zero placeholders until then.
+func ReadElfDebugInfo(elfObj elf.ElfObject) ([]Dwarf32CuDebugInfo, error) {
+	bins := map[string][]byte{}
+	for _, name := range []string{".debug_aranges", ".debug_line", ".debug_info"} {
+		bin, err := elfObj.GetRelocatedSectionBinByName(name)
+		if err != nil {
+			return nil, err
+		}
+		bins[name] = bin
+	}
+	offsetArangeMap, err := ReadAranges(bins[".debug_aranges"])
+	if err != nil {
+		return nil, err
+	}
+	offsetLineInfoMap, err := ReadLineInfo(bins[".debug_line"], elfObj)
+	if err != nil {
+		return nil, err
+	}
+	return ReadDebugInfo(offsetArangeMap, bins[".debug_info"], elfObj, offsetLineInfoMap)
 }
 
-func ReadDebugInfo(offsetArangeMap map[uint32]Dwarf32ArangeInfo, debug_info []byte, elfObj elf.ElfObject, offsetLineInfoMap map[uint64]Dwarf32LineInfoHdr) []Dwarf32CuDebugInfo {
-	var dbgInfolen uint64 = uint64(len(debug_info))
+func ReadDebugInfo(offsetArangeMap map[uint32]Dwarf32ArangeInfo, debug_info []byte, elfObj elf.ElfObject, offsetLineInfoMap map[uint64]Dwarf32LineInfoHdr) ([]Dwarf32CuDebugInfo, error) {
 	dbgInfos := []Dwarf32CuDebugInfo{}
-	count := 0
-
-	var cuLineInfoOffset uint64 = 0
+	// the units read before an error are still reported
+	tree, err := ReadDebugInfoTree(debug_info, elfObj)
 
 	cppTmpFunc := make(map[uint64]Dwarf32FuncInfo)
-
-	dbgStrSec := elfObj.GetSectionBinByName(".debug_str")
-	var offset uint64 = 0
-	for offset < dbgInfolen {
-		var cuTop uint64 = offset
-		curArrangeInfo := offsetArangeMap[uint32(offset)]
-		cuh := NewDwarf32Cuh(debug_info[offset:])
+	for _, u := range tree.Units {
 		logger.DLog("******** cu header info ********")
-		logger.DLog("size: 0x%x\n", cuh.UnitLength)
-		logger.DLog("version: %d\n", cuh.Version)
-		logger.DLog("debug_abbrev_offset: %d\n", cuh.DebugAbbrevOffset)
-		logger.DLog("address_size: %d\n", cuh.AddressSize)
-
-		cuDbgInfo := Dwarf32CuDebugInfo{}
-		cuDbgInfo.Funcs = map[uint64]Dwarf32FuncInfo{}
-
-		// compilation unit header
-		debug_abbrev := elfObj.GetSectionBinByName(".debug_abbrev")
-		abbrevTbl := ReadAbbrevTbl(debug_abbrev[cuh.DebugAbbrevOffset:])
-
-		abbrevMap := map[uint64]Abbrev{}
-		for _, abbrev := range abbrevTbl {
-			abbrevMap[abbrev.Id] = abbrev
-		}
-
-		var cuEnd uint64 = (cuh.UnitLength + 4)
-		if cuh.DwarfFormat == DWARF_64BIT_FORMAT {
-			cuEnd = offset + uint64(cuh.UnitLength+8)
+		logger.DLog("size: 0x%x\n", u.Hdr.UnitLength)
+		logger.DLog("version: %d\n", u.Hdr.Version)
+		logger.DLog("debug_abbrev_offset: %d\n", u.Hdr.DebugAbbrevOffset)
+		logger.DLog("address_size: %d\n", u.Hdr.AddressSize)
+
+		if u.Root == nil {
+			dbgInfos = append(dbgInfos, Dwarf32CuDebugInfo{FuncsByOffset: map[uint64]Dwarf32FuncInfo{}})
+			continue
 		}
-		cuEnd += cuTop
-		offset += 11
-
-		for offset < cuEnd {
-			entryOffset := offset
-			id, size := ReaduLEB128(debug_info[offset:])
-			if id == 0 {
-				offset++
-				continue
+		cuDbgInfo := newCuDebugInfo(u.Root)
+		cuLineInfoOffset, _ := u.Root.Val(DW_AT_stmt_list)
+		funcAddrs := map[uint64]string{}
+		u.Root.Walk(func(e *Entry) bool {
+			for _, a := range e.Attributes {
+				if a.Form == DW_FORM_exprloc {
+					traceExpression(a.Block, e.Offset, u, tree.sections)
+				}
+			}
+			if e.Tag != DW_TAG_subprogram {
+				return true
 			}
-
-			abbrev := abbrevMap[id]
-			offset += uint64(size)
 
 			dwarfFuncInfo := Dwarf32FuncInfo{}
-
-			for _, attr := range abbrev.Attrs {
-				attrName := AttrNameMap[attr.Attr]
-				logger.DLog("[%6x] %s", entryOffset, attrName)
-				switch attr.Form {
-				case DW_FORM_addr:
-					// TODO addr
-					var funcaddr uint64 = 0
-					if cuh.AddressSize == 2 {
-						addr, _ := binutil.FromLeToUInt16(debug_info[offset:])
-						funcaddr = uint64(addr)
-						logger.TLog("Attr: %s value:0x%04x\n", attrName, addr)
-					}
-					if cuh.AddressSize == 4 {
-						addr, _ := binutil.FromLeToUInt32(debug_info[offset:])
-						funcaddr = uint64(addr)
-						logger.TLog("Attr: %s value:0x%08x\n", attrName, addr)
+			// a function of a relocatable object may start at address 0
+			hasLowPc := false
+			for _, a := range e.Attributes {
+				switch a.Attr {
+				case DW_AT_specification, DW_AT_abstract_origin:
+					// the out-of-line copy of an inlined function refers to its abstract instance
+					fTmp, exist := Dwarf32FuncInfo{}, false
+					if decl := tree.Ref(e, a.Attr); decl != nil {
+						fTmp, exist = cppTmpFunc[decl.Offset]
 					}
-					if cuh.AddressSize == 8 {
-						addr, _ := binutil.FromLeToUInt64(debug_info[offset:])
-						funcaddr = addr
-						logger.TLog("Attr: %s value:0x%016x\n", attrName, addr)
+					if exist {
+						// take function reference
+						dwarfFuncInfo = fTmp
+					} else {
+						// TODO check func or variable
+						logger.DLog("ref func not found")
 					}
-
+				case DW_AT_name:
+					dwarfFuncInfo.Name = a.Str
+				case DW_AT_linkage_name:
+					dwarfFuncInfo.LinkageName = a.Str
+				case DW_AT_MIPS_linkage_name:
+					// arm-none-eabi-gcc
+					dwarfFuncInfo.Name = a.Str
+				case DW_AT_low_pc:
 					// DW_AT_low_pc  is function start address,
 					// DW_AT_high_pc is function end address,
-					if attr.Attr == DW_AT_low_pc {
-						dwarfFuncInfo.Addr = funcaddr
-					}
-					offset += uint64(cuh.AddressSize)
-				case DW_FORM_block2:
-					blk2, _ := binutil.FromLeToUInt16(debug_info[offset:])
-					offset += 2
-					offset += uint64(blk2)
-					logger.DLog("Attr: %s value:0x%016x\n", attrName, blk2)
-				case DW_FORM_block4:
-					blk4, _ := binutil.FromLeToUInt32(debug_info[offset:])
-					offset += 4
-					offset += uint64(blk4)
-					logger.DLog("Attr: %s value:0x%016x\n", attrName, blk4)
-				case DW_FORM_strp:
-					dbgStrOffset, _ := binutil.FromLeToUInt32(debug_info[offset:])
-					offset += uint64(unsafe.Sizeof(uint32(0)))
-					str := binutil.GetString(dbgStrSec, uint64(dbgStrOffset))
-					logger.DLog("%s: %s\n", attrName, str)
-					if abbrev.Tag == DW_TAG_compile_unit {
-						if attr.Attr == DW_AT_name {
-							// for Rust
-							if cuDbgInfo.IsRust() {
-								idx := strings.LastIndex(str, "@")
-								str = str[:idx-1]
-							}
-							cuDbgInfo.FileName = str
-						} else if attr.Attr == DW_AT_comp_dir {
-							cuDbgInfo.CompileDir = str
-						} else if attr.Attr == DW_AT_producer {
-							cuDbgInfo.Producer = str
-						} else {
-							panic("---------------")
-						}
+					if a.IsAddress() && !a.Unresolved {
+						dwarfFuncInfo.Addr = a.Val
+						hasLowPc = true
 					}
-					if abbrev.Tag == DW_TAG_subprogram {
-						if attr.Attr == DW_AT_name {
-							dwarfFuncInfo.Name = str
-						} else if attr.Attr == DW_AT_linkage_name {
-							dwarfFuncInfo.LinkageName = str
-						} else if attr.Attr == DW_AT_MIPS_linkage_name {
-							// arm-none-eabi-gcc
-							dwarfFuncInfo.Name = str
-						} else {
-							panic("not name!")
-						}
-					}
-				case DW_FORM_data1:
-					// TODO check value
-					// P207 TOOD DW_FORM_implicit_const
-					by := debug_info[offset]
-					offset += 1
-					if attr.Attr == DW_AT_decl_file {
-						lineInfoHdr := offsetLineInfoMap[cuLineInfoOffset]
-						fileName := lineInfoHdr.Files[by-1].Name
-						logger.TLog("Attr: %s filename:%s\n", attrName, fileName)
-					} else {
-						logger.TLog("Attr: %s value:0x%02x\n", attrName, by)
+				case DW_AT_high_pc:
+					if a.IsConstant() {
+						dwarfFuncInfo.Size = uint32(a.Val)
+					} else if a.IsAddress() && !a.Unresolved && hasLowPc {
+						// DWARF 2 and 3 give the end as an address
+						dwarfFuncInfo.Size = uint32(a.Val - dwarfFuncInfo.Addr)
 					}
-				case DW_FORM_data2:
-					// TODO check value
-					val, _ := binutil.FromLeToUInt16(debug_info[offset:])
-					logger.TLog("Attr: %s value:0x%04x\n", attrName, val)
-					if attr.Attr == DW_AT_high_pc {
-						dwarfFuncInfo.Size = uint32(val)
-					}
-					if attr.Attr == DW_AT_language {
-						lang := "unknown language"
-						lang, exist := langNameMap[val]
-						if exist {
-							cuDbgInfo.Language = lang
-						}
-					}
-					offset += 2
-				case DW_FORM_data4:
-					// TODO check value
-					val, _ := binutil.FromLeToUInt32(debug_info[offset:])
-					logger.TLog("Attr: %s value:0x%08x\n", attrName, val)
-					if attr.Attr == DW_AT_high_pc {
-						dwarfFuncInfo.Size = uint32(val)
-					}
-					offset += 4
-				case DW_FORM_data8:
-					// TODO check value
-					val, _ := binutil.FromLeToUInt64(debug_info[offset:])
-					if attr.Attr == DW_AT_high_pc {
-						dwarfFuncInfo.Size = uint32(val)
-					}
-					logger.TLog("Attr: %s value:0x%016x\n", attrName, val)
-					offset += 8
-				case DW_FORM_string:
-					str := binutil.GetString(debug_info[offset:], 0)
-					offset += uint64(len(str)) + 1
-					logger.DLog("str: %s \n", str)
-					if abbrev.Tag == DW_TAG_subprogram {
-						if attr.Attr == DW_AT_name {
-							dwarfFuncInfo.Name = str
-						} else if attr.Attr == DW_AT_linkage_name {
-							dwarfFuncInfo.LinkageName = str
-						} else {
-							panic("not name!")
-						}
-					}
-				case DW_FORM_block: // LEB128
-					// TODO use Block info
-					_, size := ReaduLEB128(debug_info[offset:])
-					offset += uint64(size)
-				case DW_FORM_block1: // 1byte(0～255)
-					// TODO use Block info
-					blockLen := debug_info[offset]
-					offset += 1
-					logger.TLog("Block1: len:%d\n", blockLen, blockLen)
-					offset += uint64(blockLen)
-				case DW_FORM_flag: // 1byte
-					flagVal := debug_info[offset]
-					offset += 1
-					logger.TLog("flag: val:%d\n", flagVal)
-				case DW_FORM_sdata:
-					// TODO use constant
-					_, size := ReadsLEB128(debug_info[offset:])
-					offset += uint64(size)
-				case DW_FORM_udata:
-					// TODO use constant
-					_, size := ReaduLEB128(debug_info[offset:])
-					offset += uint64(size)
-				case DW_FORM_ref1:
-					val := debug_info[offset]
-					refval := cuTop + uint64(val)
-					logger.TLog("Attr: %s value:0x%02x\n", attrName, refval)
-					offset += 1
-				case DW_FORM_ref2:
-					val, _ := binutil.FromLeToUInt16(debug_info[offset:])
-					refval := cuTop + uint64(val)
-					logger.TLog("Attr: %s value:0x%04x\n", attrName, refval)
-					offset += 2
-				case DW_FORM_ref4:
-					val, _ := binutil.FromLeToUInt32(debug_info[offset:])
-					refval := cuTop + uint64(val)
-					logger.TLog("Attr: %s value:0x%04x\n", attrName, refval)
-					if attr.Attr == DW_AT_specification {
-						fTmp, exist := cppTmpFunc[refval]
-						if exist {
-							// take function reference
-							dwarfFuncInfo = fTmp
-						} else {
-							// TODO check func or variable
-							logger.DLog("ref func not found")
-						}
-					} else if attr.Attr == DW_AT_sibling {
-
-					} else if attr.Attr == DW_AT_type {
-
-					} else {
-						fmt.Println("!!!!!!!!!!!!!")
-					}
-					/*
-						if attr.Attr == DW_AT_frame_base {
-							fmt.Println("!!!!!!!!!!!!!")
-						}
-					*/
-					offset += 4
-				case DW_FORM_sec_offset:
-					switch attr.Attr {
-					case DW_AT_stmt_list:
-						// DW_AT_stmt_list is a section offset to the line number information
-						// for this compilation unit
-						tmp, _ := binutil.FromLeToUInt32(debug_info[offset:])
-						cuLineInfoOffset = uint64(tmp)
-						offset += uint64(unsafe.Sizeof(uint32(0)))
-						logger.TLog("%s: 0x%02x\n", attrName, cuLineInfoOffset)
-					case DW_AT_ranges:
-						// A beginning address offset.
-						// A range list entry consists of:
-						// 1. A beginning address offset.
-						//    This address offset has the size of an address and is relative to the applicable base address of the compilation unit referencing this range list.
-						//    It marks the beginning of an address range.
-						// 2. An ending address offset.
-						//    This address offset again has the size of an address and is relative to the applicable base address of the compilation unit referencing this range list.
-						//    It marks the first address past the end of the address range.
-						//    The ending address must be greater than or equal to the beginning address.
-
-						// P162 rangelistptr
-						// This is an offset into the .debug_loc section (DW_FORM_sec_offset).
-						// It consists of an offset from the beginning of the .debug_loc section to the first byte of the data making up the location list for the compilation unit.
-						// It is relocatable in a relocatable object file, and relocated in an executable or shared object.
-						// In the 32-bit DWARF format, this offset is a 4-byte unsigned value; in the 64-bit DWARF format, it is an 8-byte unsigned value (see Section 7.4).
-						// TODO for 64bit impl
-						var loclistptr uint64
-						if cuh.DwarfFormat == DWARF_32BIT_FORMAT {
-							tmp, _ := binutil.FromLeToUInt32(debug_info[offset:])
-							loclistptr = uint64(tmp)
-							offset += 4
-						} else {
-							loclistptr, _ = binutil.FromLeToUInt64(debug_info[offset:])
-							offset += 8
-						}
-						logger.TLog("loclistptr:%x", loclistptr)
-					case DW_AT_location:
-						logger.TLog("%x:%s\n", abbrev.Tag, TagNameMap[abbrev.Tag])
-						var loclistptr uint64
-						if cuh.DwarfFormat == DWARF_32BIT_FORMAT {
-							tmp, _ := binutil.FromLeToUInt32(debug_info[offset:])
-							loclistptr = uint64(tmp)
-							offset += 4
-						} else {
-							loclistptr, _ = binutil.FromLeToUInt64(debug_info[offset:])
-							offset += 8
-						}
-						logger.TLog("loclistptr:%x", loclistptr)
-						// GNU extensions
-					case GNU_locviews:
-						// TODO
-						logger.TLog("%x:%s\n", abbrev.Tag, TagNameMap[abbrev.Tag])
-						var loclistptr uint64
-						if cuh.DwarfFormat == DWARF_32BIT_FORMAT {
-							tmp, _ := binutil.FromLeToUInt32(debug_info[offset:])
-							loclistptr = uint64(tmp)
-							offset += 4
-						} else {
-							loclistptr, _ = binutil.FromLeToUInt64(debug_info[offset:])
-							offset += 8
-						}
-						logger.TLog("loclistptr:%x", loclistptr)
-					default:
-						msg := fmt.Sprintf("unexpected attr:%d(%x)", attr.Attr, attr.Attr)
-						panic(msg)
-					}
-				case DW_FORM_exprloc:
-					logger.TLog("attr:%x,%s", attr.Attr, AttrNameMap[attr.Attr])
-					// following size
-					opSize, size := ReaduLEB128(debug_info[offset:])
-					offset += uint64(size)
-
-					length := int(opSize)
-					for i := 0; i < length; {
-
-						// dwarf exp OP Code
-						ins := debug_info[offset]
-						i++
-						offset += 1
-						if DW_OP_lo_user <= ins && ins <= DW_OP_hi_user {
-							// TODO skip extensions
-							offset += uint64(length - i)
-							i += int(length - i)
-							continue
-						}
-
-						switch ins {
-						case DW_OP_addr:
-							// size target specific
-							addr, _ := binutil.FromLeToUInt64(debug_info[offset:])
-							logger.TLog("DW_OP_addr:%x", addr)
-							offset += uint64(curArrangeInfo.Header.AddressSize)
-							i += int(curArrangeInfo.Header.AddressSize)
-						case DW_OP_deref:
-						case DW_OP_const1u:
-							const1u := debug_info[offset]
-							logger.TLog("DW_OP_const1u:%x", const1u)
-							offset += 1
-							i += 1
-						case DW_OP_const1s:
-							const1s := debug_info[offset]
-							logger.TLog("DW_OP_const1s :%d", int8(const1s))
-							offset += 1
-							i += 1
-						case DW_OP_const2u:
-							const2u, _ := binutil.FromLeToUInt16(debug_info[offset:])
-							logger.TLog("DW_OP_const2u :%d", const2u)
-							offset += 2
-							i += 2
-						case DW_OP_const2s:
-							const2s, _ := binutil.FromLeToInt16(debug_info[offset:])
-							logger.TLog("DW_OP_const2s :%d", const2s)
-							offset += 2
-							i += 2
-						case DW_OP_const4u:
-							const4u, _ := binutil.FromLeToUInt32(debug_info[offset:])
-							logger.TLog("DW_OP_const4u :%d", const4u)
-							offset += 4
-							i += 4
-						case DW_OP_const4s:
-							const4s, _ := binutil.FromLeToInt32(debug_info[offset:])
-							logger.TLog("DW_OP_const4s :%d", const4s)
-							offset += 4
-							i += 4
-						case DW_OP_const8u:
-							const8u, _ := binutil.FromLeToUInt64(debug_info[offset:])
-							logger.TLog("DW_OP_const8u :%d", const8u)
-							offset += 8
-							i += 8
-						case DW_OP_const8s:
-							const8s, _ := binutil.FromLeToInt64(debug_info[offset:])
-							logger.TLog("DW_OP_const8s :%d", const8s)
-							offset += 8
-							i += 8
-						case DW_OP_constu:
-							constu, size := ReadsLEB128(debug_info[offset:])
-							offset += uint64(size)
-							i += size
-							logger.TLog("DW_OP_constu:%d\n", constu)
-						case DW_OP_consts:
-							consts, size := ReadsLEB128(debug_info[offset:])
-							offset += uint64(size)
-							i += size
-							logger.TLog("DW_OP_consts:%d\n", consts)
-						case DW_OP_drop:
-						case DW_OP_over:
-						case DW_OP_swap:
-						case DW_OP_abs:
-						case DW_OP_and:
-						case DW_OP_div:
-						case DW_OP_minus:
-						case DW_OP_mod:
-						case DW_OP_mul:
-						case DW_OP_neg:
-						case DW_OP_not:
-						case DW_OP_or:
-						case DW_OP_plus:
-						case DW_OP_plus_uconst:
-							operand, size := ReaduLEB128(debug_info[offset:])
-							offset += uint64(size)
-							i += size
-							logger.TLog("\toperand:%d\n", operand)
-						case DW_OP_shl:
-						case DW_OP_shr:
-						case DW_OP_shra:
-						case DW_OP_xor:
-						case DW_OP_skip: // 0x2f
-							operand, _ := binutil.FromLeToInt16(debug_info[offset:])
-							offset += 2
-							i += 2
-							logger.TLog("\toperand:%d\n", operand)
-						case DW_OP_bra: //  0x28
-							operand, _ := binutil.FromLeToInt16(debug_info[offset:])
-							offset += 2
-							i += 2
-							logger.TLog("\toperand:%d\n", operand)
-						case DW_OP_eq: // = 0x29
-						case DW_OP_ge: // = 0x2a
-						case DW_OP_gt: // = 0x2b
-						case DW_OP_le: // = 0x2c
-						case DW_OP_lt: // = 0x2d
-						case DW_OP_ne: // = 0x2e
-
-						case DW_OP_fbreg:
-							operand, size := ReadsLEB128(debug_info[offset:])
-							offset += uint64(size)
-							i += size
-							logger.TLog("\toperand:%d\n", operand)
-						case DW_OP_call_frame_cfa:
-							// no operand
-						case DW_OP_lit0:
-							fallthrough
-						case DW_OP_lit1:
-							fallthrough
-						case DW_OP_lit2:
-							fallthrough
-						case DW_OP_lit3:
-							fallthrough
-						case DW_OP_lit4:
-							fallthrough
-						case DW_OP_lit5:
-							fallthrough
-						case DW_OP_lit6:
-							fallthrough
-						case DW_OP_lit7:
-							fallthrough
-						case DW_OP_lit8:
-							fallthrough
-						case DW_OP_lit9:
-							fallthrough
-						case DW_OP_lit10:
-							fallthrough
-						case DW_OP_lit11:
-							fallthrough
-						case DW_OP_lit12:
-							fallthrough
-						case DW_OP_lit13:
-							fallthrough
-						case DW_OP_lit14:
-							fallthrough
-						case DW_OP_lit15:
-							fallthrough
-						case DW_OP_lit16:
-							fallthrough
-						case DW_OP_lit17:
-							fallthrough
-						case DW_OP_lit18:
-							fallthrough
-						case DW_OP_lit19:
-							fallthrough
-						case DW_OP_lit20:
-							fallthrough
-						case DW_OP_lit21:
-							fallthrough
-						case DW_OP_lit22:
-							fallthrough
-						case DW_OP_lit23:
-							fallthrough
-						case DW_OP_lit24:
-							fallthrough
-						case DW_OP_lit25:
-							fallthrough
-						case DW_OP_lit26:
-							fallthrough
-						case DW_OP_lit27:
-							fallthrough
-						case DW_OP_lit28:
-							fallthrough
-						case DW_OP_lit29:
-							fallthrough
-						case DW_OP_lit30:
-							fallthrough
-						case DW_OP_lit31:
-							// TODO lit,
-						case DW_OP_reg0:
-							fallthrough
-						case DW_OP_reg1:
-							fallthrough
-						case DW_OP_reg2:
-							fallthrough
-						case DW_OP_reg3:
-							fallthrough
-						case DW_OP_reg4:
-							fallthrough
-						case DW_OP_reg5:
-							fallthrough
-						case DW_OP_reg6:
-							fallthrough
-						case DW_OP_reg7:
-							fallthrough
-						case DW_OP_reg8:
-							fallthrough
-						case DW_OP_reg9:
-							fallthrough
-						case DW_OP_reg10:
-							fallthrough
-						case DW_OP_reg11:
-							fallthrough
-						case DW_OP_reg12:
-							fallthrough
-						case DW_OP_reg13:
-							fallthrough
-						case DW_OP_reg14:
-							fallthrough
-						case DW_OP_reg15:
-							fallthrough
-						case DW_OP_reg16:
-							fallthrough
-						case DW_OP_reg17:
-							fallthrough
-						case DW_OP_reg18:
-							fallthrough
-						case DW_OP_reg19:
-							fallthrough
-						case DW_OP_reg20:
-							fallthrough
-						case DW_OP_reg21:
-							fallthrough
-						case DW_OP_reg22:
-							fallthrough
-						case DW_OP_reg23:
-							fallthrough
-						case DW_OP_reg24:
-							fallthrough
-						case DW_OP_reg25:
-							fallthrough
-						case DW_OP_reg26:
-							fallthrough
-						case DW_OP_reg27:
-							fallthrough
-						case DW_OP_reg28:
-							fallthrough
-						case DW_OP_reg29:
-							fallthrough
-						case DW_OP_reg30:
-							fallthrough
-						case DW_OP_reg31:
-							// TODO reg0 ~ reg31
-						case DW_OP_breg0:
-							fallthrough
-						case DW_OP_breg1:
-							fallthrough
-						case DW_OP_breg2:
-							fallthrough
-						case DW_OP_breg3:
-							fallthrough
-						case DW_OP_breg4:
-							fallthrough
-						case DW_OP_breg5:
-							fallthrough
-						case DW_OP_breg6:
-							fallthrough
-						case DW_OP_breg7:
-							fallthrough
-						case DW_OP_breg8:
-							fallthrough
-						case DW_OP_breg9:
-							fallthrough
-						case DW_OP_breg10:
-							fallthrough
-						case DW_OP_breg11:
-							fallthrough
-						case DW_OP_breg12:
-							fallthrough
-						case DW_OP_breg13:
-							fallthrough
-						case DW_OP_breg14:
-							fallthrough
-						case DW_OP_breg15:
-							fallthrough
-						case DW_OP_breg16:
-							fallthrough
-						case DW_OP_breg17:
-							fallthrough
-						case DW_OP_breg18:
-							fallthrough
-						case DW_OP_breg19:
-							fallthrough
-						case DW_OP_breg20:
-							fallthrough
-						case DW_OP_breg21:
-							fallthrough
-						case DW_OP_breg22:
-							fallthrough
-						case DW_OP_breg23:
-							fallthrough
-						case DW_OP_breg24:
-							fallthrough
-						case DW_OP_breg25:
-							fallthrough
-						case DW_OP_breg26:
-							fallthrough
-						case DW_OP_breg27:
-							fallthrough
-						case DW_OP_breg28:
-							fallthrough
-						case DW_OP_breg29:
-							fallthrough
-						case DW_OP_breg30:
-							fallthrough
-						case DW_OP_breg31:
-							// The single operand of the DW_OP_bregn operations provides a signed LEB128 offset
-							// from the specified register.
-							_, size := ReadsLEB128(debug_info[offset:])
-							offset += uint64(size)
-							i += size
-						case DW_OP_deref_size:
-							//operand := debug_info[offset:]
-							offset++
-							i += 1
-						case DW_OP_implicit_value:
-							length, size := ReaduLEB128(debug_info[offset:])
-							offset += uint64(size)
-							offset += length
-							i += size
-							i += int(length)
-						case DW_OP_stack_value:
-							// TODO
-						default:
-							msg := fmt.Sprintf("TODO Not decoded op 0x%02x\n", ins)
-							panic(msg)
-						}
-					}
-				case DW_FORM_flag_present:
-					// flag exist
-					logger.TLog("Attr: %s flag exists\n", attrName)
-				case DW_FORM_line_strp:
-					var strOffset uint64 = 0
-					shLineStr := elfObj.GetSectionBinByName(".debug_line_str")
-					if cuh.DwarfFormat == DWARF_32BIT_FORMAT {
-						// 4byte
-						tmp, _ := binutil.FromLeToUInt32(debug_info[offset:])
-						offset += 4
-						strOffset = (uint64)(tmp)
-					} else {
-						// 8byte
-						tmp, _ := binutil.FromLeToUInt64(debug_info[offset:])
-						offset += 8
-						strOffset = tmp
-					}
-					name := binutil.GetString(shLineStr, strOffset)
-					logger.DLog(name)
-					if abbrev.Tag == DW_TAG_compile_unit {
-						if attr.Attr == DW_AT_name {
-							// for Rust
-							if cuDbgInfo.IsRust() {
-								idx := strings.LastIndex(name, "@")
-								name = name[:idx-1]
-							}
-							cuDbgInfo.FileName = name
-						} else if attr.Attr == DW_AT_comp_dir {
-							cuDbgInfo.CompileDir = name
-						} else {
-							panic("---------------")
-						}
-					}
-				case DW_FORM_implicit_const:
-					if attr.Attr == DW_AT_decl_file {
-						lineInfoHdr := offsetLineInfoMap[cuLineInfoOffset]
-						fileName := lineInfoHdr.Files[attr.Const].Name
-						logger.TLog("Attr: %s filename:%s\n", attrName, fileName)
-					} else {
-						logger.TLog("Attr: %s value:0x%02x\n", attrName, attr.Const)
-					}
-				default:
-					fmt.Printf("Unknown Form:0x%x\n", attr.Form)
-					panic("unknown")
+				case DW_AT_decl_file:
+					lineInfoHdr := offsetLineInfoMap[cuLineInfoOffset]
+					dwarfFuncInfo.DeclFile = lineInfoHdr.FileName(a.Val)
+					logger.TLog("Attr: %s filename:%s\n", AttrNameMap[a.Attr], dwarfFuncInfo.DeclFile)
+				case DW_AT_decl_line:
+					dwarfFuncInfo.DeclLine = a.Val
+				case DW_AT_external:
+					dwarfFuncInfo.External = a.Val != 0
 				}
 			}
-			if abbrev.Tag == DW_TAG_subprogram {
-				if dwarfFuncInfo.Name == "" {
-					dbgFunc, exist := cuDbgInfo.Funcs[dwarfFuncInfo.Addr]
-					if exist {
-						logger.TLog("name:%s, addr:0x%X already registed\n", dbgFunc.Name, dwarfFuncInfo.Addr)
-					} else {
-						// TODO For Rust
-						cppTmpFunc[uint64(entryOffset)] = dwarfFuncInfo
-						logger.DLog("addr:0x:%x function not found\n", dwarfFuncInfo.Addr)
-						count++
-						// TODO Comment out For Rust
-						//os.Exit(2)
-						continue
-					}
-				}
-				if dwarfFuncInfo.Addr != 0 {
-					// skip if addr not set(must be library function)
-					logger.TLog("name:%s, linkageName:%s addr:0x%X\n", dwarfFuncInfo.Name, dwarfFuncInfo.LinkageName, dwarfFuncInfo.Addr)
-					cuDbgInfo.Funcs[dwarfFuncInfo.Addr] = dwarfFuncInfo
+			if dwarfFuncInfo.Name == "" {
+				name, exist := funcAddrs[dwarfFuncInfo.Addr]
+				if exist {
+					logger.TLog("name:%s, addr:0x%X already registed\n", name, dwarfFuncInfo.Addr)
 				} else {
-					// addr not fixed, maybe c++ function delc, add tmpFuncs
-					cppTmpFunc[uint64(entryOffset)] = dwarfFuncInfo
+					// TODO For Rust
+					cppTmpFunc[e.Offset] = dwarfFuncInfo
+					logger.DLog("addr:0x:%x function not found\n", dwarfFuncInfo.Addr)
+					return true
 				}
 			}
-			count++
-		}
+			if hasLowPc {
+				// skip if addr not set(must be library function)
+				logger.TLog("name:%s, linkageName:%s addr:0x%X\n", dwarfFuncInfo.Name, dwarfFuncInfo.LinkageName, dwarfFuncInfo.Addr)
+				cuDbgInfo.FuncsByOffset[e.Offset] = dwarfFuncInfo
+				funcAddrs[dwarfFuncInfo.Addr] = dwarfFuncInfo.Name
+			} else {
+				// addr not fixed, maybe c++ function delc, add tmpFuncs
+				cppTmpFunc[e.Offset] = dwarfFuncInfo
+			}
+			return true
+		})
 		dbgInfos = append(dbgInfos, cuDbgInfo)
 	}
+	return dbgInfos, err
+}
+
+// newCuDebugInfo returns the information of the unit entry root with no functions yet
+func newCuDebugInfo(root *Entry) Dwarf32CuDebugInfo {
+	cuDbgInfo := Dwarf32CuDebugInfo{}
+	cuDbgInfo.FuncsByOffset = map[uint64]Dwarf32FuncInfo{}
+	if lang, exist := root.Attr(DW_AT_language); exist && lang.IsConstant() {
+		cuDbgInfo.Language = langNameMap[uint16(lang.Val)]
+	}
+	if root.Tag != DW_TAG_compile_unit && root.Tag != DW_TAG_skeleton_unit {
+		return cuDbgInfo
+	}
+	cuDbgInfo.FileName = root.Name()
+	// for Rust
+	idx := strings.LastIndex(cuDbgInfo.FileName, "@")
+	if cuDbgInfo.IsRust() && 0 < idx {
+		cuDbgInfo.FileName = cuDbgInfo.FileName[:idx-1]
+	}
+	cuDbgInfo.CompileDir = root.Str(DW_AT_comp_dir)
+	cuDbgInfo.Producer = root.Str(DW_AT_producer)
+	return cuDbgInfo
+}
+
+// traceExpression logs the operations of a DWARF expression of the entry at entryOffset
+func traceExpression(bin []byte, entryOffset uint64, u *Unit, sections indexSections) {
+	cuh := u.Hdr
+	expr := binutil.NewLeCursor("DWARF expression", bin)
+	for 0 < expr.Len() {
+
+		// dwarf exp OP Code
+		ins := expr.ReadU8()
+		if DW_OP_lo_user <= ins && ins <= DW_OP_hi_user {
+			// TODO skip extensions
+			expr.Skip(expr.Len())
+			continue
+		}
 
-	return dbgInfos
+		switch ins {
+		case DW_OP_addr:
+			// size target specific
+			addr := expr.ReadUint(int(cuh.AddressSize))
+			logger.TLog("DW_OP_addr:%x", addr)
+		case DW_OP_deref:
+		case DW_OP_const1u:
+			const1u := expr.ReadU8()
+			logger.TLog("DW_OP_const1u:%x", const1u)
+		case DW_OP_const1s:
+			const1s := expr.ReadS8()
+			logger.TLog("DW_OP_const1s :%d", const1s)
+		case DW_OP_const2u:
+			const2u := expr.ReadU16()
+			logger.TLog("DW_OP_const2u :%d", const2u)
+		case DW_OP_const2s:
+			const2s := expr.ReadS16()
+			logger.TLog("DW_OP_const2s :%d", const2s)
+		case DW_OP_const4u:
+			const4u := expr.ReadU32()
+			logger.TLog("DW_OP_const4u :%d", const4u)
+		case DW_OP_const4s:
+			const4s := expr.ReadS32()
+			logger.TLog("DW_OP_const4s :%d", const4s)
+		case DW_OP_const8u:
+			const8u := expr.ReadU64()
+			logger.TLog("DW_OP_const8u :%d", const8u)
+		case DW_OP_const8s:
+			const8s := expr.ReadS64()
+			logger.TLog("DW_OP_const8s :%d", const8s)
+		case DW_OP_constu:
+			constu := expr.ReadUleb128()
+			logger.TLog("DW_OP_constu:%d\n", constu)
+		case DW_OP_consts:
+			consts := expr.ReadSleb128()
+			logger.TLog("DW_OP_consts:%d\n", consts)
+		case DW_OP_drop:
+		case DW_OP_over:
+		case DW_OP_swap:
+		case DW_OP_abs:
+		case DW_OP_and:
+		case DW_OP_div:
+		case DW_OP_minus:
+		case DW_OP_mod:
+		case DW_OP_mul:
+		case DW_OP_neg:
+		case DW_OP_not:
+		case DW_OP_or:
+		case DW_OP_plus:
+		case DW_OP_plus_uconst:
+			operand := expr.ReadUleb128()
+			logger.TLog("\toperand:%d\n", operand)
+		case DW_OP_shl:
+		case DW_OP_shr:
+		case DW_OP_shra:
+		case DW_OP_xor:
+		case DW_OP_skip: // 0x2f
+			operand := expr.ReadS16()
+			logger.TLog("\toperand:%d\n", operand)
+		case DW_OP_bra: //  0x28
+			operand := expr.ReadS16()
+			logger.TLog("\toperand:%d\n", operand)
+		case DW_OP_eq: // = 0x29
+		case DW_OP_ge: // = 0x2a
+		case DW_OP_gt: // = 0x2b
+		case DW_OP_le: // = 0x2c
+		case DW_OP_lt: // = 0x2d
+		case DW_OP_ne: // = 0x2e
+
+		case DW_OP_fbreg:
+			operand := expr.ReadSleb128()
+			logger.TLog("\toperand:%d\n", operand)
+		case DW_OP_call_frame_cfa:
+			// no operand
+		case DW_OP_lit0:
+			fallthrough
+		case DW_OP_lit1:
+			fallthrough
+		case DW_OP_lit2:
+			fallthrough
+		case DW_OP_lit3:
+			fallthrough
+		case DW_OP_lit4:
+			fallthrough
+		case DW_OP_lit5:
+			fallthrough
+		case DW_OP_lit6:
+			fallthrough
+		case DW_OP_lit7:
+			fallthrough
+		case DW_OP_lit8:
+			fallthrough
+		case DW_OP_lit9:
+			fallthrough
+		case DW_OP_lit10:
+			fallthrough
+		case DW_OP_lit11:
+			fallthrough
+		case DW_OP_lit12:
+			fallthrough
+		case DW_OP_lit13:
+			fallthrough
+		case DW_OP_lit14:
+			fallthrough
+		case DW_OP_lit15:
+			fallthrough
+		case DW_OP_lit16:
+			fallthrough
+		case DW_OP_lit17:
+			fallthrough
+		case DW_OP_lit18:
+			fallthrough
+		case DW_OP_lit19:
+			fallthrough
+		case DW_OP_lit20:
+			fallthrough
+		case DW_OP_lit21:
+			fallthrough
+		case DW_OP_lit22:
+			fallthrough
+		case DW_OP_lit23:
+			fallthrough
+		case DW_OP_lit24:
+			fallthrough
+		case DW_OP_lit25:
+			fallthrough
+		case DW_OP_lit26:
+			fallthrough
+		case DW_OP_lit27:
+			fallthrough
+		case DW_OP_lit28:
+			fallthrough
+		case DW_OP_lit29:
+			fallthrough
+		case DW_OP_lit30:
+			fallthrough
+		case DW_OP_lit31:
+			// TODO lit,
+		case DW_OP_reg0:
+			fallthrough
+		case DW_OP_reg1:
+			fallthrough
+		case DW_OP_reg2:
+			fallthrough
+		case DW_OP_reg3:
+			fallthrough
+		case DW_OP_reg4:
+			fallthrough
+		case DW_OP_reg5:
+			fallthrough
+		case DW_OP_reg6:
+			fallthrough
+		case DW_OP_reg7:
+			fallthrough
+		case DW_OP_reg8:
+			fallthrough
+		case DW_OP_reg9:
+			fallthrough
+		case DW_OP_reg10:
+			fallthrough
+		case DW_OP_reg11:
+			fallthrough
+		case DW_OP_reg12:
+			fallthrough
+		case DW_OP_reg13:
+			fallthrough
+		case DW_OP_reg14:
+			fallthrough
+		case DW_OP_reg15:
+			fallthrough
+		case DW_OP_reg16:
+			fallthrough
+		case DW_OP_reg17:
+			fallthrough
+		case DW_OP_reg18:
+			fallthrough
+		case DW_OP_reg19:
+			fallthrough
+		case DW_OP_reg20:
+			fallthrough
+		case DW_OP_reg21:
+			fallthrough
+		case DW_OP_reg22:
+			fallthrough
+		case DW_OP_reg23:
+			fallthrough
+		case DW_OP_reg24:
+			fallthrough
+		case DW_OP_reg25:
+			fallthrough
+		case DW_OP_reg26:
+			fallthrough
+		case DW_OP_reg27:
+			fallthrough
+		case DW_OP_reg28:
+			fallthrough
+		case DW_OP_reg29:
+			fallthrough
+		case DW_OP_reg30:
+			fallthrough
+		case DW_OP_reg31:
+			// TODO reg0 ~ reg31
+		case DW_OP_breg0:
+			fallthrough
+		case DW_OP_breg1:
+			fallthrough
+		case DW_OP_breg2:
+			fallthrough
+		case DW_OP_breg3:
+			fallthrough
+		case DW_OP_breg4:
+			fallthrough
+		case DW_OP_breg5:
+			fallthrough
+		case DW_OP_breg6:
+			fallthrough
+		case DW_OP_breg7:
+			fallthrough
+		case DW_OP_breg8:
+			fallthrough
+		case DW_OP_breg9:
+			fallthrough
+		case DW_OP_breg10:
+			fallthrough
+		case DW_OP_breg11:
+			fallthrough
+		case DW_OP_breg12:
+			fallthrough
+		case DW_OP_breg13:
+			fallthrough
+		case DW_OP_breg14:
+			fallthrough
+		case DW_OP_breg15:
+			fallthrough
+		case DW_OP_breg16:
+			fallthrough
+		case DW_OP_breg17:
+			fallthrough
+		case DW_OP_breg18:
+			fallthrough
+		case DW_OP_breg19:
+			fallthrough
+		case DW_OP_breg20:
+			fallthrough
+		case DW_OP_breg21:
+			fallthrough
+		case DW_OP_breg22:
+			fallthrough
+		case DW_OP_breg23:
+			fallthrough
+		case DW_OP_breg24:
+			fallthrough
+		case DW_OP_breg25:
+			fallthrough
+		case DW_OP_breg26:
+			fallthrough
+		case DW_OP_breg27:
+			fallthrough
+		case DW_OP_breg28:
+			fallthrough
+		case DW_OP_breg29:
+			fallthrough
+		case DW_OP_breg30:
+			fallthrough
+		case DW_OP_breg31:
+			// The single operand of the DW_OP_bregn operations provides a signed LEB128 offset
+			// from the specified register.
+			expr.ReadSleb128()
+		case DW_OP_deref_size:
+			expr.Skip(1)
+		case DW_OP_implicit_value:
+			length := expr.ReadUleb128()
+			expr.Skip(length)
+		case DW_OP_stack_value:
+			// TODO
+		case DW_OP_addrx, DW_OP_constx:
+			// an index into .debug_addr
+			addr, _ := sections.getAddr(expr.ReadUleb128(), u.bases, cuh)
+			logger.TLog("%s:%x", OpNameMap[ins], addr)
+		default:
+			logger.DLog("DIE at offset 0x%x: not decoded op 0x%02x\n", entryOffset, ins)
+			return
+		}
+		if expr.Err() != nil {
+			logger.DLog("DIE at offset 0x%x: %v\n", entryOffset, expr.Err())
+			return
+		}
+	}
 }
 
-func ReadLineInfo(bin []byte, elfObj elf.ElfObject) map[uint64]Dwarf32LineInfoHdr {
-	offsetLineInfoHdrMap := map[uint64]Dwarf32LineInfoHdr{}
-	var offset uint64 = 0
+func ReadLineInfo(bin []byte, elfObj elf.ElfObject) (offsetLineInfoHdrMap map[uint64]Dwarf32LineInfoHdr, err error) {
+	offsetLineInfoHdrMap = map[uint64]Dwarf32LineInfoHdr{}
+	lineStrTab := binutil.NewStrTab(elfObj.GetSectionBinByName(".debug_line_str"))
+	cur := binutil.NewLeCursor(".debug_line", bin)
 	var hdrOffset uint64 = 0
-	lineInfoLen := uint64(len(bin))
-	for hdrOffset < lineInfoLen {
-		offset = hdrOffset
+	defer recoverParseError(&err)
+	for 0 < cur.Len() {
+		hdrOffset = cur.Pos()
 		lineInfoHdr := Dwarf32LineInfoHdr{}
 
-		// TODO 4 or 8
 		// unit_length initial length(4 or 8 bytes)
-		tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-		offset += 4
-		if tmp < 0xffffff00 {
-			// 32-bit DWARF Format
-			lineInfoHdr.UnitLength = uint64(tmp)
-			lineInfoHdr.DwarfFormat = DWARF_32BIT_FORMAT
-		} else {
-			// 64-bit DWARF Format
-			lineInfoHdr.UnitLength, _ = binutil.FromLeToUInt64(bin[offset:])
-			lineInfoHdr.DwarfFormat = DWARF_64BIT_FORMAT
-			offset += 8
-		}
+		var endOffset uint64 = 0
+		lineInfoHdr.UnitLength, lineInfoHdr.DwarfFormat, endOffset = readInitialLength(cur, ".debug_line")
 
 		// version uhalf
-		lineInfoHdr.Version, _ = binutil.FromLeToUInt16(bin[offset:])
-		offset += 2
+		lineInfoHdr.Version = cur.ReadU16()
 
 		if 5 <= lineInfoHdr.Version {
 			// DWARF Version 5 or later
-			lineInfoHdr.AddressSize = bin[offset]
-			offset += 1
-			lineInfoHdr.SegmentSelectorSize = bin[offset]
-			offset += 1
+			lineInfoHdr.AddressSize = cur.ReadU8()
+			lineInfoHdr.SegmentSelectorSize = cur.ReadU8()
 		}
 
 		// header_length 32bit-DWARF/64bit-DWARF
-		lineInfoHdr.HeaderLength, _ = binutil.FromLeToUInt32(bin[offset:])
-		offset += 4
+		lineInfoHdr.HeaderLength = uint32(readSecOffset(cur, lineInfoHdr.DwarfFormat))
 
 		// minimum_instruction_length ubyte
-		lineInfoHdr.MinInstLength = bin[offset]
-		offset += 1
+		lineInfoHdr.MinInstLength = cur.ReadU8()
 
 		// maximum_operations_per_instruction ubyte
 		if 4 <= lineInfoHdr.Version {
-			lineInfoHdr.MaxInstLength = bin[offset]
-			offset += 1
+			lineInfoHdr.MaxInstLength = cur.ReadU8()
 		}
 
 		// default_is_stmt ubyte
-		lineInfoHdr.DefaultIsStmt = bin[offset]
-		offset += 1
+		lineInfoHdr.DefaultIsStmt = cur.ReadU8()
 
 		// line_base (sbyte)
-		lineInfoHdr.LineBase = int8(bin[offset])
-		offset += 1
+		lineInfoHdr.LineBase = cur.ReadS8()
 
 		// line_range ubyte
-		lineInfoHdr.LineRange = bin[offset]
-		offset += 1
+		lineInfoHdr.LineRange = cur.ReadU8()
+		checkCursor(cur)
+		if lineInfoHdr.LineRange == 0 {
+			// special opcodes divide by it
+			parseFail(".debug_line", hdrOffset, "line_range is 0")
+		}
 
 		// opcode_base ubyte
 		// The number assigned to the first special opcode.
-		lineInfoHdr.OpcodeBase = bin[offset]
-		offset += 1
+		lineInfoHdr.OpcodeBase = cur.ReadU8()
 
 		// standard_opcode_lengths array of ubyte
 		// This array specifies the number of LEB128 operands for each of the standard opcodes.
@@ -2490,31 +2349,31 @@ func ReadLineInfo(bin []byte, elfObj elf.ElfObject) map[uint64]Dwarf32LineInfoHd
 		// the last element corresponds to the opcode whose value is opcode_base - 1.
 		lineInfoHdr.StdOpcodeLengths = []byte{}
 		for i := 0; i < int(lineInfoHdr.OpcodeBase-1); i++ {
-			lineInfoHdr.StdOpcodeLengths = append(lineInfoHdr.StdOpcodeLengths, bin[offset])
-			offset++
+			lineInfoHdr.StdOpcodeLengths = append(lineInfoHdr.StdOpcodeLengths, cur.ReadU8())
 		}
+		checkCursor(cur)
 
 		if 5 <= lineInfoHdr.Version {
 			// DWARF Version 5 or later
 			lineInfoHdr.IncludeDirs = []string{}
 
 			// directories
-			lineInfoHdr.DirectoryEntryFormatCount = bin[offset]
-			offset++
+			lineInfoHdr.DirectoryEntryFormatCount = cur.ReadU8()
 
 			// P156
-			var sz int = 0
 			for i := 0; i < (int)(lineInfoHdr.DirectoryEntryFormatCount); i++ {
 				var EntryFmt EntryFormat
-				EntryFmt.TypeCode, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
-				EntryFmt.FormCode, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
+				EntryFmt.TypeCode = cur.ReadUleb128()
+				EntryFmt.FormCode = cur.ReadUleb128()
 				lineInfoHdr.DirectoryEntryFormats = append(lineInfoHdr.DirectoryEntryFormats, EntryFmt)
 			}
 
-			lineInfoHdr.DirectoriesCount, sz = ReaduLEB128(bin[offset:])
-			offset += uint64(sz)
+			lineInfoHdr.DirectoriesCount = cur.ReadUleb128()
+			checkCursor(cur)
+			// an entry reads nothing without formats, the count alone would loop
+			if lineInfoHdr.DirectoryEntryFormatCount == 0 && 0 < lineInfoHdr.DirectoriesCount {
+				parseFail(".debug_line", hdrOffset, "%d directories have no entry formats", lineInfoHdr.DirectoriesCount)
+			}
 
 			for i := 0; i < (int)(lineInfoHdr.DirectoriesCount); i++ {
 				for j := 0; j < (int)(lineInfoHdr.DirectoryEntryFormatCount); j++ {
@@ -2525,44 +2384,35 @@ func ReadLineInfo(bin []byte, elfObj elf.ElfObject) map[uint64]Dwarf32LineInfoHd
 						dirName := ""
 						if formCode == DW_FORM_line_strp {
 							// offset in the .debug_str, size follows Dwarf format(4 or 8)
-							var strOffset uint64 = 0
-							shLineStr := elfObj.GetSectionBinByName(".debug_line_str")
-							if lineInfoHdr.DwarfFormat == DWARF_32BIT_FORMAT {
-								// 4byte
-								tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-								offset += 4
-								strOffset = (uint64)(tmp)
-							} else {
-								// 8byte
-								tmp, _ := binutil.FromLeToUInt64(bin[offset:])
-								offset += 8
-								strOffset = tmp
-							}
-							dirName = binutil.GetString(shLineStr, strOffset)
+							strOffset := readSecOffset(cur, lineInfoHdr.DwarfFormat)
+							dirName = lineStrTab.Get(strOffset)
 							logger.DLog(dirName)
+						} else {
+							parseFail(".debug_line", hdrOffset, "directory name form 0x%x is not implemented", formCode)
 						}
 						lineInfoHdr.IncludeDirs = append(lineInfoHdr.IncludeDirs, dirName)
 					default:
-						panic("unknown")
+						parseFail(".debug_line", hdrOffset, "unknown directory entry type 0x%x", typeCode)
 					}
 				}
+				checkCursor(cur)
 			}
 
 			// file names
-			lineInfoHdr.FileNameEntryFormatCount = bin[offset]
-			offset++
+			lineInfoHdr.FileNameEntryFormatCount = cur.ReadU8()
 
 			for i := 0; i < (int)(lineInfoHdr.FileNameEntryFormatCount); i++ {
 				var entryFmt EntryFormat
-				entryFmt.TypeCode, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
-				entryFmt.FormCode, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
+				entryFmt.TypeCode = cur.ReadUleb128()
+				entryFmt.FormCode = cur.ReadUleb128()
 				lineInfoHdr.FileNameEntryFormats = append(lineInfoHdr.FileNameEntryFormats, entryFmt)
 			}
 
-			lineInfoHdr.FileNamesCount, sz = ReaduLEB128(bin[offset:])
-			offset += uint64(sz)
+			lineInfoHdr.FileNamesCount = cur.ReadUleb128()
+			checkCursor(cur)
+			if lineInfoHdr.FileNameEntryFormatCount == 0 && 0 < lineInfoHdr.FileNamesCount {
+				parseFail(".debug_line", hdrOffset, "%d file names have no entry formats", lineInfoHdr.FileNamesCount)
+			}
 
 			for i := 0; i < (int)(lineInfoHdr.FileNamesCount); i++ {
 				fileNameInfo := FileNameInfo{}
@@ -2574,72 +2424,42 @@ func ReadLineInfo(bin []byte, elfObj elf.ElfObject) map[uint64]Dwarf32LineInfoHd
 					case DW_LNCT_path:
 						if formCode == DW_FORM_line_strp {
 							// offset in the .debug_str, size follows Dwarf format(4 or 8)
-							var strOffset uint64 = 0
-							shDbgStr := elfObj.GetSectionBinByName(".debug_line_str")
-							if lineInfoHdr.DwarfFormat == DWARF_32BIT_FORMAT {
-								// 4byte
-								tmp, _ := binutil.FromLeToUInt32(bin[offset:])
-								offset += 4
-								strOffset = (uint64)(tmp)
-							} else {
-								// 8byte
-								tmp, _ := binutil.FromLeToUInt64(bin[offset:])
-								offset += 8
-								strOffset = tmp
-							}
-							fileNameInfo.Name = binutil.GetString(shDbgStr, strOffset)
+							strOffset := readSecOffset(cur, lineInfoHdr.DwarfFormat)
+							fileNameInfo.Name = lineStrTab.Get(strOffset)
 							logger.DLog(fileNameInfo.Name)
 						} else {
-							panic("not implemented")
+							parseFail(".debug_line", hdrOffset, "file name form 0x%x is not implemented", formCode)
 						}
 					case DW_LNCT_directory_index:
 						switch formCode {
 						case DW_FORM_data1:
-							fileIdx = (uint64)(bin[offset])
-							offset++
+							fileIdx = uint64(cur.ReadU8())
 						case DW_FORM_data2:
-							tmp, _ := binutil.FromLeToUInt16(bin[offset:])
-							fileIdx += (uint64)(tmp)
-							offset += 2
+							fileIdx = uint64(cur.ReadU16())
 						case DW_FORM_udata:
-							fileIdx, sz = ReaduLEB128(bin[offset:])
-							offset += uint64(sz)
+							fileIdx = cur.ReadUleb128()
 						default:
-							panic("unknown")
+							parseFail(".debug_line", hdrOffset, "unknown directory index form 0x%x", formCode)
 						}
 						fileNameInfo.DirIdx = fileIdx
 
 					default:
-						panic("unknown")
+						parseFail(".debug_line", hdrOffset, "unknown file name entry type 0x%x", typeCode)
 					}
 				}
+				checkCursor(cur)
 				// TODO save fileIdx info
 				lineInfoHdr.Files = append(lineInfoHdr.Files, fileNameInfo)
 			}
-
-			endOffset := hdrOffset + uint64(lineInfoHdr.UnitLength)
-			if lineInfoHdr.DwarfFormat == DWARF_32BIT_FORMAT {
-				endOffset += 4
-			} else {
-				endOffset += 8
-			}
-
-			fileName := lineInfoHdr.Files[0].Name
-			if 0 < (endOffset - offset) {
-				readLineNumberProgram(fileName, lineInfoHdr, bin, offset, endOffset, elfObj)
-			}
-
 		} else {
 			// include_directories
 			lineInfoHdr.IncludeDirs = []string{}
 			for {
-				dirName := binutil.GetString(bin[offset:], 0)
-				sLen := len(dirName)
-				if sLen == 0 {
-					offset++
+				dirName := cur.ReadCString()
+				checkCursor(cur)
+				if dirName == "" {
 					break
 				}
-				offset += uint64(sLen + 1)
 				lineInfoHdr.IncludeDirs = append(lineInfoHdr.IncludeDirs, dirName)
 			}
 
@@ -2649,52 +2469,45 @@ func ReadLineInfo(bin []byte, elfObj elf.ElfObject) map[uint64]Dwarf32LineInfoHd
 				fileNameInfo := FileNameInfo{}
 
 				// name
-				fileNameInfo.Name = binutil.GetString(bin[offset:], 0)
-				sLen := len(fileNameInfo.Name)
-				if sLen == 0 {
-					offset++
+				fileNameInfo.Name = cur.ReadCString()
+				checkCursor(cur)
+				if fileNameInfo.Name == "" {
 					break
 				}
-				offset += uint64(sLen + 1)
 
 				// directory Idx
-				var sz int
-				fileNameInfo.DirIdx, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
+				fileNameInfo.DirIdx = cur.ReadUleb128()
 
 				// last modified
-				fileNameInfo.LastModified, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
+				fileNameInfo.LastModified = cur.ReadUleb128()
 
 				// file size
-				fileNameInfo.Size, sz = ReaduLEB128(bin[offset:])
-				offset += uint64(sz)
+				fileNameInfo.Size = cur.ReadUleb128()
+				checkCursor(cur)
 
 				lineInfoHdr.Files = append(lineInfoHdr.Files, fileNameInfo)
 			}
+		}
 
-			endOffset := hdrOffset + uint64(lineInfoHdr.UnitLength)
-			if lineInfoHdr.DwarfFormat == DWARF_32BIT_FORMAT {
-				endOffset += 4
-			} else {
-				endOffset += 8
-			}
-
-			fileName := lineInfoHdr.Files[0].Name
-			if 0 < (endOffset - offset) {
-				readLineNumberProgram(fileName, lineInfoHdr, bin, offset, endOffset, elfObj)
-			}
+		fileName := ""
+		if 0 < len(lineInfoHdr.Files) {
+			fileName = lineInfoHdr.Files[0].Name
+		}
+		lnpStart := cur.Pos()
+		if endOffset < lnpStart {
+			parseFail(".debug_line", hdrOffset, "header exceeds unit_length 0x%x", lineInfoHdr.UnitLength)
+		}
+		if lnpStart < endOffset {
+			lnp := binutil.NewLeCursor("line number program", cur.ReadBytes(endOffset-lnpStart))
+			checkCursor(cur)
+			readLineNumberProgram(fileName, lineInfoHdr, lnp, lnpStart, elfObj)
 		}
 
 		offsetLineInfoHdrMap[hdrOffset] = lineInfoHdr
-		hdrOffset += uint64(lineInfoHdr.UnitLength)
-		if lineInfoHdr.DwarfFormat == DWARF_32BIT_FORMAT {
-			hdrOffset += 4
-		} else {
-			hdrOffset += 8
-		}
+		cur.Seek(endOffset)
+		checkCursor(cur)
 	}
-	return offsetLineInfoHdrMap
+	return offsetLineInfoHdrMap, nil
 }
 func NewLnsm(defaultIsStmt uint8) LineNumberStateMachine {
 	lnsm := LineNumberStateMachine{}
@@ -2710,22 +2523,21 @@ func NewLnsm(defaultIsStmt uint8) LineNumberStateMachine {
 	lnsm.Isa = 0
 	return lnsm
 }
-func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, secBin []uint8, lnpStart uint64, lnpEnd uint64, elfObj elf.ElfObject) {
-	lnpIns := secBin[lnpStart:lnpEnd]
-	var length uint64 = uint64(len(lnpIns))
-	var offset uint64 = 0
+
+// readLineNumberProgram runs the program read by lnp, lnpStart is its offset in .debug_line
+func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, lnp *binutil.Cursor, lnpStart uint64, elfObj elf.ElfObject) {
 	var curFuncAddr uint64 = 0
 	lnsm := NewLnsm(lineInfoHdr.DefaultIsStmt)
 
 	var endOfSeq = false
-	for offset < length {
+	for 0 < lnp.Len() {
 		endOfSeq = false
 
-		// read opecode
-		opcode := lnpIns[offset]
-
 		// for debug
-		opOffset := lnpStart + offset
+		opOffset := lnpStart + lnp.Pos()
+
+		// read opecode
+		opcode := lnp.ReadU8()
 		dwLnsName, exist := dwLnsNameMap[opcode]
 		if exist {
 			logger.TLog("[%6x] opcode: %d(0x%x), %s", opOffset, opcode, opcode, dwLnsName)
@@ -2733,13 +2545,10 @@ func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, secB
 			logger.TLog("[%6x] opcode: %d(0x%x)", opOffset, opcode, opcode)
 		}
 
-		offset++
 		switch opcode {
 		case 0x00: // extended opcodes
-			length, size := ReaduLEB128(lnpIns[offset:])
-			offset += uint64(size)
-			extendedOpcode := lnpIns[offset]
-			offset++
+			length := lnp.ReadUleb128()
+			extendedOpcode := lnp.ReadU8()
 			switch extendedOpcode {
 			case DW_LNE_end_sequence:
 				lnsm = NewLnsm(lineInfoHdr.DefaultIsStmt)
@@ -2747,61 +2556,44 @@ func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, secB
 				// break parse loop
 				endOfSeq = true
 			case DW_LNE_set_address:
-				addrSize := length - 1
-				if addrSize == 8 {
-					address, _ := binutil.FromLeToUInt64(lnpIns[offset:])
-					lnsm.Address = address
-					curFuncAddr = address
-				} else {
-					address, _ := binutil.FromLeToUInt32(lnpIns[offset:])
-					lnsm.Address = uint64(address)
-					curFuncAddr = uint64(address)
-				}
-				offset += length - 1
+				address := lnp.ReadUint(int(length - 1))
+				lnsm.Address = address
+				curFuncAddr = address
 			case DW_LNE_define_file:
 				// TODO
-				offset += length - 1
+				lnp.Skip(length - 1)
 			case DW_LNE_set_discriminator:
 				// TODO
 				// Bug. gcc version 9.3.0 (Ubuntu 9.3.0-17ubuntu1~20.04)
 				// DW_LNE_set_discriminator is defined DWARF4, but section header's DWARF version is 3...
-				discriminator, size := ReaduLEB128(lnpIns[offset:])
-				lnsm.Discriminator = discriminator
-				offset += uint64(size)
+				lnsm.Discriminator = lnp.ReadUleb128()
 			case DW_LNE_lo_user:
 				// TODO
-				offset += length - 1
+				lnp.Skip(length - 1)
 			case DW_LNE_hi_user:
 				// TODO
-				offset += length - 1
+				lnp.Skip(length - 1)
 			default:
-				msg := fmt.Sprintf("Unexpected extended opcode:%d(0x%x)", extendedOpcode, extendedOpcode)
-				panic(msg)
+				parseFail("line number program", opOffset, "unexpected extended opcode %d(0x%x)", extendedOpcode, extendedOpcode)
 			}
 		case DW_LNS_copy:
 			if lnsm.IsStmt {
-				addFuncAddrLineInfo(lineInfoHdr, lnsm, curFuncAddr, elfObj)
+				addFuncAddrLineInfo(lineInfoHdr, lnsm, curFuncAddr, elfObj, opOffset)
 			}
 			lnsm.BasicBlock = false
 			lnsm.PrologueEnd = false
 			lnsm.EpilogueBegin = false
 		case DW_LNS_advance_pc:
-			addrInc, size := ReaduLEB128(lnpIns[offset:])
+			addrInc := lnp.ReadUleb128()
 			lnsm.Address += addrInc * uint64(lineInfoHdr.MinInstLength)
-			offset += uint64(size)
 		case DW_LNS_advance_line:
-			lineInc, size := ReadsLEB128(lnpIns[offset:])
+			lineInc := lnp.ReadSleb128()
 			lnsm.Line = uint64(int64(lnsm.Line) + lineInc)
-			offset += uint64(size)
 		case DW_LNS_set_file:
-			fileIdx, size := ReaduLEB128(lnpIns[offset:])
-			lnsm.File = fileIdx
-			offset += uint64(size)
+			lnsm.File = lnp.ReadUleb128()
 		case DW_LNS_set_column:
 			// column set
-			coperand, size := ReaduLEB128(lnpIns[offset:])
-			lnsm.Column = coperand
-			offset += uint64(size)
+			lnsm.Column = lnp.ReadUleb128()
 		case DW_LNS_negate_stmt:
 			// no operand
 			lnsm.IsStmt = !(lnsm.IsStmt)
@@ -2817,17 +2609,15 @@ func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, secB
 		case DW_LNS_fixed_advance_pc:
 			// The DW_LNS_fixed_advance_pc opcode takes a single uhalf (unencoded) operand
 			// and adds it to the address register of the state machine and sets the op_index register to 0.
-			address, _ := binutil.FromLeToUInt16(lnpIns[offset:])
+			address := lnp.ReadU16()
 			lnsm.Address = uint64(int64(lnsm.Address) + int64(address))
 			lnsm.OpIndex = 0
-			offset += 2
 		case DW_LNS_set_prologue_end:
 			lnsm.PrologueEnd = true
 		case DW_LNS_set_epilogue_begin:
 			lnsm.EpilogueBegin = true
 		case DW_LNS_set_isa:
-			_, size := ReadsLEB128(lnpIns[offset:])
-			offset += uint64(size)
+			lnsm.Isa = lnp.ReadUleb128()
 		default:
 			// special opcode
 			// no operand
@@ -2848,20 +2638,32 @@ func readLineNumberProgram(fileName string, lineInfoHdr Dwarf32LineInfoHdr, secB
 			lnsm.EpilogueBegin = false
 			curFuncAddr = lnsm.Address
 			if lnsm.IsStmt {
-				addFuncAddrLineInfo(lineInfoHdr, lnsm, curFuncAddr, elfObj)
+				addFuncAddrLineInfo(lineInfoHdr, lnsm, curFuncAddr, elfObj, opOffset)
 			}
 			logger.DLog("special opcode:0x%02X, address inc:%d, line inc:%d\n", opcode, addrInc, lineInc)
 		}
+		checkCursor(lnp)
 	}
 
 	if !endOfSeq {
-		panic("Error DW_LNE_end_sequence not found")
+		parseFail("line number program", lnpStart, "DW_LNE_end_sequence not found")
 	}
 	return
 }
 
-func addFuncAddrLineInfo(lineInfoHdr Dwarf32LineInfoHdr, lnsm LineNumberStateMachine, funcAddr uint64, elfObj elf.ElfObject) {
+// addFuncAddrLineInfo adds the row of lnsm to the function at funcAddr, opOffset is the offset of the opcode emitting it
+func addFuncAddrLineInfo(lineInfoHdr Dwarf32LineInfoHdr, lnsm LineNumberStateMachine, funcAddr uint64, elfObj elf.ElfObject, opOffset uint64) {
+	if lnsm.File == 0 || uint64(len(lineInfoHdr.Files)) < lnsm.File {
+		parseFail("line number program", opOffset, "file %d is not in the %d file names", lnsm.File, len(lineInfoHdr.Files))
+	}
 	file := lineInfoHdr.Files[lnsm.File-1]
+	dirIdx := file.DirIdx
+	if lineInfoHdr.Version < 5 && 0 < dirIdx {
+		dirIdx--
+	}
+	if (5 <= lineInfoHdr.Version || 0 < file.DirIdx) && uint64(len(lineInfoHdr.IncludeDirs)) <= dirIdx {
+		parseFail("line number program", opOffset, "directory %d of %s is not in the %d directories", file.DirIdx, file.Name, len(lineInfoHdr.IncludeDirs))
+	}
 	funcIdx := elfObj.GetFuncIdxByAddr(funcAddr)
 	if funcIdx < 0 {
 		logger.DLog("function not exist in %s, funcAddr:0x%x\n", elfObj.GetPath, funcAddr)
@@ -2925,69 +2727,57 @@ func ShowLineInfoHdr(lineInfoHdr Dwarf32LineInfoHdr) {
 			lineInfoHdr.Files[i].Size)
 	}
 }
-func NewDwarf32Cuh(debug_info []byte) Dwarf32CuHdr {
+func NewDwarf32Cuh(debug_info []byte) (Dwarf32CuHdr, error) {
+	return readDwarf32Cuh(binutil.NewLeCursor("Dwarf32CuHdr", debug_info))
+}
+
+// readDwarf32Cuh reads a unit header at the position of cur and moves past it
+func readDwarf32Cuh(cur *binutil.Cursor) (Dwarf32CuHdr, error) {
 	cuh := Dwarf32CuHdr{}
-	var offset uintptr = 0
-	tmp, _ := binutil.FromLeToUInt32(debug_info[0:4])
-	offset += 4
+	tmp := cur.ReadU32()
 	if tmp < 0xFFFFFF00 {
 		// 32-bit DWARF Format
 		cuh.UnitLength = uint64(tmp)
 		cuh.DwarfFormat = DWARF_32BIT_FORMAT
 	} else {
 		// 64-bit DWARF Format
-		cuh.UnitLength, _ = binutil.FromLeToUInt64(debug_info[offset:])
+		cuh.UnitLength = cur.ReadU64()
 		cuh.DwarfFormat = DWARF_64BIT_FORMAT
-		offset += 8
 	}
 
-	size := unsafe.Sizeof(uint16(0))
-	cuh.Version, _ = binutil.FromLeToUInt16(debug_info[offset:])
-	offset += size
+	cuh.Version = cur.ReadU16()
 	if cuh.Version < 5 {
 		// debug_abbrev_offset
-		cuh.DebugAbbrevOffset, _ = binutil.FromLeToUInt32(debug_info[offset:])
-		offset += 4
+		cuh.DebugAbbrevOffset = cur.ReadU32()
 
 		// address_size
-		size = unsafe.Sizeof(uint8(0))
-		cuh.AddressSize = debug_info[offset]
-		offset++
+		cuh.AddressSize = cur.ReadU8()
 	} else {
 		// DWARF 5 or later
-		cuh.UnitType = debug_info[offset]
-		offset++
+		unitTypeOffset := cur.Pos()
+		cuh.UnitType = cur.ReadU8()
 
 		// address_size
-		cuh.AddressSize = debug_info[offset]
-		offset++
+		cuh.AddressSize = cur.ReadU8()
 
 		// debug_abbrev_offset
-		cuh.DebugAbbrevOffset, _ = binutil.FromLeToUInt32(debug_info[offset:])
-		offset += 4
+		cuh.DebugAbbrevOffset = cur.ReadU32()
+		if cur.Err() != nil {
+			return cuh, cur.Err()
+		}
 
 		switch cuh.UnitType {
 		case DW_UT_compile, DW_UT_partial:
 		case DW_UT_skeleton, DW_UT_split_compile:
-			cuh.UnitID, _ = binutil.FromLeToUInt64(debug_info[offset:])
-			offset += 8
+			cuh.UnitID = cur.ReadU64()
 		case DW_UT_type, DW_UT_split_type:
-			cuh.TypeSignature, _ = binutil.FromLeToUInt64(debug_info[offset:])
-			offset += 8
-			cuh.TypeSignature, _ = binutil.FromLeToUInt64(debug_info[offset:])
-			if cuh.DwarfFormat == DWARF_32BIT_FORMAT {
-				tmp, _ = binutil.FromLeToUInt32(debug_info[offset:])
-				cuh.TypeOffset = (uint64)(tmp)
-				offset += 8
-			} else {
-				cuh.TypeOffset, _ = binutil.FromLeToUInt64(debug_info[offset:])
-			}
+			cuh.TypeSignature = cur.ReadU64()
+			cuh.TypeOffset = readSecOffset(cur, cuh.DwarfFormat)
 		default:
-			panic("not implemented")
+			return cuh, binutil.NewParseError("Dwarf32CuHdr", unitTypeOffset, "unit_type 0x%x is not implemented", cuh.UnitType)
 		}
 	}
-
-	return cuh
+	return cuh, cur.Err()
 }
 
 type AbbrevAttr struct {
@@ -3033,49 +2823,33 @@ func ShowAbbrevTbl(abbrevTbl []Abbrev) {
 	}
 }
 
-func ReadAbbrevTbl(bytes []byte) []Abbrev {
+func ReadAbbrevTbl(bin []byte) ([]Abbrev, error) {
 	abbrevTbl := []Abbrev{}
-	len := len(bytes)
-	pos := 0
-	var id uint64
-	var tag uint64
-	var hasChildren byte
-	var size int
-	for pos < len {
+	cur := binutil.NewLeCursor("abbreviation", bin)
+	for 0 < cur.Len() {
 		abbrev := Abbrev{}
 		abbrev.Attrs = []AbbrevAttr{}
-		id, size = ReaduLEB128(bytes[pos:])
-		pos += size
-		if id == 0 {
+		abbrev.Id = cur.ReadUleb128()
+		if abbrev.Id == 0 {
 			// Abbreviations Tables end with an entry consisting of a 0 byte for the abbreviation code.
 			break
 		}
-		tag, size = ReaduLEB128(bytes[pos:])
-		pos += size
-		hasChildren = bytes[pos]
-		pos++
-		abbrev.Id = id
-		abbrev.Tag = tag
-		abbrev.HasChildren = hasChildren == DW_CHILDREN_yes
+		abbrev.Tag = cur.ReadUleb128()
+		abbrev.HasChildren = cur.ReadU8() == DW_CHILDREN_yes
 
 		// Read Attributes
-		for {
-			var attrCode uint64
-			var formCode uint64
-			var Const uint64
-			attrCode, size = ReaduLEB128(bytes[pos:])
-			pos += size
-
-			formCode, size = ReaduLEB128(bytes[pos:])
-			pos += size
+		for cur.Err() == nil {
+			attrCode := cur.ReadUleb128()
+			formCode := cur.ReadUleb128()
 			if attrCode == 0 && formCode == 0 {
 				break
 			}
 
 			// DWARF5 or later, FORM special case
+			var Const uint64
 			if formCode == DW_FORM_implicit_const {
-				Const, size = ReaduLEB128(bytes[pos:])
-				pos += size
+				// a signed LEB128, sign extended
+				Const = uint64(cur.ReadSleb128())
 			}
 
 			attr := AbbrevAttr{Attr: attrCode, Form: formCode, Const: Const}
@@ -3083,45 +2857,24 @@ func ReadAbbrevTbl(bytes []byte) []Abbrev {
 
 			abbrev.Attrs = append(abbrev.Attrs, attr)
 		}
+		if cur.Err() != nil {
+			return abbrevTbl, cur.Err()
+		}
 		abbrevTbl = append(abbrevTbl, abbrev)
 	}
-	return abbrevTbl
+	return abbrevTbl, cur.Err()
 }
 
-func ReaduLEB128(bytes []byte) (uint64, int) {
-	len := len(bytes)
-	pos := 0
-	var val uint64 = 0
-	var tmp uint64
-	for pos < len {
-		tmp = uint64(bytes[pos] & 0x7F)
-		tmp = tmp << (7 * pos)
-		val += tmp
-		if bytes[pos]&0x80 == 0 {
-			break
-		}
-		pos++
-	}
-	return val, pos + 1
+// ReaduLEB128 returns an unsigned LEB128 value and its size, the size is 0 when bin ends inside the value
+func ReaduLEB128(bin []byte) (uint64, int) {
+	cur := binutil.NewLeCursor("ULEB128", bin)
+	val := cur.ReadUleb128()
+	return val, int(cur.Pos())
 }
-func ReadsLEB128(bytes []byte) (int64, int) {
-	len := len(bytes)
-	pos := 0
-	var val int64 = 0
-	var tmp int64
-	for pos < len {
-		tmp = int64(bytes[pos] & 0x7F)
-		tmp = tmp << (7 * pos)
-		val += tmp
-		if bytes[pos]&0x80 == 0 {
-			if bytes[pos]&0x40 != 0 {
-				// signed
-				// TODO
-				val |= ^0 << (7 * (pos + 1))
-			}
-			break
-		}
-		pos++
-	}
-	return val, pos + 1
+
+// ReadsLEB128 returns a signed LEB128 value and its size, the size is 0 when bin ends inside the value
+func ReadsLEB128(bin []byte) (int64, int) {
+	cur := binutil.NewLeCursor("SLEB128", bin)
+	val := cur.ReadSleb128()
+	return val, int(cur.Pos())
 }
@@ -0,0 +1,105 @@
+package dwarf
+
+import (
+	"os"
+	elf "sym-exposer/elf"
+	"testing"
+)
+
+var seedPaths = []string{
+	"../testdata/func_x86_64.o",
+	"../testdata/func_x86_64_dwarf4.o",
+	"../testdata/func_x86_64_split.o",
+	"../testdata/types_x86_64_dwarf4.o",
+}
+
+func readSeeds(f *testing.F) [][]byte {
+	bins := [][]byte{}
+	for _, path := range seedPaths {
+		bin, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if _, err := elf.NewElf64(path, bin); err != nil {
+			f.Fatal(err)
+		}
+		bins = append(bins, bin)
+	}
+	return bins
+}
+
+// newSeedObjects parses the seed objects again, reading line info updates their function infos
+func newSeedObjects(bins [][]byte) []*elf.Elf64Object {
+	elfObjs := []*elf.Elf64Object{}
+	for i, bin := range bins {
+		elfObj, _ := elf.NewElf64(seedPaths[i], bin)
+		elfObjs = append(elfObjs, elfObj)
+	}
+	return elfObjs
+}
+
+// addSectionSeeds adds the named section of every seed object to the corpus,
+// with the relocations of the object applied
+func addSectionSeeds(f *testing.F, bins [][]byte, name string) {
+	for _, elfObj := range newSeedObjects(bins) {
+		bin, err := elfObj.GetRelocatedSectionBinByName(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(bin)
+	}
+}
+
+func FuzzReadAbbrevTbl(f *testing.F) {
+	addSectionSeeds(f, readSeeds(f), ".debug_abbrev")
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		ReadAbbrevTbl(bin)
+	})
+}
+
+func FuzzReadAranges(f *testing.F) {
+	addSectionSeeds(f, readSeeds(f), ".debug_aranges")
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		ReadAranges(bin)
+	})
+}
+
+func FuzzReadFrameInfo(f *testing.F) {
+	addSectionSeeds(f, readSeeds(f), ".eh_frame")
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		ReadFrameInfo(bin)
+	})
+}
+
+func FuzzReadLineInfo(f *testing.F) {
+	bins := readSeeds(f)
+	addSectionSeeds(f, bins, ".debug_line")
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		// the line program refers to .debug_line_str and the functions of the object
+		for _, elfObj := range newSeedObjects(bins) {
+			ReadLineInfo(bin, elfObj)
+		}
+	})
+}
+
+func FuzzReadDebugInfo(f *testing.F) {
+	bins := readSeeds(f)
+	addSectionSeeds(f, bins, ".debug_info")
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		for _, elfObj := range newSeedObjects(bins) {
+			aranges, _ := elfObj.GetRelocatedSectionBinByName(".debug_aranges")
+			arangeMap, _ := ReadAranges(aranges)
+			debugLine, _ := elfObj.GetRelocatedSectionBinByName(".debug_line")
+			lineInfoMap, _ := ReadLineInfo(debugLine, elfObj)
+			ReadDebugInfo(arangeMap, bin, elfObj, lineInfoMap)
+			tree, _ := ReadDebugInfoTree(bin, elfObj)
+			tree.Walk(func(e *Entry) bool {
+				e.QualifiedName()
+				e.PcRange()
+				tree.Ref(e, DW_AT_type)
+				tree.Ref(e, DW_AT_specification)
+				return true
+			})
+		}
+	})
+}
@@ -0,0 +1,446 @@
+package dwarf
+
+import (
+	"strings"
+
+	binutil "sym-exposer/binutil"
+	"sym-exposer/elf"
+)
+
+// MAX_SPECIFICATION_DEPTH bounds the walks of DW_AT_specification chains, which only loop in broken DWARF
+const MAX_SPECIFICATION_DEPTH = 16
+
+// Attribute is an attribute of an Entry with its value decoded. Strings are
+// read from their string sections, index forms are resolved through the
+// bases of the unit and unit relative references are made section offsets.
+type Attribute struct {
+	Attr  uint64
+	Form  uint64 // the form selected by DW_FORM_indirect replaces it
+	Val   uint64 // constant, address, flag, reference, section offset or string index, signed constants are sign extended
+	Str   string // string forms
+	Block []byte // block, exprloc and data16 forms, sharing memory with the section
+	// Unresolved is set for an index form whose section is not in the object,
+	// such as an address of a split unit, Val keeps the index
+	Unresolved bool
+}
+
+// IsAddress reports whether the attribute is of the address class,
+// a DW_AT_high_pc of a constant form is an offset from DW_AT_low_pc instead
+func (a Attribute) IsAddress() bool {
+	switch a.Form {
+	case DW_FORM_addr, DW_FORM_addrx, DW_FORM_addrx1, DW_FORM_addrx2, DW_FORM_addrx3, DW_FORM_addrx4, DW_FORM_GNU_addr_index:
+		return true
+	}
+	return false
+}
+
+func (a Attribute) IsConstant() bool {
+	switch a.Form {
+	case DW_FORM_data1, DW_FORM_data2, DW_FORM_data4, DW_FORM_data8, DW_FORM_sdata, DW_FORM_udata, DW_FORM_implicit_const:
+		return true
+	}
+	return false
+}
+
+func (a Attribute) IsReference() bool {
+	switch a.Form {
+	case DW_FORM_ref1, DW_FORM_ref2, DW_FORM_ref4, DW_FORM_ref8, DW_FORM_ref_udata, DW_FORM_ref_addr,
+		DW_FORM_ref_sig8, DW_FORM_ref_sup4, DW_FORM_ref_sup8, DW_FORM_GNU_ref_alt:
+		return true
+	}
+	return false
+}
+
+func (a Attribute) IsString() bool {
+	switch a.Form {
+	case DW_FORM_string, DW_FORM_strp, DW_FORM_line_strp,
+		DW_FORM_strx, DW_FORM_strx1, DW_FORM_strx2, DW_FORM_strx3, DW_FORM_strx4, DW_FORM_GNU_str_index:
+		return true
+	}
+	return false
+}
+
+// Entry is a debugging information entry (DIE) in the tree of its unit
+type Entry struct {
+	Offset     uint64 // offset in .debug_info, or in its .debug_types for a DWARF 4 type unit
+	Tag        uint64
+	Attributes []Attribute
+	Children   []*Entry
+	Parent     *Entry // nil for the unit entry
+	Unit       *Unit
+}
+
+// Attr returns the attribute attr of the entry
+func (e *Entry) Attr(attr uint64) (Attribute, bool) {
+	for _, a := range e.Attributes {
+		if a.Attr == attr {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Val returns the value of attribute attr, false when the entry does not have it
+func (e *Entry) Val(attr uint64) (uint64, bool) {
+	a, exist := e.Attr(attr)
+	return a.Val, exist
+}
+
+// Str returns the string of attribute attr, "" when the entry does not have it
+func (e *Entry) Str(attr uint64) string {
+	a, _ := e.Attr(attr)
+	return a.Str
+}
+
+// Flag reports whether the flag attr is set, DW_FORM_flag_present is set by being there
+func (e *Entry) Flag(attr uint64) bool {
+	a, exist := e.Attr(attr)
+	return exist && a.Val != 0
+}
+
+func (e *Entry) Name() string {
+	return e.Str(DW_AT_name)
+}
+
+// PcRange returns the addresses of DW_AT_low_pc and the end of the entry,
+// false when it has no address
+func (e *Entry) PcRange() (uint64, uint64, bool) {
+	low, exist := e.Attr(DW_AT_low_pc)
+	if !exist || !low.IsAddress() || low.Unresolved {
+		return 0, 0, false
+	}
+	high, exist := e.Attr(DW_AT_high_pc)
+	if !exist || high.Unresolved {
+		return low.Val, low.Val, true
+	}
+	if high.IsAddress() {
+		return low.Val, high.Val, true
+	}
+	return low.Val, low.Val + high.Val, true
+}
+
+// ScopeParent returns the entry e is declared in: the parent of the declaration
+// DW_AT_specification refers to in the unit, else the parent of e. g++ defines
+// the type of a DWARF 4 type unit at the top of the unit, with a specification
+// of it declared in its namespaces.
+func (e *Entry) ScopeParent() *Entry {
+	decl := e
+	for i := 0; i <= MAX_SPECIFICATION_DEPTH; i++ {
+		a, exist := decl.Attr(DW_AT_specification)
+		if !exist || decl.Unit == nil {
+			break
+		}
+		switch a.Form {
+		case DW_FORM_ref1, DW_FORM_ref2, DW_FORM_ref4, DW_FORM_ref8, DW_FORM_ref_udata:
+		default:
+			return decl.Parent
+		}
+		spec := decl.Unit.entries[a.Val]
+		if spec == nil || spec == decl {
+			break
+		}
+		decl = spec
+	}
+	return decl.Parent
+}
+
+// QualifiedName returns the name of the entry prefixed by the names of its enclosing
+// namespaces, classes, structures and unions, like ns::klass::func
+func (e *Entry) QualifiedName() string {
+	names := []string{e.Name()}
+	for scope := e.ScopeParent(); scope != nil; scope = scope.Parent {
+		switch scope.Tag {
+		case DW_TAG_namespace:
+			name := scope.Name()
+			if name == "" {
+				name = "(anonymous namespace)"
+			}
+			names = append(names, name)
+		case DW_TAG_class_type, DW_TAG_structure_type, DW_TAG_union_type, DW_TAG_enumeration_type:
+			names = append(names, scope.Name())
+		}
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, "::")
+}
+
+// Walk calls fn for the entry and its descendants in the order of the section,
+// fn returns false to skip the children of an entry
+func (e *Entry) Walk(fn func(*Entry) bool) {
+	if !fn(e) {
+		return
+	}
+	for _, child := range e.Children {
+		child.Walk(fn)
+	}
+}
+
+// Unit is a unit of .debug_info or .debug_types with the tree of its entries
+type Unit struct {
+	Offset  uint64 // offset of the unit header
+	Hdr     Dwarf32CuHdr
+	Root    *Entry // DW_TAG_compile_unit, DW_TAG_type_unit, ...
+	InTypes bool   // a DWARF 4 type unit of .debug_types
+	bases   unitBases
+	entries map[uint64]*Entry // of the section of the unit by offset
+}
+
+// IsTypeUnit reports whether the unit holds a type referred to by DW_FORM_ref_sig8
+func (u *Unit) IsTypeUnit() bool {
+	return u.InTypes || u.Hdr.UnitType == DW_UT_type || u.Hdr.UnitType == DW_UT_split_type
+}
+
+// DebugInfo is the tree of the entries of all the units of an object
+type DebugInfo struct {
+	Units     []*Unit
+	entries   map[uint64]*Entry // of .debug_info by offset
+	typeUnits map[uint64]*Unit  // by type signature, of all the .debug_types sections
+	sections  indexSections
+}
+
+// EntryAt returns the entry at offset in .debug_info, nil if there is none
+func (d *DebugInfo) EntryAt(offset uint64) *Entry {
+	return d.entries[offset]
+}
+
+// TypeEntry returns the type entry of the type unit of signature, nil if there is none
+func (d *DebugInfo) TypeEntry(signature uint64) *Entry {
+	u, exist := d.typeUnits[signature]
+	if !exist {
+		return nil
+	}
+	return u.entries[u.Offset+u.Hdr.TypeOffset]
+}
+
+// Ref returns the entry the reference attribute attr of e refers to. References into
+// other units and type units are followed, nil is returned for the references into
+// supplementary object files and references to entries that are not there.
+func (d *DebugInfo) Ref(e *Entry, attr uint64) *Entry {
+	a, exist := e.Attr(attr)
+	if !exist {
+		return nil
+	}
+	var ref *Entry
+	switch a.Form {
+	case DW_FORM_ref1, DW_FORM_ref2, DW_FORM_ref4, DW_FORM_ref8, DW_FORM_ref_udata:
+		// within the unit, a .debug_types section has offsets of its own
+		ref = e.Unit.entries[a.Val]
+	case DW_FORM_ref_addr:
+		ref = d.entries[a.Val]
+	case DW_FORM_ref_sig8:
+		return d.TypeEntry(a.Val)
+	}
+	// a type moved to a type unit leaves a stub with its signature
+	if ref != nil && attr != DW_AT_signature {
+		if typ := d.Ref(ref, DW_AT_signature); typ != nil {
+			return typ
+		}
+	}
+	return ref
+}
+
+// Walk calls Entry.Walk for the unit entry of every unit
+func (d *DebugInfo) Walk(fn func(*Entry) bool) {
+	for _, u := range d.Units {
+		if u.Root != nil {
+			u.Root.Walk(fn)
+		}
+	}
+}
+
+// ReadElfDebugInfoTree reads the entries of .debug_info and .debug_types of an object,
+// with the relocations of a relocatable object applied
+func ReadElfDebugInfoTree(elfObj elf.ElfObject) (*DebugInfo, error) {
+	debugInfo, err := elfObj.GetRelocatedSectionBinByName(".debug_info")
+	if err != nil {
+		return nil, err
+	}
+	d, err := ReadDebugInfoTree(debugInfo, elfObj)
+	if err != nil {
+		return d, err
+	}
+	// one .debug_types per type unit with g++ -fdebug-types-section
+	debugTypes, err := elfObj.GetRelocatedSectionBinsByName(".debug_types")
+	if err != nil {
+		return d, err
+	}
+	for _, bin := range debugTypes {
+		if err := d.readUnits(".debug_types", bin, elfObj, map[uint64]*Entry{}); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// ReadDebugInfoTree reads the entries of debug_info into a tree.
+// On an error the units read before the broken one are returned with it.
+func ReadDebugInfoTree(debug_info []byte, elfObj elf.ElfObject) (*DebugInfo, error) {
+	d := &DebugInfo{
+		Units:     []*Unit{},
+		entries:   map[uint64]*Entry{},
+		typeUnits: map[uint64]*Unit{},
+	}
+	sections, err := readIndexSections(elfObj)
+	if err != nil {
+		return d, err
+	}
+	d.sections = sections
+	return d, d.readUnits(".debug_info", debug_info, elfObj, d.entries)
+}
+
+// readUnits reads the units of the section bin into entries, the entries of the section by offset
+func (d *DebugInfo) readUnits(secName string, bin []byte, elfObj elf.ElfObject, entries map[uint64]*Entry) (err error) {
+	cur := binutil.NewLeCursor(secName, bin)
+	var unitTop uint64 = 0
+	defer recoverParseError(&err)
+
+	inTypes := secName == ".debug_types"
+	debug_abbrev := elfObj.GetSectionBinByName(".debug_abbrev")
+	lineStrTab := binutil.NewStrTab(elfObj.GetSectionBinByName(".debug_line_str"))
+	// units of an object mostly share one abbreviation table
+	abbrevMaps := map[uint32]map[uint64]Abbrev{}
+	for 0 < cur.Len() {
+		unitTop = cur.Pos()
+		cuh, err := readDwarf32Cuh(cur)
+		if err != nil {
+			return err
+		}
+		if inTypes {
+			// the DWARF 4 type unit header
+			cuh.TypeSignature = cur.ReadU64()
+			cuh.TypeOffset = readSecOffset(cur, cuh.DwarfFormat)
+			checkCursor(cur)
+		}
+
+		abbrevMap, exist := abbrevMaps[cuh.DebugAbbrevOffset]
+		if !exist {
+			if uint64(len(debug_abbrev)) < uint64(cuh.DebugAbbrevOffset) {
+				msg := "debug_abbrev_offset 0x%x is out of .debug_abbrev"
+				return binutil.NewParseError(secName, unitTop, msg, cuh.DebugAbbrevOffset)
+			}
+			abbrevTbl, err := ReadAbbrevTbl(debug_abbrev[cuh.DebugAbbrevOffset:])
+			if err != nil {
+				return err
+			}
+			abbrevMap = map[uint64]Abbrev{}
+			for _, abbrev := range abbrevTbl {
+				abbrevMap[abbrev.Id] = abbrev
+			}
+			abbrevMaps[cuh.DebugAbbrevOffset] = abbrevMap
+		}
+
+		var unitEnd uint64 = unitTop + cuh.UnitLength + 4
+		if cuh.DwarfFormat == DWARF_64BIT_FORMAT {
+			unitEnd = unitTop + cuh.UnitLength + 12
+		}
+		u := &Unit{Offset: unitTop, Hdr: cuh, InTypes: inTypes, entries: entries}
+		u.bases = readUnitBases(bin, cur.Pos(), abbrevMap, cuh)
+
+		// the entry new entries are children of, a null entry ends the children
+		var parent *Entry
+		for cur.Pos() < unitEnd {
+			entryOffset := cur.Pos()
+			id := cur.ReadUleb128()
+			checkCursor(cur)
+			if id == 0 {
+				if parent != nil {
+					parent = parent.Parent
+				}
+				continue
+			}
+
+			abbrev, exist := abbrevMap[id]
+			if !exist {
+				parseFail("DIE", entryOffset, "unknown abbreviation code %d", id)
+			}
+			e := &Entry{Offset: entryOffset, Tag: abbrev.Tag, Parent: parent, Unit: u}
+			e.Attributes = make([]Attribute, 0, len(abbrev.Attrs))
+			for _, abbrevAttr := range abbrev.Attrs {
+				e.Attributes = append(e.Attributes, d.readAttribute(cur, abbrevAttr, u, lineStrTab))
+				checkCursor(cur)
+			}
+
+			if parent != nil {
+				parent.Children = append(parent.Children, e)
+			} else if u.Root == nil {
+				u.Root = e
+			} else {
+				// entries following the unit entry are kept as its children
+				e.Parent = u.Root
+				u.Root.Children = append(u.Root.Children, e)
+			}
+			entries[entryOffset] = e
+			if abbrev.HasChildren {
+				parent = e
+			}
+		}
+		d.Units = append(d.Units, u)
+		if u.IsTypeUnit() {
+			d.typeUnits[cuh.TypeSignature] = u
+		}
+	}
+	return nil
+}
+
+func (d *DebugInfo) readAttribute(cur *binutil.Cursor, abbrevAttr AbbrevAttr, u *Unit, lineStrTab *binutil.StrTab) Attribute {
+	a := Attribute{Attr: abbrevAttr.Attr, Form: abbrevAttr.Form}
+	if a.Form == DW_FORM_indirect {
+		// the form is given in the DIE
+		a.Form = cur.ReadUleb128()
+	}
+	cuh := u.Hdr
+	var ok bool
+	switch a.Form {
+	case DW_FORM_string:
+		a.Str = cur.ReadCString()
+	case DW_FORM_strp:
+		a.Val = readSecOffset(cur, cuh.DwarfFormat)
+		a.Str = d.sections.strTab.Get(a.Val)
+	case DW_FORM_line_strp:
+		a.Val = readSecOffset(cur, cuh.DwarfFormat)
+		a.Str = lineStrTab.Get(a.Val)
+	case DW_FORM_strx, DW_FORM_strx1, DW_FORM_strx2, DW_FORM_strx3, DW_FORM_strx4, DW_FORM_GNU_str_index:
+		a.Val = readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+		a.Str, ok = d.sections.getStr(a.Val, u.bases, cuh)
+		a.Unresolved = !ok
+	case DW_FORM_addrx, DW_FORM_addrx1, DW_FORM_addrx2, DW_FORM_addrx3, DW_FORM_addrx4, DW_FORM_GNU_addr_index:
+		idx := readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+		a.Val, ok = d.sections.getAddr(idx, u.bases, cuh)
+		if !ok {
+			a.Val = idx
+			a.Unresolved = true
+		}
+	case DW_FORM_rnglistx:
+		idx := readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+		a.Val, ok = d.sections.getRnglist(idx, u.bases, cuh)
+		if !ok {
+			a.Val = idx
+			a.Unresolved = true
+		}
+	case DW_FORM_loclistx:
+		idx := readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+		a.Val, ok = d.sections.getLoclist(idx, u.bases, cuh)
+		if !ok {
+			a.Val = idx
+			a.Unresolved = true
+		}
+	case DW_FORM_ref1, DW_FORM_ref2, DW_FORM_ref4, DW_FORM_ref8, DW_FORM_ref_udata:
+		// an offset from the unit header
+		a.Val = u.Offset + readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+	case DW_FORM_block1:
+		a.Block = cur.ReadBytes(uint64(cur.ReadU8()))
+	case DW_FORM_block2:
+		a.Block = cur.ReadBytes(uint64(cur.ReadU16()))
+	case DW_FORM_block4:
+		a.Block = cur.ReadBytes(uint64(cur.ReadU32()))
+	case DW_FORM_block, DW_FORM_exprloc:
+		a.Block = cur.ReadBytes(cur.ReadUleb128())
+	case DW_FORM_data16:
+		a.Block = cur.ReadBytes(16)
+	default:
+		a.Val = readFormValue(cur, a.Form, abbrevAttr.Const, cuh)
+	}
+	return a
+}
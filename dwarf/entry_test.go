@@ -0,0 +1,174 @@
+package dwarf
+
+import (
+	"os"
+	elf "sym-exposer/elf"
+	"testing"
+)
+
+// findEntry returns the first entry of d with the tag and name, nil if there is none
+func findEntry(d *DebugInfo, tag uint64, name string) *Entry {
+	var found *Entry
+	d.Walk(func(e *Entry) bool {
+		if found == nil && e.Tag == tag && e.Name() == name {
+			found = e
+		}
+		return found == nil
+	})
+	return found
+}
+
+// typeUnderPointer returns the type a parameter of type const T * points to
+func typeUnderPointer(d *DebugInfo, param *Entry) *Entry {
+	t := d.Ref(param, DW_AT_type)
+	for t != nil && (t.Tag == DW_TAG_pointer_type || t.Tag == DW_TAG_const_type) {
+		t = d.Ref(t, DW_AT_type)
+	}
+	return t
+}
+
+func TestReadElfDebugInfoTreeTypeSections(t *testing.T) {
+	// g++ -O0 -gdwarf-4 -fdebug-types-section -c types.cpp, a .debug_types per type unit
+	path := "../testdata/types_x86_64_dwarf4.o"
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elfObj, err := elf.NewElf64(path, bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := elfObj.GetRelocatedSectionBinsByName(".debug_types")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) < 3 {
+		t.Fatalf("%d .debug_types sections, want a section per type unit", len(sections))
+	}
+	d, err := ReadElfDebugInfoTree(elfObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeUnits := 0
+	for _, u := range d.Units {
+		if u.InTypes {
+			typeUnits++
+		}
+	}
+	if typeUnits != len(sections) {
+		t.Errorf("%d type units, want %d", typeUnits, len(sections))
+	}
+
+	apply := findEntry(d, DW_TAG_subprogram, "apply")
+	if apply == nil {
+		t.Fatal("no DW_TAG_subprogram apply")
+	}
+	params := []*Entry{}
+	for _, child := range apply.Children {
+		if child.Tag == DW_TAG_formal_parameter {
+			params = append(params, child)
+		}
+	}
+	if len(params) != 2 {
+		t.Fatalf("apply has %d parameters, want 2", len(params))
+	}
+
+	// the type units start at offset 0 of their sections, the units are told apart by signature
+	tests := []struct {
+		param   *Entry
+		name    string
+		members []string
+	}{
+		{params[0], "outer::inner::scale", []string{"factor", "steps"}},
+		{params[1], "pair", []string{"first", "second"}},
+	}
+	for _, test := range tests {
+		typ := typeUnderPointer(d, test.param)
+		if typ == nil {
+			t.Errorf("%s: the type does not resolve", test.param.Name())
+			continue
+		}
+		if !typ.Unit.InTypes || typ.Tag != DW_TAG_structure_type {
+			t.Errorf("%s: tag 0x%x in a type unit %v, want a structure of a type unit", test.param.Name(), typ.Tag, typ.Unit.InTypes)
+		}
+		if got := typ.QualifiedName(); got != test.name {
+			t.Errorf("%s: type %q, want %q", test.param.Name(), got, test.name)
+		}
+		members := []string{}
+		for _, child := range typ.Children {
+			if child.Tag == DW_TAG_member {
+				members = append(members, child.Name())
+			}
+		}
+		if len(members) != len(test.members) || members[0] != test.members[0] || members[1] != test.members[1] {
+			t.Errorf("%s: members %v, want %v", test.param.Name(), members, test.members)
+		}
+	}
+
+	// a type unit refers to a type of another type unit by signature
+	scale := typeUnderPointer(d, params[0])
+	if scale == nil {
+		return
+	}
+	factor := findEntry(&DebugInfo{Units: []*Unit{scale.Unit}}, DW_TAG_member, "factor")
+	if factor == nil {
+		t.Fatal("no member factor of outer::inner::scale")
+	}
+	vec := d.Ref(factor, DW_AT_type)
+	if vec == nil || vec.Unit == scale.Unit || vec.QualifiedName() != "outer::inner::vec" {
+		t.Errorf("factor: the type does not resolve to outer::inner::vec of another type unit")
+	}
+}
+
+func TestReadElfDebugInfoTreeRelocated(t *testing.T) {
+	// gcc -O0 -g -c func.c, the strp names and the DW_AT_low_pc of a .o are
+	// relocations to .debug_str and .text. i386 uses SHT_REL, the addend is in the field.
+	type pcRange struct {
+		low  uint64
+		high uint64
+	}
+	tests := []struct {
+		path  string
+		funcs map[string]pcRange // the st_value and st_size of the symbols
+	}{
+		{"../testdata/func_x86_64.o", map[string]pcRange{"add": {0x0, 0x1c}, "dist": {0x1c, 0x4a}, "pub": {0x4a, 0x97}}},
+		{"../testdata/func_i386.o", map[string]pcRange{"add": {0x0, 0x1f}, "dist": {0x1f, 0x4e}, "pub": {0x4e, 0x9d}}},
+	}
+	for _, test := range tests {
+		bin, err := os.ReadFile(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var elfObj elf.ElfObject
+		if elf.IsELF64(bin) {
+			elfObj, err = elf.NewElf64(test.path, bin)
+		} else {
+			elfObj, err = elf.NewElf32(test.path, bin)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := ReadElfDebugInfoTree(elfObj)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+
+		// DW_FORM_line_strp
+		if findEntry(d, DW_TAG_compile_unit, "func.c") == nil {
+			t.Errorf("%s: no DW_TAG_compile_unit func.c", test.path)
+		}
+		// dist is DW_FORM_strp, add and pub are DW_FORM_string
+		for name, want := range test.funcs {
+			entry := findEntry(d, DW_TAG_subprogram, name)
+			if entry == nil {
+				t.Errorf("%s: no DW_TAG_subprogram %s", test.path, name)
+				continue
+			}
+			low, high, exist := entry.PcRange()
+			if !exist || low != want.low || high != want.high {
+				t.Errorf("%s: %s PcRange() = 0x%x, 0x%x, %v, want 0x%x, 0x%x, true", test.path, name, low, high, exist, want.low, want.high)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package dwarf
+
+import (
+	binutil "sym-exposer/binutil"
+	"sym-exposer/elf"
+)
+
+// unitBases holds the offsets the index forms of a unit are resolved from.
+// DWARF 5 index sections start with a header, a unit without the base
+// attribute uses the entries right after the first header. The GNU split
+// DWARF extension of DWARF 4 has no headers.
+type unitBases struct {
+	StrOffsets uint64 // DW_AT_str_offsets_base into .debug_str_offsets
+	Addr       uint64 // DW_AT_addr_base into .debug_addr
+	Rnglists   uint64 // DW_AT_rnglists_base into .debug_rnglists
+	Loclists   uint64 // DW_AT_loclists_base into .debug_loclists
+}
+
+func newUnitBases(cuh Dwarf32CuHdr) unitBases {
+	if cuh.Version < 5 {
+		return unitBases{}
+	}
+	// unit_length, version, padding or address_size and segment_selector_size
+	var hdrSize uint64 = 8
+	if cuh.DwarfFormat == DWARF_64BIT_FORMAT {
+		hdrSize = 16
+	}
+	// the list headers add offset_entry_count
+	return unitBases{StrOffsets: hdrSize, Addr: hdrSize, Rnglists: hdrSize + 4, Loclists: hdrSize + 4}
+}
+
+// indexSections holds the sections DWARF 5 index forms refer to
+type indexSections struct {
+	strTab     *binutil.StrTab
+	strOffsets []byte
+	addr       []byte
+	rnglists   []byte
+	loclists   []byte
+}
+
+func readIndexSections(elfObj elf.ElfObject) (indexSections, error) {
+	names := []string{".debug_str", ".debug_str_offsets", ".debug_addr", ".debug_rnglists", ".debug_loclists"}
+	bins := map[string][]byte{}
+	for _, name := range names {
+		bin, err := elfObj.GetRelocatedSectionBinByName(name)
+		if err != nil {
+			return indexSections{}, err
+		}
+		bins[name] = bin
+	}
+	return indexSections{
+		strTab:     binutil.NewStrTab(bins[".debug_str"]),
+		strOffsets: bins[".debug_str_offsets"],
+		addr:       bins[".debug_addr"],
+		rnglists:   bins[".debug_rnglists"],
+		loclists:   bins[".debug_loclists"],
+	}, nil
+}
+
+// readIndexEntry returns the size byte entry idx of the array at base in bin,
+// false when it is out of range
+func readIndexEntry(bin []byte, base uint64, idx uint64, size uint64) (uint64, bool) {
+	if size == 0 || (uint64(len(bin))-min(base, uint64(len(bin))))/size <= idx {
+		return 0, false
+	}
+	cur := binutil.NewLeCursor("index", bin)
+	cur.Seek(base + idx*size)
+	return cur.ReadUint(int(size)), cur.Err() == nil
+}
+
+func offsetSize(dwarfFormat uint8) uint64 {
+	if dwarfFormat == DWARF_32BIT_FORMAT {
+		return 4
+	}
+	return 8
+}
+
+// getStr returns the string of a DW_FORM_strx* index
+func (s indexSections) getStr(idx uint64, bases unitBases, cuh Dwarf32CuHdr) (string, bool) {
+	offset, ok := readIndexEntry(s.strOffsets, bases.StrOffsets, idx, offsetSize(cuh.DwarfFormat))
+	if !ok {
+		return "", false
+	}
+	return s.strTab.Get(offset), true
+}
+
+// getAddr returns the address of a DW_FORM_addrx* index
+func (s indexSections) getAddr(idx uint64, bases unitBases, cuh Dwarf32CuHdr) (uint64, bool) {
+	return readIndexEntry(s.addr, bases.Addr, idx, uint64(cuh.AddressSize))
+}
+
+// getRnglist returns the .debug_rnglists offset of a DW_FORM_rnglistx index,
+// the offsets of the table are relative to the base
+func (s indexSections) getRnglist(idx uint64, bases unitBases, cuh Dwarf32CuHdr) (uint64, bool) {
+	offset, ok := readIndexEntry(s.rnglists, bases.Rnglists, idx, offsetSize(cuh.DwarfFormat))
+	return bases.Rnglists + offset, ok
+}
+
+// getLoclist returns the .debug_loclists offset of a DW_FORM_loclistx index
+func (s indexSections) getLoclist(idx uint64, bases unitBases, cuh Dwarf32CuHdr) (uint64, bool) {
+	offset, ok := readIndexEntry(s.loclists, bases.Loclists, idx, offsetSize(cuh.DwarfFormat))
+	return bases.Loclists + offset, ok
+}
+
+// readFormValue reads an attribute value of form and returns it as a number:
+// the constant, address, reference, section offset or index. Strings, blocks
+// and data16 are skipped and read as 0, DW_FORM_indirect reads the form first.
+func readFormValue(cur *binutil.Cursor, form uint64, implicitConst uint64, cuh Dwarf32CuHdr) uint64 {
+	switch form {
+	case DW_FORM_addr:
+		return cur.ReadUint(int(cuh.AddressSize))
+	case DW_FORM_data1, DW_FORM_ref1, DW_FORM_flag, DW_FORM_strx1, DW_FORM_addrx1:
+		return uint64(cur.ReadU8())
+	case DW_FORM_data2, DW_FORM_ref2, DW_FORM_strx2, DW_FORM_addrx2:
+		return uint64(cur.ReadU16())
+	case DW_FORM_strx3, DW_FORM_addrx3:
+		low := uint64(cur.ReadU16())
+		return low | uint64(cur.ReadU8())<<16
+	case DW_FORM_data4, DW_FORM_ref4, DW_FORM_ref_sup4, DW_FORM_strx4, DW_FORM_addrx4:
+		return uint64(cur.ReadU32())
+	case DW_FORM_data8, DW_FORM_ref8, DW_FORM_ref_sig8, DW_FORM_ref_sup8:
+		return cur.ReadU64()
+	case DW_FORM_data16:
+		cur.Skip(16)
+	case DW_FORM_sdata:
+		return uint64(cur.ReadSleb128())
+	case DW_FORM_udata, DW_FORM_ref_udata, DW_FORM_strx, DW_FORM_addrx, DW_FORM_loclistx, DW_FORM_rnglistx,
+		DW_FORM_GNU_str_index, DW_FORM_GNU_addr_index:
+		return cur.ReadUleb128()
+	case DW_FORM_strp, DW_FORM_line_strp, DW_FORM_strp_sup, DW_FORM_sec_offset, DW_FORM_GNU_ref_alt, DW_FORM_GNU_strp_alt:
+		return readSecOffset(cur, cuh.DwarfFormat)
+	case DW_FORM_ref_addr:
+		// DWARF 2 sized it as an address
+		if cuh.Version <= 2 {
+			return cur.ReadUint(int(cuh.AddressSize))
+		}
+		return readSecOffset(cur, cuh.DwarfFormat)
+	case DW_FORM_string:
+		cur.ReadCString()
+	case DW_FORM_block1:
+		cur.Skip(uint64(cur.ReadU8()))
+	case DW_FORM_block2:
+		cur.Skip(uint64(cur.ReadU16()))
+	case DW_FORM_block4:
+		cur.Skip(uint64(cur.ReadU32()))
+	case DW_FORM_block, DW_FORM_exprloc:
+		cur.Skip(cur.ReadUleb128())
+	case DW_FORM_flag_present:
+		return 1
+	case DW_FORM_implicit_const:
+		return implicitConst
+	case DW_FORM_indirect:
+		indirectForm := cur.ReadUleb128()
+		if indirectForm == DW_FORM_indirect {
+			parseFail("DIE", cur.Pos(), "nested DW_FORM_indirect")
+		}
+		return readFormValue(cur, indirectForm, implicitConst, cuh)
+	default:
+		parseFail("DIE", cur.Pos(), "unknown form 0x%x", form)
+	}
+	return 0
+}
+
+// readUnitBases reads the base attributes of the unit DIE at pos in debug_info,
+// index forms may come before the bases in the DIE so they are read ahead
+func readUnitBases(debug_info []byte, pos uint64, abbrevMap map[uint64]Abbrev, cuh Dwarf32CuHdr) unitBases {
+	bases := newUnitBases(cuh)
+	cur := binutil.NewLeCursor(".debug_info", debug_info)
+	cur.Seek(pos)
+	abbrev, exist := abbrevMap[cur.ReadUleb128()]
+	if !exist {
+		return bases
+	}
+	for _, attr := range abbrev.Attrs {
+		val := readFormValue(cur, attr.Form, attr.Const, cuh)
+		if cur.Err() != nil {
+			return bases
+		}
+		switch attr.Attr {
+		case DW_AT_str_offsets_base:
+			bases.StrOffsets = val
+		case DW_AT_addr_base, GNU_addr_base:
+			bases.Addr = val
+		case DW_AT_rnglists_base:
+			bases.Rnglists = val
+		case DW_AT_loclists_base:
+			bases.Loclists = val
+		}
+	}
+	return bases
+}
@@ -0,0 +1,133 @@
+package elf
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	binutil "sym-exposer/binutil"
+)
+
+const DEBUGLINK_SECTION = ".gnu_debuglink"
+
+// IsDebugSection reports whether name is a DWARF section, compressed .zdebug_* included
+func IsDebugSection(name string) bool {
+	return strings.HasPrefix(name, ".debug") || strings.HasPrefix(name, ".zdebug")
+}
+
+// debugLinkData is the .gnu_debuglink contents: the file name padded to 4 bytes and the CRC32 of the file
+func debugLinkData(debugName string, crc uint32) []byte {
+	w := binutil.NewLeWriter()
+	w.PutCString(debugName)
+	w.Align(4)
+	w.PutU32(crc)
+	return w.Bytes()
+}
+
+// keepInDebugFile marks the sections whose contents a separate debug file keeps:
+// debug sections with their relocations, notes, groups and the tables the writer rebuilds
+func keepInDebugFile(names []string, shTypes []uint32, shInfos []uint32, tables []int) []bool {
+	keep := make([]bool, len(names))
+	for i := range names {
+		switch {
+		case i == 0 || IsDebugSection(names[i]):
+			keep[i] = true
+		case shTypes[i] == SHT_NOTE || shTypes[i] == SHT_GROUP:
+			keep[i] = true
+		case isRelocSection(shTypes[i]) && int(shInfos[i]) < len(names) && IsDebugSection(names[shInfos[i]]):
+			keep[i] = true
+		}
+	}
+	for _, idx := range tables {
+		if 0 <= idx && idx < len(keep) {
+			keep[idx] = true
+		}
+	}
+	return keep
+}
+
+// StripDebug removes the debug sections and their relocation sections like objcopy --strip-debug
+func (w *Elf64Writer) StripDebug() error {
+	removed := make([]bool, len(w.Sections))
+	for i, sec := range w.Sections {
+		removed[i] = i != 0 && IsDebugSection(sec.Name)
+	}
+	return w.removeSections(removed)
+}
+
+// OnlyKeepDebug turns the object into a separate debug file like objcopy --only-keep-debug.
+// All section headers stay so symbols keep their st_shndx, the sections a debug
+// file does not need become SHT_NOBITS. With program headers the bytes of the
+// segments stay in the file as Bytes keeps them.
+func (w *Elf64Writer) OnlyKeepDebug() {
+	names := make([]string, len(w.Sections))
+	shTypes := make([]uint32, len(w.Sections))
+	shInfos := make([]uint32, len(w.Sections))
+	for i, sec := range w.Sections {
+		names[i] = sec.Name
+		shTypes[i] = sec.Shdr.Sh_type
+		shInfos[i] = sec.Shdr.Sh_info
+	}
+	keep := keepInDebugFile(names, shTypes, shInfos, w.rebuiltSections())
+	for i := range w.Sections {
+		sec := &w.Sections[i]
+		if keep[i] || sec.Shdr.Sh_type == SHT_NOBITS {
+			continue
+		}
+		sec.Shdr.Sh_type = SHT_NOBITS
+		sec.Shdr.Sh_size = uint64(len(sec.Data))
+		sec.Data = nil
+	}
+}
+
+// AddDebugLink adds a .gnu_debuglink section pointing to the debug file debugName
+// with the contents debugBin, and returns its index
+func (w *Elf64Writer) AddDebugLink(debugName string, debugBin []byte) (int, error) {
+	if 0 <= w.GetSectionIdx(DEBUGLINK_SECTION) {
+		return -1, fmt.Errorf("%s already exists", DEBUGLINK_SECTION)
+	}
+	idx := w.AddSection(DEBUGLINK_SECTION, debugLinkData(debugName, crc32.ChecksumIEEE(debugBin)))
+	w.Sections[idx].Shdr.Sh_addralign = 4
+	return idx, nil
+}
+
+// StripDebug is the ELF32 version of Elf64Writer.StripDebug
+func (w *Elf32Writer) StripDebug() error {
+	removed := make([]bool, len(w.Sections))
+	for i, sec := range w.Sections {
+		removed[i] = i != 0 && IsDebugSection(sec.Name)
+	}
+	return w.removeSections(removed)
+}
+
+// OnlyKeepDebug is the ELF32 version of Elf64Writer.OnlyKeepDebug
+func (w *Elf32Writer) OnlyKeepDebug() {
+	names := make([]string, len(w.Sections))
+	shTypes := make([]uint32, len(w.Sections))
+	shInfos := make([]uint32, len(w.Sections))
+	for i, sec := range w.Sections {
+		names[i] = sec.Name
+		shTypes[i] = sec.Shdr.Sh_type
+		shInfos[i] = sec.Shdr.Sh_info
+	}
+	keep := keepInDebugFile(names, shTypes, shInfos, w.rebuiltSections())
+	for i := range w.Sections {
+		sec := &w.Sections[i]
+		if keep[i] || sec.Shdr.Sh_type == SHT_NOBITS {
+			continue
+		}
+		sec.Shdr.Sh_type = SHT_NOBITS
+		sec.Shdr.Sh_size = Elf32_Word(len(sec.Data))
+		sec.Data = nil
+	}
+}
+
+// AddDebugLink is the ELF32 version of Elf64Writer.AddDebugLink
+func (w *Elf32Writer) AddDebugLink(debugName string, debugBin []byte) (int, error) {
+	if 0 <= w.GetSectionIdx(DEBUGLINK_SECTION) {
+		return -1, fmt.Errorf("%s already exists", DEBUGLINK_SECTION)
+	}
+	idx := w.AddSection(DEBUGLINK_SECTION, debugLinkData(debugName, crc32.ChecksumIEEE(debugBin)))
+	w.Sections[idx].Shdr.Sh_addralign = 4
+	return idx, nil
+}
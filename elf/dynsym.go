@@ -0,0 +1,515 @@
+package elf
+
+import (
+	"fmt"
+
+	binutil "sym-exposer/binutil"
+)
+
+// .gnu.version entries
+const (
+	VER_NDX_LOCAL     = 0      // Symbol is local
+	VER_NDX_GLOBAL    = 1      // Symbol is global
+	VER_NDX_LORESERVE = 0xff00 // Beginning of reserved entries
+	VERSYM_HIDDEN     = 0x8000 // Symbol is not the default version
+	VERSYM_VERSION    = 0x7fff // Version index mask
+)
+
+// vd_flags and vna_flags
+const (
+	VER_FLG_BASE = 0x1 // Version definition of file itself
+	VER_FLG_WEAK = 0x2 // Weak version identifier
+)
+
+type Elf64_Verdef struct {
+	Vd_version Elf64_Half // Version revision
+	Vd_flags   Elf64_Half // Version information
+	Vd_ndx     Elf64_Half // Version Index
+	Vd_cnt     Elf64_Half // Number of associated aux entries
+	Vd_hash    Elf64_Word // Version name hash value
+	Vd_aux     Elf64_Word // Offset in bytes to verdaux array
+	Vd_next    Elf64_Word // Offset in bytes to next verdef entry
+}
+
+type Elf64_Verdaux struct {
+	Vda_name Elf64_Word // Version or dependency names
+	Vda_next Elf64_Word // Offset in bytes to next verdaux entry
+}
+
+type Elf64_Verneed struct {
+	Vn_version Elf64_Half // Version of structure
+	Vn_cnt     Elf64_Half // Number of associated aux entries
+	Vn_file    Elf64_Word // Offset of filename for this dependency
+	Vn_aux     Elf64_Word // Offset in bytes to vernaux array
+	Vn_next    Elf64_Word // Offset in bytes to next verneed entry
+}
+
+type Elf64_Vernaux struct {
+	Vna_hash  Elf64_Word // Hash value of dependency name
+	Vna_flags Elf64_Half // Dependency specific information
+	Vna_other Elf64_Half // Version index used in .gnu.version
+	Vna_name  Elf64_Word // Dependency name string offset
+	Vna_next  Elf64_Word // Offset in bytes to next vernaux entry
+}
+
+// the version structures have the same layout in ELF32
+type Elf32_Verdef = Elf64_Verdef
+type Elf32_Verdaux = Elf64_Verdaux
+type Elf32_Verneed = Elf64_Verneed
+type Elf32_Vernaux = Elf64_Vernaux
+
+const (
+	VERDEF_SIZE  = 20
+	VERDAUX_SIZE = 8
+	VERNEED_SIZE = 16
+	VERNAUX_SIZE = 16
+)
+
+// SymbolVersion is a version defined by the object (.gnu.version_d)
+// or required from a shared library (.gnu.version_r)
+type SymbolVersion struct {
+	Index uint16 // index referred to by .gnu.version
+	Name  string
+	Flags uint16
+	File  string // library the version is required from, empty for a definition
+}
+
+// DynSymbol is a .dynsym entry of either ELF class with its version
+type DynSymbol struct {
+	Name        string
+	Value       uint64
+	Size        uint64
+	Info        uint8
+	Other       uint8
+	Shndx       uint16
+	Version     string // empty for an unversioned symbol
+	VersionFile string // library of a required version
+	Hidden      bool   // not the default version of the symbol
+}
+
+// IsDefined reports whether the object defines the symbol instead of importing it
+func (sym DynSymbol) IsDefined() bool {
+	return sym.Shndx != SHN_UNDEF
+}
+
+// IsExported reports whether other objects can bind to the symbol
+func (sym DynSymbol) IsExported() bool {
+	bind := sym.Info >> 4
+	vis := sym.Other & 0x03
+	return sym.IsDefined() && (bind == STB_GLOBAL || bind == STB_WEAK) && (vis == STV_DEFAULT || vis == STV_PROTECTED)
+}
+
+// VersionedName returns the name as nm -D prints it: name@@version for the
+// default version of a definition, name@version otherwise. The symbols
+// that name the versions themselves keep their plain name.
+func (sym DynSymbol) VersionedName() string {
+	if sym.Version == "" || sym.Name == sym.Version {
+		return sym.Name
+	}
+	if sym.IsDefined() && !sym.Hidden {
+		return sym.Name + "@@" + sym.Version
+	}
+	return sym.Name + "@" + sym.Version
+}
+
+// DynSymTable is the dynamic symbol table of a shared library or executable
+type DynSymTable struct {
+	Symbols  []DynSymbol
+	Verdefs  []SymbolVersion
+	Verneeds []SymbolVersion
+	GnuHash  *GnuHashTable // nil without .gnu.hash
+}
+
+// Lookup returns the index of the symbol the dynamic linker binds name to, or -1.
+// It searches .gnu.hash when present like ld.so does, the symbols otherwise.
+func (tab *DynSymTable) Lookup(name string) int {
+	if tab.GnuHash != nil {
+		return tab.GnuHash.Lookup(name, tab.Symbols)
+	}
+	for i, sym := range tab.Symbols {
+		if sym.Name == name && sym.IsDefined() && !sym.Hidden {
+			return i
+		}
+	}
+	return -1
+}
+
+// Exported returns the symbols other objects can bind to
+func (tab *DynSymTable) Exported() []DynSymbol {
+	syms := []DynSymbol{}
+	for _, sym := range tab.Symbols {
+		if sym.IsExported() {
+			syms = append(syms, sym)
+		}
+	}
+	return syms
+}
+
+// GnuHashTable is a SHT_GNU_HASH section
+type GnuHashTable struct {
+	SymOffset  uint32   // index of the first symbol in the table
+	BloomShift uint32   // shift of the second bloom filter bit
+	BloomBits  uint32   // bits of a bloom filter word, the ELF class size
+	Bloom      []uint64 // 32-bit words are widened in ELF32
+	Buckets    []uint32 // first symbol index of each bucket
+	Chains     []uint32 // hashes of the symbols from SymOffset on, bit 0 ends a chain
+}
+
+// GnuHash is the hash function of .gnu.hash
+func GnuHash(name string) uint32 {
+	h := uint32(5381)
+	for i := 0; i < len(name); i++ {
+		h = h*33 + uint32(name[i])
+	}
+	return h
+}
+
+// NewGnuHashTable reads a .gnu.hash section, wordSize is 4 for ELF32 and 8 for ELF64
+func NewGnuHashTable(bin []byte, wordSize uint64) (*GnuHashTable, error) {
+	tbl := GnuHashTable{}
+	cur := binutil.NewLeCursor(".gnu.hash", bin)
+	nbuckets := cur.ReadU32()
+	tbl.SymOffset = cur.ReadU32()
+	bloomSize := cur.ReadU32()
+	tbl.BloomShift = cur.ReadU32()
+	tbl.BloomBits = uint32(wordSize * 8)
+	if cur.Err() != nil {
+		return nil, cur.Err()
+	}
+	if cur.Len()/wordSize < uint64(bloomSize) || (cur.Len()-uint64(bloomSize)*wordSize)/4 < uint64(nbuckets) {
+		msg := "%d bloom words and %d buckets exceed the section (size 0x%x)"
+		return nil, binutil.NewParseError(".gnu.hash", 0, msg, bloomSize, nbuckets, len(bin))
+	}
+	if bloomSize == 0 || nbuckets == 0 {
+		return nil, binutil.NewParseError(".gnu.hash", 0, "empty bloom filter or buckets")
+	}
+
+	tbl.Bloom = make([]uint64, bloomSize)
+	for i := range tbl.Bloom {
+		tbl.Bloom[i] = cur.ReadUint(int(wordSize))
+	}
+	tbl.Buckets = make([]uint32, nbuckets)
+	for i := range tbl.Buckets {
+		tbl.Buckets[i] = cur.ReadU32()
+	}
+	tbl.Chains = make([]uint32, 0, cur.Len()/4)
+	for 4 <= cur.Len() {
+		tbl.Chains = append(tbl.Chains, cur.ReadU32())
+	}
+	return &tbl, nil
+}
+
+// Lookup returns the index in syms of the default version of name, or -1
+func (tbl *GnuHashTable) Lookup(name string, syms []DynSymbol) int {
+	h := GnuHash(name)
+	word := tbl.Bloom[(h/tbl.BloomBits)%uint32(len(tbl.Bloom))]
+	mask := uint64(1)<<(h%tbl.BloomBits) | uint64(1)<<((h>>tbl.BloomShift)%tbl.BloomBits)
+	if word&mask != mask {
+		return -1
+	}
+
+	symIdx := tbl.Buckets[h%uint32(len(tbl.Buckets))]
+	if symIdx < tbl.SymOffset {
+		return -1
+	}
+	for ; int(symIdx-tbl.SymOffset) < len(tbl.Chains) && int(symIdx) < len(syms); symIdx++ {
+		chainHash := tbl.Chains[symIdx-tbl.SymOffset]
+		sym := syms[symIdx]
+		if chainHash|1 == h|1 && sym.Name == name && sym.IsDefined() && !sym.Hidden {
+			return int(symIdx)
+		}
+		if chainHash&1 != 0 {
+			break
+		}
+	}
+	return -1
+}
+
+// readVerdefs reads the chain of Elf64_Verdef entries of .gnu.version_d,
+// a version is named by its first Elf64_Verdaux
+func readVerdefs(bin []byte, strTab *binutil.StrTab) ([]SymbolVersion, error) {
+	versions := []SymbolVersion{}
+	cur := binutil.NewLeCursor("Elf64_Verdef", bin)
+	// every entry is at least VERDEF_SIZE bytes, so a cycle ends the loop too
+	for offset := uint64(0); len(versions) <= len(bin)/VERDEF_SIZE; {
+		cur.Seek(offset)
+		vd := Elf64_Verdef{}
+		vd.Vd_version = cur.ReadU16()
+		vd.Vd_flags = cur.ReadU16()
+		vd.Vd_ndx = cur.ReadU16()
+		vd.Vd_cnt = cur.ReadU16()
+		vd.Vd_hash = cur.ReadU32()
+		vd.Vd_aux = cur.ReadU32()
+		vd.Vd_next = cur.ReadU32()
+		ver := SymbolVersion{Index: vd.Vd_ndx, Flags: vd.Vd_flags}
+		if 0 < vd.Vd_cnt {
+			cur.Seek(offset + uint64(vd.Vd_aux))
+			ver.Name = strTab.Get(uint64(cur.ReadU32()))
+		}
+		if cur.Err() != nil {
+			return versions, cur.Err()
+		}
+		versions = append(versions, ver)
+		if vd.Vd_next == 0 {
+			break
+		}
+		offset += uint64(vd.Vd_next)
+	}
+	return versions, nil
+}
+
+// readVerneeds reads the chain of Elf64_Verneed entries of .gnu.version_r,
+// each Elf64_Vernaux is a version required from the file of the Elf64_Verneed
+func readVerneeds(bin []byte, strTab *binutil.StrTab) ([]SymbolVersion, error) {
+	versions := []SymbolVersion{}
+	cur := binutil.NewLeCursor("Elf64_Verneed", bin)
+	count := 0
+	for offset := uint64(0); count <= len(bin)/VERNEED_SIZE; count++ {
+		cur.Seek(offset)
+		vn := Elf64_Verneed{}
+		vn.Vn_version = cur.ReadU16()
+		vn.Vn_cnt = cur.ReadU16()
+		vn.Vn_file = cur.ReadU32()
+		vn.Vn_aux = cur.ReadU32()
+		vn.Vn_next = cur.ReadU32()
+		file := strTab.Get(uint64(vn.Vn_file))
+
+		auxOffset := offset + uint64(vn.Vn_aux)
+		for i := 0; i < int(vn.Vn_cnt) && cur.Err() == nil; i++ {
+			cur.Seek(auxOffset)
+			vna := Elf64_Vernaux{}
+			vna.Vna_hash = cur.ReadU32()
+			vna.Vna_flags = cur.ReadU16()
+			vna.Vna_other = cur.ReadU16()
+			vna.Vna_name = cur.ReadU32()
+			vna.Vna_next = cur.ReadU32()
+			ver := SymbolVersion{Index: vna.Vna_other, Flags: vna.Vna_flags, File: file}
+			ver.Name = strTab.Get(uint64(vna.Vna_name))
+			versions = append(versions, ver)
+			if vna.Vna_next == 0 {
+				break
+			}
+			auxOffset += uint64(vna.Vna_next)
+		}
+		if cur.Err() != nil {
+			return versions, cur.Err()
+		}
+		if vn.Vn_next == 0 {
+			break
+		}
+		offset += uint64(vn.Vn_next)
+	}
+	return versions, nil
+}
+
+// setVersions gives each symbol the version its .gnu.version entry refers to
+func (tab *DynSymTable) setVersions(versym []byte) error {
+	if versym == nil {
+		return nil
+	}
+	versions := map[uint16]SymbolVersion{}
+	for _, ver := range tab.Verdefs {
+		// the base definition names the file itself, its symbols are unversioned
+		if ver.Flags&VER_FLG_BASE == 0 {
+			versions[ver.Index] = ver
+		}
+	}
+	for _, ver := range tab.Verneeds {
+		versions[ver.Index&VERSYM_VERSION] = ver
+	}
+
+	cur := binutil.NewLeCursor(".gnu.version", versym)
+	for i := range tab.Symbols {
+		vs := cur.ReadU16()
+		if cur.Err() != nil {
+			return cur.Err()
+		}
+		idx := vs & VERSYM_VERSION
+		if idx == VER_NDX_LOCAL || idx == VER_NDX_GLOBAL {
+			continue
+		}
+		ver, exist := versions[idx]
+		if !exist {
+			return fmt.Errorf("dynamic symbol [%d] %s: version index %d is not defined", i, tab.Symbols[i].Name, idx)
+		}
+		tab.Symbols[i].Version = ver.Name
+		tab.Symbols[i].VersionFile = ver.File
+		tab.Symbols[i].Hidden = vs&VERSYM_HIDDEN != 0
+	}
+	return nil
+}
+
+// findDynSection returns the first section of shType linked to section link, or -1
+func findDynSection(shTypes []uint32, shLinks []uint32, shType uint32, link int) int {
+	for i := range shTypes {
+		if shTypes[i] == shType && int(shLinks[i]) == link {
+			return i
+		}
+	}
+	return -1
+}
+
+// dynSections are the section data ReadDynSymbols works on
+type dynSections struct {
+	types  []uint32
+	links  []uint32
+	getBin func(shIdx int) []byte
+}
+
+// linkedStrTab returns the string table in sh_link of section shIdx
+func (secs dynSections) linkedStrTab(shIdx int) (*binutil.StrTab, error) {
+	link := int(secs.links[shIdx])
+	if len(secs.types) <= link || secs.types[link] != SHT_STRTAB {
+		return nil, fmt.Errorf("section [%d]: sh_link %d is not a string table", shIdx, link)
+	}
+	return binutil.NewStrTab(secs.getBin(link)), nil
+}
+
+// readVersions fills the versions of tab from the sections of the dynamic symbol table at symIdx
+func (secs dynSections) readVersions(tab *DynSymTable, symIdx int) error {
+	verdefIdx := findDynSection(secs.types, secs.links, SHT_GNU_verdef, int(secs.links[symIdx]))
+	if 0 <= verdefIdx {
+		strTab, err := secs.linkedStrTab(verdefIdx)
+		if err != nil {
+			return err
+		}
+		tab.Verdefs, err = readVerdefs(secs.getBin(verdefIdx), strTab)
+		if err != nil {
+			return err
+		}
+	}
+	verneedIdx := findDynSection(secs.types, secs.links, SHT_GNU_verneed, int(secs.links[symIdx]))
+	if 0 <= verneedIdx {
+		strTab, err := secs.linkedStrTab(verneedIdx)
+		if err != nil {
+			return err
+		}
+		tab.Verneeds, err = readVerneeds(secs.getBin(verneedIdx), strTab)
+		if err != nil {
+			return err
+		}
+	}
+	versymIdx := findDynSection(secs.types, secs.links, SHT_GNU_versym, symIdx)
+	if versymIdx < 0 {
+		return nil
+	}
+	return tab.setVersions(secs.getBin(versymIdx))
+}
+
+func (elfObj *Elf64Object) dynSections() dynSections {
+	secs := dynSections{}
+	for _, sh := range elfObj.Shdrs {
+		secs.types = append(secs.types, sh.Sh_type)
+		secs.links = append(secs.links, sh.Sh_link)
+	}
+	secs.getBin = func(shIdx int) []byte {
+		sh := elfObj.Shdrs[shIdx]
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+			return nil
+		}
+		return elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	}
+	return secs
+}
+
+func (elfObj *Elf32Object) dynSections() dynSections {
+	secs := dynSections{}
+	for _, sh := range elfObj.Shdrs {
+		secs.types = append(secs.types, sh.Sh_type)
+		secs.links = append(secs.links, sh.Sh_link)
+	}
+	secs.getBin = func(shIdx int) []byte {
+		sh := elfObj.Shdrs[shIdx]
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+			return nil
+		}
+		return elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	}
+	return secs
+}
+
+// ReadDynSymbols reads .dynsym with the versions of .gnu.version,
+// .gnu.version_d and .gnu.version_r, and .gnu.hash. The table is empty
+// when the object has no SHT_DYNSYM section.
+func (elfObj *Elf64Object) ReadDynSymbols() (*DynSymTable, error) {
+	tab := DynSymTable{}
+	secs := elfObj.dynSections()
+	symIdx := -1
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_DYNSYM {
+			symIdx = i
+			break
+		}
+	}
+	if symIdx < 0 {
+		return &tab, nil
+	}
+	strTab, err := secs.linkedStrTab(symIdx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sym := range getElf64SymTbl(secs.getBin(symIdx)) {
+		s := DynSymbol{}
+		s.Name = strTab.Get(uint64(sym.St_name))
+		s.Value = sym.St_value
+		s.Size = sym.St_size
+		s.Info = sym.St_info
+		s.Other = sym.St_other
+		s.Shndx = sym.St_shndx
+		tab.Symbols = append(tab.Symbols, s)
+	}
+	err = secs.readVersions(&tab, symIdx)
+	if err != nil {
+		return nil, err
+	}
+	hashIdx := findDynSection(secs.types, secs.links, SHT_GNU_HASH, symIdx)
+	if 0 <= hashIdx {
+		tab.GnuHash, err = NewGnuHashTable(secs.getBin(hashIdx), 8)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &tab, nil
+}
+
+// ReadDynSymbols is the ELF32 version of Elf64Object.ReadDynSymbols
+func (elfObj *Elf32Object) ReadDynSymbols() (*DynSymTable, error) {
+	tab := DynSymTable{}
+	secs := elfObj.dynSections()
+	symIdx := -1
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_DYNSYM {
+			symIdx = i
+			break
+		}
+	}
+	if symIdx < 0 {
+		return &tab, nil
+	}
+	strTab, err := secs.linkedStrTab(symIdx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sym := range getElf32SymTbl(secs.getBin(symIdx)) {
+		s := DynSymbol{}
+		s.Name = strTab.Get(uint64(sym.St_name))
+		s.Value = uint64(sym.St_value)
+		s.Size = uint64(sym.St_size)
+		s.Info = sym.St_info
+		s.Other = sym.St_other
+		s.Shndx = sym.St_shndx
+		tab.Symbols = append(tab.Symbols, s)
+	}
+	err = secs.readVersions(&tab, symIdx)
+	if err != nil {
+		return nil, err
+	}
+	hashIdx := findDynSection(secs.types, secs.links, SHT_GNU_HASH, symIdx)
+	if 0 <= hashIdx {
+		tab.GnuHash, err = NewGnuHashTable(secs.getBin(hashIdx), 4)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &tab, nil
+}
@@ -1,10 +1,13 @@
 package elf
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	binutil "sym-exposer/binutil"
+	fileutil "sym-exposer/fileutil"
 	logger "sym-exposer/logger"
 	"unsafe"
 )
@@ -95,6 +98,7 @@ const (
 	OFFSET_ELF32_E_SHENTSIZE = OFFSET_ELF32_E_PHNUM + unsafe.Sizeof(Elf32_Half(0))
 	OFFSET_ELF32_E_SHNUM     = OFFSET_ELF32_E_SHENTSIZE + unsafe.Sizeof(Elf32_Half(0))
 	OFFSET_ELF32_E_SHSTRNDX  = OFFSET_ELF32_E_SHNUM + unsafe.Sizeof(Elf32_Half(0))
+	ELF32_EHDR_SIZE          = OFFSET_ELF32_E_SHSTRNDX + unsafe.Sizeof(Elf32_Half(0))
 )
 
 const (
@@ -112,6 +116,7 @@ const (
 	OFFSET_ELF64_E_SHENTSIZE = OFFSET_ELF64_E_PHNUM + unsafe.Sizeof(Elf64_Half(0))
 	OFFSET_ELF64_E_SHNUM     = OFFSET_ELF64_E_SHENTSIZE + unsafe.Sizeof(Elf64_Half(0))
 	OFFSET_ELF64_E_SHSTRNDX  = OFFSET_ELF64_E_SHNUM + unsafe.Sizeof(Elf64_Half(0))
+	ELF64_EHDR_SIZE          = OFFSET_ELF64_E_SHSTRNDX + unsafe.Sizeof(Elf64_Half(0))
 )
 
 const (
@@ -176,6 +181,21 @@ const (
 	SHT_HIUSER         = 0x8fffffff // End of application-specific
 )
 
+// sh_flags
+const (
+	SHF_WRITE            = 0x1   // Writable
+	SHF_ALLOC            = 0x2   // Occupies memory during execution
+	SHF_EXECINSTR        = 0x4   // Executable
+	SHF_MERGE            = 0x10  // Might be merged
+	SHF_STRINGS          = 0x20  // Contains nul-terminated strings
+	SHF_INFO_LINK        = 0x40  // `sh_info' contains SHT index
+	SHF_LINK_ORDER       = 0x80  // Preserve order after combining
+	SHF_OS_NONCONFORMING = 0x100 // Non-standard OS specific handling required
+	SHF_GROUP            = 0x200 // Section is member of a group
+	SHF_TLS              = 0x400 // Section hold thread-local data
+	SHF_COMPRESSED       = 0x800 // Section with compressed data
+)
+
 const (
 	SHN_UNDEF     = 0x0000
 	SHN_LORESERVE = 0xff00
@@ -185,6 +205,7 @@ const (
 	SHN_HIPROC    = 0xff1f
 	SHN_ABS       = 0xfff1
 	SHN_COMMON    = 0xfff2
+	SHN_XINDEX    = 0xffff // Index is in the SHT_SYMTAB_SHNDX section
 	SHN_HIRESERVE = 0xffff
 )
 
@@ -351,12 +372,12 @@ type Elf64Ehdr struct {
 
 type Elf32Phdr struct {
 	P_type   Elf32_Word
-	P_flags  Elf32_Word
 	P_offset Elf32_Off
 	P_vaddr  Elf32_Addr
 	P_paddr  Elf32_Addr
 	P_filesz Elf32_Word
 	P_memsz  Elf32_Word
+	P_flags  Elf32_Word
 	P_align  Elf32_Word
 }
 
@@ -427,14 +448,62 @@ type Elf64_Dyn struct {
 	D_ptr Elf64_Addr   // Address value
 }
 
+type Elf32_Rel struct {
+	R_offset Elf32_Addr // Address
+	R_info   Elf32_Word // Relocation type and symbol index
+}
+
+type Elf32_Rela struct {
+	R_offset Elf32_Addr  // Address
+	R_info   Elf32_Word  // Relocation type and symbol index
+	R_addend Elf32_Sword // Addend
+}
+
+type Elf64_Rel struct {
+	R_offset Elf64_Addr  // Address
+	R_info   Elf64_Xword // Relocation type and symbol index
+}
+
+type Elf64_Rela struct {
+	R_offset Elf64_Addr   // Address
+	R_info   Elf64_Xword  // Relocation type and symbol index
+	R_addend Elf64_Sxword // Addend
+}
+
+func ELF32_R_SYM(info Elf32_Word) uint32 {
+	return info >> 8
+}
+
+func ELF32_R_TYPE(info Elf32_Word) uint32 {
+	return info & 0xFF
+}
+
+func ELF32_R_INFO(sym uint32, ty uint32) Elf32_Word {
+	return (sym << 8) | (ty & 0xFF)
+}
+
+func ELF64_R_SYM(info Elf64_Xword) uint32 {
+	return uint32(info >> 32)
+}
+
+func ELF64_R_TYPE(info Elf64_Xword) uint32 {
+	return uint32(info & 0xFFFFFFFF)
+}
+
+func ELF64_R_INFO(sym uint32, ty uint32) Elf64_Xword {
+	return (Elf64_Xword(sym) << 32) | Elf64_Xword(ty)
+}
+
 type ElfObject interface {
 	GetMachineArch() uint16
 	ShowElfHeaderInfo()
 	GetSectionBinByName(name string) []byte
+	GetRelocatedSectionBinByName(name string) ([]byte, error)
+	GetRelocatedSectionBinsByName(name string) ([][]byte, error)
 	HasSection(name string) bool
 	GetFuncIdxByAddr(addr uint64) int
 	GetFuncsInfos() []ElfFunctionInfo
-	ReadDynamic(dynamic []byte) []string
+	ReadDynamic(dynamic []byte) ([]string, error)
 	GetPath() string
 	GetExecPhOffset() uint64
 }
@@ -446,12 +515,13 @@ type Elf32Object struct {
 	Phdrs          []Elf32Phdr
 	Shdrs          []Elf32_Shdr
 	SymTbl         []Elf32_Sym
+	SymShndxTbl    []Elf32_Word // SHT_SYMTAB_SHNDX entries for .symtab, empty if not present
 	FuncsInfos     []ElfFunctionInfo
-	AddrFuncIdxMap map[uint64]int
+	FuncAddrIndex  FuncAddrIndex
 	SectionNameMap map[string]int
-	secNameStr     []byte
-	strtbl         []byte
-	dynstr         []byte
+	secNameStr     *binutil.StrTab
+	strtbl         *binutil.StrTab
+	dynstr         *binutil.StrTab
 }
 
 type Elf64Object struct {
@@ -461,12 +531,13 @@ type Elf64Object struct {
 	Phdrs          []Elf64Phdr
 	Shdrs          []Elf64_Shdr
 	SymTbl         []Elf64_Sym
+	SymShndxTbl    []Elf64_Word // SHT_SYMTAB_SHNDX entries for .symtab, empty if not present
 	FuncsInfos     []ElfFunctionInfo
-	AddrFuncIdxMap map[uint64]int
+	FuncAddrIndex  FuncAddrIndex
 	SectionNameMap map[string]int
-	secNameStr     []byte
-	strtbl         []byte
-	dynstr         []byte
+	secNameStr     *binutil.StrTab
+	strtbl         *binutil.StrTab
+	dynstr         *binutil.StrTab
 }
 
 func getMachineName(e_machine uint16) string {
@@ -488,7 +559,7 @@ func IsELFFile(path string) bool {
 
 	defer f.Close()
 
-	_, err = f.Read(buf)
+	_, err = io.ReadFull(f, buf)
 	if err != nil {
 		// TODO err
 		return false
@@ -502,25 +573,15 @@ func IsELFFile(path string) bool {
 }
 
 func LoadFile(path string) error {
-	fi, err := os.Stat(path)
+	m, err := fileutil.Map(path)
 	if err != nil {
 		// TODO err
 		return err
 	}
+	defer m.Close()
 
-	buf := make([]byte, fi.Size())
-	f, err := os.Open(path)
-	if err != nil {
-		// TODO err
-		return err
-	}
-	_, err = f.Read(buf)
-	if err != nil {
-		// TODO err
-		return err
-	}
-
-	if (buf[0] != 0x7F) || (buf[1] != 'E') || (buf[2] != 'L') || (buf[3] != 'F') {
+	buf := m.Bytes
+	if len(buf) < 4 || (buf[0] != 0x7F) || (buf[1] != 'E') || (buf[2] != 'L') || (buf[3] != 'F') {
 		msg := fmt.Sprintf("%s is not ELF file", path)
 		return errors.New(msg)
 	}
@@ -530,10 +591,10 @@ func LoadFile(path string) error {
 }
 
 func IsELF32(bytes []uint8) bool {
-	return bytes[EI_CLASS] == ELFCLASS32
+	return EI_CLASS < len(bytes) && bytes[EI_CLASS] == ELFCLASS32
 }
 func IsELF64(bytes []uint8) bool {
-	return bytes[EI_CLASS] == ELFCLASS64
+	return EI_CLASS < len(bytes) && bytes[EI_CLASS] == ELFCLASS64
 }
 
 func getOSABIName(e_osabi byte) string {
@@ -545,30 +606,40 @@ func getOSABIName(e_osabi byte) string {
 	return osabi
 }
 
-func NewElf32Ehdr(bin []byte) Elf32Ehdr {
+func NewElf32Ehdr(bin []byte) (Elf32Ehdr, error) {
 	var elf32Ehdr = Elf32Ehdr{}
-	elf32Ehdr.E_ident = bin[0:EI_NIDENT]
-	elf32Ehdr.E_type, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_TYPE:])
-	elf32Ehdr.E_machine, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_MACHINE:])
-	elf32Ehdr.E_version, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF32_E_VERSION:])
-	elf32Ehdr.E_entry, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF32_E_ENTRY:])
-	elf32Ehdr.E_phoff, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF32_E_PHOFF:])
-	elf32Ehdr.E_shoff, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF32_E_SHOFF:])
-	elf32Ehdr.E_flags, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF32_E_FLAGS:])
-	elf32Ehdr.E_ehsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_EHSIZE:])
-	elf32Ehdr.E_phentsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_PHENTSIZE:])
-	elf32Ehdr.E_phnum, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_PHNUM:])
-	elf32Ehdr.E_shentsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_SHENTSIZE:])
-	elf32Ehdr.E_shnum, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_SHNUM:])
-	elf32Ehdr.E_shstrndx, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF32_E_SHSTRNDX:])
-	return elf32Ehdr
+	err := binutil.CheckRange(bin, "Elf32Ehdr", 0, uint64(ELF32_EHDR_SIZE))
+	if err != nil {
+		return elf32Ehdr, err
+	}
+	if bin[0] != 0x7F || bin[1] != 'E' || bin[2] != 'L' || bin[3] != 'F' {
+		return elf32Ehdr, binutil.NewParseError("Elf32Ehdr", 0, "bad ELF magic")
+	}
+
+	// the size is checked above, so the field reads cannot fail
+	cur := binutil.NewLeCursor("Elf32Ehdr", bin)
+	elf32Ehdr.E_ident = cur.ReadBytes(EI_NIDENT)
+	elf32Ehdr.E_type = cur.ReadU16()
+	elf32Ehdr.E_machine = cur.ReadU16()
+	elf32Ehdr.E_version = cur.ReadU32()
+	elf32Ehdr.E_entry = cur.ReadU32()
+	elf32Ehdr.E_phoff = cur.ReadU32()
+	elf32Ehdr.E_shoff = cur.ReadU32()
+	elf32Ehdr.E_flags = cur.ReadU32()
+	elf32Ehdr.E_ehsize = cur.ReadU16()
+	elf32Ehdr.E_phentsize = cur.ReadU16()
+	elf32Ehdr.E_phnum = cur.ReadU16()
+	elf32Ehdr.E_shentsize = cur.ReadU16()
+	elf32Ehdr.E_shnum = cur.ReadU16()
+	elf32Ehdr.E_shstrndx = cur.ReadU16()
+	return elf32Ehdr, nil
 }
 
 func (elfObj *Elf32Object) getElf32Functions() []ElfFunctionInfo {
 	funcs := []ElfFunctionInfo{}
-	for _, sym := range elfObj.SymTbl {
+	for i, sym := range elfObj.SymTbl {
 		if sym.St_info&0x0F == STT_FUNC {
-			if isSpecialShndx(sym.St_shndx) {
+			if !elfObj.IsSymInSection(i) {
 				continue
 			}
 
@@ -579,7 +650,15 @@ func (elfObj *Elf32Object) getElf32Functions() []ElfFunctionInfo {
 			f.Addr = uint64(sym.St_value) & 0xFFFFFFFFFFFFFFFE
 			f.Size = uint64(sym.St_size)
 
-			sh := elfObj.Shdrs[sym.St_shndx]
+			shndx := elfObj.GetSymShndx(i)
+			if len(elfObj.Shdrs) <= int(shndx) {
+				continue
+			}
+			sh := elfObj.Shdrs[shndx]
+			if sh.Sh_type == SHT_NOBITS || uint64(sh.Sh_size) < f.Size {
+				// broken symbol without code or larger than its section, its range would take the line addresses of other functions
+				continue
+			}
 			f.SecName = elfObj.getSectionName(sh.Sh_name)
 			f.LineAddrs = map[uint64]LineAddrInfo{}
 			funcs = append(funcs, f)
@@ -588,45 +667,73 @@ func (elfObj *Elf32Object) getElf32Functions() []ElfFunctionInfo {
 	return funcs
 }
 
-func NewElf64Ehdr(bin []byte) Elf64Ehdr {
+func NewElf64Ehdr(bin []byte) (Elf64Ehdr, error) {
 	var elf64Ehdr = Elf64Ehdr{}
-	elf64Ehdr.E_ident = bin[0:EI_NIDENT]
-	elf64Ehdr.E_type, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_TYPE:])
-	elf64Ehdr.E_machine, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_MACHINE:])
-	elf64Ehdr.E_version, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF64_E_VERSION:])
-	elf64Ehdr.E_entry, _ = binutil.FromLeToUInt64(bin[OFFSET_ELF64_E_ENTRY:])
-	elf64Ehdr.E_phoff, _ = binutil.FromLeToUInt64(bin[OFFSET_ELF64_E_PHOFF:])
-	elf64Ehdr.E_shoff, _ = binutil.FromLeToUInt64(bin[OFFSET_ELF64_E_SHOFF:])
-	elf64Ehdr.E_flags, _ = binutil.FromLeToUInt32(bin[OFFSET_ELF64_E_FLAGS:])
-	elf64Ehdr.E_ehsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_EHSIZE:])
-	elf64Ehdr.E_phentsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_PHENTSIZE:])
-	elf64Ehdr.E_phnum, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_PHNUM:])
-	elf64Ehdr.E_shentsize, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_SHENTSIZE:])
-	elf64Ehdr.E_shnum, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_SHNUM:])
-	elf64Ehdr.E_shstrndx, _ = binutil.FromLeToUInt16(bin[OFFSET_ELF64_E_SHSTRNDX:])
-	return elf64Ehdr
-}
-
-func (elf32Ehdr *Elf32Ehdr) GetProgramHeaders(bin []byte) []Elf32Phdr {
+	err := binutil.CheckRange(bin, "Elf64Ehdr", 0, uint64(ELF64_EHDR_SIZE))
+	if err != nil {
+		return elf64Ehdr, err
+	}
+	if bin[0] != 0x7F || bin[1] != 'E' || bin[2] != 'L' || bin[3] != 'F' {
+		return elf64Ehdr, binutil.NewParseError("Elf64Ehdr", 0, "bad ELF magic")
+	}
+
+	// the size is checked above, so the field reads cannot fail
+	cur := binutil.NewLeCursor("Elf64Ehdr", bin)
+	elf64Ehdr.E_ident = cur.ReadBytes(EI_NIDENT)
+	elf64Ehdr.E_type = cur.ReadU16()
+	elf64Ehdr.E_machine = cur.ReadU16()
+	elf64Ehdr.E_version = cur.ReadU32()
+	elf64Ehdr.E_entry = cur.ReadU64()
+	elf64Ehdr.E_phoff = cur.ReadU64()
+	elf64Ehdr.E_shoff = cur.ReadU64()
+	elf64Ehdr.E_flags = cur.ReadU32()
+	elf64Ehdr.E_ehsize = cur.ReadU16()
+	elf64Ehdr.E_phentsize = cur.ReadU16()
+	elf64Ehdr.E_phnum = cur.ReadU16()
+	elf64Ehdr.E_shentsize = cur.ReadU16()
+	elf64Ehdr.E_shnum = cur.ReadU16()
+	elf64Ehdr.E_shstrndx = cur.ReadU16()
+	return elf64Ehdr, nil
+}
+
+func (elf32Ehdr *Elf32Ehdr) GetProgramHeaders(bin []byte) ([]Elf32Phdr, error) {
 	var phdrs []Elf32Phdr
-	var offset = elf32Ehdr.E_phoff
+	var offset = uint64(elf32Ehdr.E_phoff)
+	phdrSize := uint64(unsafe.Sizeof(Elf32Phdr{}))
+	if 0 < elf32Ehdr.E_phnum && uint64(elf32Ehdr.E_phentsize) < phdrSize {
+		msg := "e_phentsize %d is smaller than %d"
+		return nil, binutil.NewParseError("Elf32Ehdr", uint64(OFFSET_ELF32_E_PHENTSIZE), msg, elf32Ehdr.E_phentsize, phdrSize)
+	}
 	for i := 0; i < int(elf32Ehdr.E_phnum); i++ {
+		err := binutil.CheckRange(bin, "Elf32Phdr", offset, phdrSize)
+		if err != nil {
+			return nil, err
+		}
 		elf32Phdr := NewElf32Phdr(bin[offset:])
 		phdrs = append(phdrs, elf32Phdr)
-		offset += uint32(elf32Ehdr.E_phentsize)
+		offset += uint64(elf32Ehdr.E_phentsize)
 	}
-	return phdrs
+	return phdrs, nil
 }
 
-func (elf64Ehdr *Elf64Ehdr) GetProgramHeaders(bin []byte) []Elf64Phdr {
+func (elf64Ehdr *Elf64Ehdr) GetProgramHeaders(bin []byte) ([]Elf64Phdr, error) {
 	var phdrs []Elf64Phdr
 	var offset = elf64Ehdr.E_phoff
+	phdrSize := uint64(unsafe.Sizeof(Elf64Phdr{}))
+	if 0 < elf64Ehdr.E_phnum && uint64(elf64Ehdr.E_phentsize) < phdrSize {
+		msg := "e_phentsize %d is smaller than %d"
+		return nil, binutil.NewParseError("Elf64Ehdr", uint64(OFFSET_ELF64_E_PHENTSIZE), msg, elf64Ehdr.E_phentsize, phdrSize)
+	}
 	for i := 0; i < int(elf64Ehdr.E_phnum); i++ {
+		err := binutil.CheckRange(bin, "Elf64Phdr", offset, phdrSize)
+		if err != nil {
+			return nil, err
+		}
 		elf64Phdr := NewElf64Phdr(bin[offset:])
 		phdrs = append(phdrs, elf64Phdr)
 		offset += uint64(elf64Ehdr.E_phentsize)
 	}
-	return phdrs
+	return phdrs, nil
 }
 func (elfObj *Elf32Object) HasSection(name string) bool {
 	_, exist := elfObj.SectionNameMap[name]
@@ -634,11 +741,7 @@ func (elfObj *Elf32Object) HasSection(name string) bool {
 }
 
 func (elfObj *Elf32Object) GetFuncIdxByAddr(addr uint64) int {
-	funcIdx, exist := elfObj.AddrFuncIdxMap[addr]
-	if !exist {
-		return -1
-	}
-	return funcIdx
+	return elfObj.FuncAddrIndex.Lookup(addr)
 }
 
 func (elfObj Elf32Object) GetFuncsInfos() []ElfFunctionInfo {
@@ -681,6 +784,9 @@ func (elfObj Elf32Object) GetSectionBinByName(name string) []byte {
 		return nil
 	}
 	sh := elfObj.Shdrs[shIdx]
+	if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+		return nil
+	}
 	endOffset := sh.Sh_offset + sh.Sh_size
 	return elfObj.Bin[sh.Sh_offset:endOffset]
 }
@@ -691,39 +797,33 @@ func (elfObj *Elf64Object) GetSectionBinByName(name string) []byte {
 		return nil
 	}
 	sh := elfObj.Shdrs[shIdx]
+	if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+		return nil
+	}
 	endOffset := sh.Sh_offset + sh.Sh_size
 	return elfObj.Bin[sh.Sh_offset:endOffset]
 }
 
 func (elfObj Elf32Object) GetStrFromStrTbl(st_name Elf64_Word) string {
-	str := ""
-	offset := st_name
-	for elfObj.strtbl[offset] != 0 {
-		str += string(elfObj.strtbl[offset])
-		offset++
-	}
-	return str
+	return elfObj.strtbl.Get(uint64(st_name))
 }
 
-func (elfObj Elf32Object) ReadDynamic(dynamic []byte) []string {
-	size := len(dynamic)
-	offset := 0
-
+func (elfObj Elf32Object) ReadDynamic(dynamic []byte) ([]string, error) {
 	var dynlibs []string
 
-	for offset < size {
+	cur := binutil.NewLeCursor("Elf32_Dyn", dynamic)
+	for 0 < cur.Len() {
 		var dyn Elf32_Dyn
-		// /var tag int32
-		//var val uint64
-		tag, _ := binutil.FromLeToInt32(dynamic[offset:])
-		varSize := unsafe.Sizeof(Elf32_Sxword(0))
-		offset += int(varSize)
-		val, _ := binutil.FromLeToUInt32(dynamic[offset:])
-		offset += 8
+		// Elf32_Dyn is a 4 byte tag followed by a 4 byte value
+		tag := cur.ReadS32()
+		val := cur.ReadU32()
+		if cur.Err() != nil {
+			return dynlibs, cur.Err()
+		}
 		dyn.D_tag = tag
 		switch dyn.D_tag {
 		case DT_NEEDED:
-			libname := binutil.GetString(elfObj.dynstr, uint64(val))
+			libname := elfObj.dynstr.Get(uint64(val))
 			dyn.D_val = val
 			dynlibs = append(dynlibs, libname)
 			logger.TLog(libname)
@@ -775,7 +875,7 @@ func (elfObj Elf32Object) ReadDynamic(dynamic []byte) []string {
 		case DT_FINI_ARRAYSZ:
 			dyn.D_val = val
 		case DT_RUNPATH:
-			runpath := binutil.GetString(elfObj.dynstr, uint64(val))
+			runpath := elfObj.dynstr.Get(uint64(val))
 			dyn.D_val = val
 			logger.TLog(runpath)
 			dyn.D_val = val
@@ -793,7 +893,8 @@ func (elfObj Elf32Object) ReadDynamic(dynamic []byte) []string {
 			case DF_STATIC_TLS:
 				logger.TLog("DF_STATIC_TLS")
 			default:
-				panic("unexpected DT_FLAGS val")
+				// several flags may be set at once
+				logger.TLog("DT_FLAGS 0x%x\n", val)
 			}
 		case DT_VERSYM:
 			dyn.D_val = val
@@ -806,7 +907,7 @@ func (elfObj Elf32Object) ReadDynamic(dynamic []byte) []string {
 			case DTF_1_CONFEXP:
 				// need configuration file
 			default:
-				panic("Unexpected val")
+				logger.TLog("DT_FEATURE_1 0x%x\n", val)
 			}
 		case DT_GNU_HASH:
 			dyn.D_val = val
@@ -827,28 +928,24 @@ func (elfObj Elf32Object) ReadDynamic(dynamic []byte) []string {
 			// TODO not implemented.
 		}
 	}
-	return dynlibs
+	return dynlibs, nil
 }
 
-func (elfObj Elf64Object) ReadDynamic(dynamic []byte) []string {
-	size := len(dynamic)
-	offset := 0
-
+func (elfObj Elf64Object) ReadDynamic(dynamic []byte) ([]string, error) {
 	var dynlibs []string
 
-	for offset < size {
+	cur := binutil.NewLeCursor("Elf64_Dyn", dynamic)
+	for 0 < cur.Len() {
 		var dyn Elf64_Dyn
-		var tag int64
-		var val uint64
-		tag, _ = binutil.FromLeToInt64(dynamic[offset:])
-		varSize := unsafe.Sizeof(Elf64_Sxword(0))
-		offset += int(varSize)
-		val, _ = binutil.FromLeToUInt64(dynamic[offset:])
-		offset += 8
+		tag := cur.ReadS64()
+		val := cur.ReadU64()
+		if cur.Err() != nil {
+			return dynlibs, cur.Err()
+		}
 		dyn.D_tag = tag
 		switch dyn.D_tag {
 		case DT_NEEDED:
-			libname := binutil.GetString(elfObj.dynstr, val)
+			libname := elfObj.dynstr.Get(val)
 			dyn.D_val = val
 			dynlibs = append(dynlibs, libname)
 			logger.TLog(libname)
@@ -900,7 +997,7 @@ func (elfObj Elf64Object) ReadDynamic(dynamic []byte) []string {
 		case DT_FINI_ARRAYSZ:
 			dyn.D_val = val
 		case DT_RUNPATH:
-			runpath := binutil.GetString(elfObj.dynstr, val)
+			runpath := elfObj.dynstr.Get(val)
 			dyn.D_val = val
 			logger.TLog(runpath)
 			dyn.D_val = val
@@ -918,7 +1015,8 @@ func (elfObj Elf64Object) ReadDynamic(dynamic []byte) []string {
 			case DF_STATIC_TLS:
 				logger.TLog("DF_STATIC_TLS")
 			default:
-				panic("unexpected DT_FLAGS val")
+				// several flags may be set at once
+				logger.TLog("DT_FLAGS 0x%x\n", val)
 			}
 		case DT_VERSYM:
 			dyn.D_val = val
@@ -931,7 +1029,7 @@ func (elfObj Elf64Object) ReadDynamic(dynamic []byte) []string {
 			case DTF_1_CONFEXP:
 				// need configuration file
 			default:
-				panic("Unexpected val")
+				logger.TLog("DT_FEATURE_1 0x%x\n", val)
 			}
 		case DT_GNU_HASH:
 			dyn.D_val = val
@@ -952,7 +1050,7 @@ func (elfObj Elf64Object) ReadDynamic(dynamic []byte) []string {
 			// TODO not implemented.
 		}
 	}
-	return dynlibs
+	return dynlibs, nil
 }
 
 func (elf32Ehdr *Elf32Ehdr) ShowElfHeaderInfo() {
@@ -1101,91 +1199,74 @@ func (elf64Ehdr *Elf64Ehdr) ShowElfHeaderInfo() {
 
 func NewElf32Phdr(bin []byte) Elf32Phdr {
 	var elf32Phdr = Elf32Phdr{}
-	var offset uintptr = 0
-
-	size := unsafe.Sizeof(Elf32_Word(0))
-	elf32Phdr.P_type, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Phdr.P_flags, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Off(0))
-	elf32Phdr.P_offset, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Addr(0))
-	elf32Phdr.P_vaddr, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Addr(0))
-	elf32Phdr.P_paddr, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Phdr.P_filesz, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Phdr.P_memsz, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Phdr.P_align, _ = binutil.FromLeToUInt32(bin[offset:])
-
+	cur := binutil.NewLeCursor("Elf32Phdr", bin)
+
+	// unlike Elf64_Phdr, p_flags follows p_memsz
+	elf32Phdr.P_type = cur.ReadU32()
+	elf32Phdr.P_offset = cur.ReadU32()
+	elf32Phdr.P_vaddr = cur.ReadU32()
+	elf32Phdr.P_paddr = cur.ReadU32()
+	elf32Phdr.P_filesz = cur.ReadU32()
+	elf32Phdr.P_memsz = cur.ReadU32()
+	elf32Phdr.P_flags = cur.ReadU32()
+	elf32Phdr.P_align = cur.ReadU32()
 	return elf32Phdr
 }
 
 func NewElf64Phdr(bin []byte) Elf64Phdr {
 	var elf64Phdr = Elf64Phdr{}
-	var offset uintptr = 0
-
-	size := unsafe.Sizeof(Elf64_Word(0))
-	elf64Phdr.P_type, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Word(0))
-	elf64Phdr.P_flags, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Off(0))
-	elf64Phdr.P_offset, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Addr(0))
-	elf64Phdr.P_vaddr, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Addr(0))
-	elf64Phdr.P_paddr, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Phdr.P_filesz, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Phdr.P_memsz, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Phdr.P_align, _ = binutil.FromLeToUInt64(bin[offset:])
-
+	cur := binutil.NewLeCursor("Elf64Phdr", bin)
+
+	elf64Phdr.P_type = cur.ReadU32()
+	elf64Phdr.P_flags = cur.ReadU32()
+	elf64Phdr.P_offset = cur.ReadU64()
+	elf64Phdr.P_vaddr = cur.ReadU64()
+	elf64Phdr.P_paddr = cur.ReadU64()
+	elf64Phdr.P_filesz = cur.ReadU64()
+	elf64Phdr.P_memsz = cur.ReadU64()
+	elf64Phdr.P_align = cur.ReadU64()
 	return elf64Phdr
 }
 
-func NewElf32(path string, bin []byte) *Elf32Object {
+func NewElf32(path string, bin []byte) (*Elf32Object, error) {
 	elfObj := Elf32Object{}
-	ehdr := NewElf32Ehdr(bin)
+	ehdr, err := NewElf32Ehdr(bin)
+	if err != nil {
+		return nil, err
+	}
 	elfObj.Path = path
 	elfObj.Bin = bin
 	elfObj.Elf32Ehdr = ehdr
-	elfObj.Shdrs = ehdr.GetSectionHeaders(bin)
-	elfObj.Phdrs = ehdr.GetProgramHeaders(bin)
+	elfObj.Shdrs, err = ehdr.GetSectionHeaders(bin)
+	if err != nil {
+		return nil, err
+	}
+	elfObj.Phdrs, err = ehdr.GetProgramHeaders(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	// section contents must be in the file
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+			continue
+		}
+		err = binutil.CheckRange(bin, fmt.Sprintf("section [%d]", i), uint64(sh.Sh_offset), uint64(sh.Sh_size))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	elfObj.SectionNameMap = make(map[string]int)
-	strSh := elfObj.Shdrs[ehdr.E_shstrndx]
-	elfObj.secNameStr = bin[strSh.Sh_offset : uint32(strSh.Sh_offset)+strSh.Sh_size]
+	if 0 < len(elfObj.Shdrs) {
+		shstrndx := elfObj.GetShstrndx()
+		if len(elfObj.Shdrs) <= int(shstrndx) || elfObj.Shdrs[shstrndx].Sh_type == SHT_NULL || elfObj.Shdrs[shstrndx].Sh_type == SHT_NOBITS {
+			msg := "e_shstrndx %d is not a valid section (%d sections)"
+			return nil, binutil.NewParseError("Elf32Ehdr", uint64(OFFSET_ELF32_E_SHSTRNDX), msg, shstrndx, len(elfObj.Shdrs))
+		}
+		strSh := elfObj.Shdrs[shstrndx]
+		elfObj.secNameStr = binutil.NewStrTab(bin[strSh.Sh_offset : uint32(strSh.Sh_offset)+strSh.Sh_size])
+	}
 	for i, sh := range elfObj.Shdrs {
 		name := elfObj.getSectionName(sh.Sh_name)
 		elfObj.SectionNameMap[name] = i
@@ -1193,36 +1274,56 @@ func NewElf32(path string, bin []byte) *Elf32Object {
 
 	symTblBin := elfObj.GetSectionBinByName(".symtab")
 	elfObj.SymTbl = getElf32SymTbl(symTblBin)
+	elfObj.SymShndxTbl = elfObj.getSymShndxTbl()
 
-	elfObj.strtbl = elfObj.GetSectionBinByName(".strtab")
-	elfObj.dynstr = elfObj.GetSectionBinByName(".dynstr")
+	elfObj.strtbl = binutil.NewStrTab(elfObj.GetSectionBinByName(".strtab"))
+	elfObj.dynstr = binutil.NewStrTab(elfObj.GetSectionBinByName(".dynstr"))
 
 	elfObj.FuncsInfos = elfObj.getElf32Functions()
-	elfObj.AddrFuncIdxMap = map[uint64]int{}
-	for funcIdx, f := range elfObj.FuncsInfos {
-		var offset uint64 = 0
-		for offset < f.Size {
-			funcAddr := f.Addr + offset
-			offset++
-			elfObj.AddrFuncIdxMap[funcAddr] = funcIdx
-		}
-
-	}
+	elfObj.FuncAddrIndex = NewFuncAddrIndex(elfObj.FuncsInfos)
 
-	return &elfObj
+	return &elfObj, nil
 }
 
-func NewElf64(path string, bin []byte) *Elf64Object {
+func NewElf64(path string, bin []byte) (*Elf64Object, error) {
 	elfObj := Elf64Object{}
-	ehdr := NewElf64Ehdr(bin)
+	ehdr, err := NewElf64Ehdr(bin)
+	if err != nil {
+		return nil, err
+	}
 	elfObj.Path = path
 	elfObj.Bin = bin
 	elfObj.Elf64Ehdr = ehdr
-	elfObj.Shdrs = ehdr.GetSectionHeaders(bin)
-	elfObj.Phdrs = ehdr.GetProgramHeaders(bin)
+	elfObj.Shdrs, err = ehdr.GetSectionHeaders(bin)
+	if err != nil {
+		return nil, err
+	}
+	elfObj.Phdrs, err = ehdr.GetProgramHeaders(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	// section contents must be in the file
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+			continue
+		}
+		err = binutil.CheckRange(bin, fmt.Sprintf("section [%d]", i), uint64(sh.Sh_offset), uint64(sh.Sh_size))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	elfObj.SectionNameMap = make(map[string]int)
-	strSh := elfObj.Shdrs[ehdr.E_shstrndx]
-	elfObj.secNameStr = bin[strSh.Sh_offset : strSh.Sh_offset+strSh.Sh_size]
+	if 0 < len(elfObj.Shdrs) {
+		shstrndx := elfObj.GetShstrndx()
+		if len(elfObj.Shdrs) <= int(shstrndx) || elfObj.Shdrs[shstrndx].Sh_type == SHT_NULL || elfObj.Shdrs[shstrndx].Sh_type == SHT_NOBITS {
+			msg := "e_shstrndx %d is not a valid section (%d sections)"
+			return nil, binutil.NewParseError("Elf64Ehdr", uint64(OFFSET_ELF64_E_SHSTRNDX), msg, shstrndx, len(elfObj.Shdrs))
+		}
+		strSh := elfObj.Shdrs[shstrndx]
+		elfObj.secNameStr = binutil.NewStrTab(bin[strSh.Sh_offset : strSh.Sh_offset+strSh.Sh_size])
+	}
 	for i, sh := range elfObj.Shdrs {
 		name := elfObj.getSectionName(sh.Sh_name)
 		elfObj.SectionNameMap[name] = i
@@ -1230,49 +1331,109 @@ func NewElf64(path string, bin []byte) *Elf64Object {
 
 	symTblBin := elfObj.GetSectionBinByName(".symtab")
 	elfObj.SymTbl = getElf64SymTbl(symTblBin)
+	elfObj.SymShndxTbl = elfObj.getSymShndxTbl()
 
-	elfObj.strtbl = elfObj.GetSectionBinByName(".strtab")
-	elfObj.dynstr = elfObj.GetSectionBinByName(".dynstr")
+	elfObj.strtbl = binutil.NewStrTab(elfObj.GetSectionBinByName(".strtab"))
+	elfObj.dynstr = binutil.NewStrTab(elfObj.GetSectionBinByName(".dynstr"))
 
 	elfObj.FuncsInfos = elfObj.getElf64Functions()
-	elfObj.AddrFuncIdxMap = map[uint64]int{}
-	for funcIdx, f := range elfObj.FuncsInfos {
-		var offset uint64 = 0
-		for offset < f.Size {
-			funcAddr := f.Addr + offset
-			offset++
-			elfObj.AddrFuncIdxMap[funcAddr] = funcIdx
+	elfObj.FuncAddrIndex = NewFuncAddrIndex(elfObj.FuncsInfos)
+
+	return &elfObj, nil
+}
+
+// GetShstrndx returns the index of the section name table,
+// which is in sh_link of section 0 when it does not fit in e_shstrndx
+func (elfObj *Elf32Object) GetShstrndx() uint32 {
+	shstrndx := uint32(elfObj.Elf32Ehdr.E_shstrndx)
+	if shstrndx == SHN_XINDEX && 0 < len(elfObj.Shdrs) {
+		shstrndx = elfObj.Shdrs[0].Sh_link
+	}
+	return shstrndx
+}
+
+// getSymShndxTbl reads the SHT_SYMTAB_SHNDX section associated with .symtab
+func (elfObj *Elf32Object) getSymShndxTbl() []Elf32_Word {
+	tbl := []Elf32_Word{}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		return tbl
+	}
+	for _, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_SYMTAB_SHNDX || int(sh.Sh_link) != symTabIdx {
+			continue
 		}
+		bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+		cur := binutil.NewLeCursor("SHT_SYMTAB_SHNDX", bin)
+		for 4 <= cur.Len() {
+			tbl = append(tbl, cur.ReadU32())
+		}
+		break
+	}
+	return tbl
+}
 
+// GetSymShndx returns the section index of the symbol, resolving SHN_XINDEX
+// through the SHT_SYMTAB_SHNDX table
+func (elfObj *Elf32Object) GetSymShndx(symIdx int) uint32 {
+	shndx := uint32(elfObj.SymTbl[symIdx].St_shndx)
+	if shndx == SHN_XINDEX && symIdx < len(elfObj.SymShndxTbl) {
+		shndx = elfObj.SymShndxTbl[symIdx]
 	}
+	return shndx
+}
 
-	return &elfObj
+// IsSymInSection reports whether the symbol refers to a real section
+func (elfObj *Elf32Object) IsSymInSection(symIdx int) bool {
+	shndx := elfObj.SymTbl[symIdx].St_shndx
+	if shndx == SHN_XINDEX {
+		return symIdx < len(elfObj.SymShndxTbl)
+	}
+	return !isSpecialShndx(shndx)
 }
 
-func (elf32Ehdr *Elf32Ehdr) GetSectionHeaders(bin []byte) []Elf32_Shdr {
+func (elf32Ehdr *Elf32Ehdr) GetSectionHeaders(bin []byte) ([]Elf32_Shdr, error) {
 	var shTbl []Elf32_Shdr
-	offset := elf32Ehdr.E_shoff
-	for i := 0; i < int(elf32Ehdr.E_shnum); i++ {
+	offset := uint64(elf32Ehdr.E_shoff)
+	if offset == 0 {
+		return shTbl, nil
+	}
+	shdrSize := uint64(unsafe.Sizeof(Elf32_Shdr{}))
+	if uint64(elf32Ehdr.E_shentsize) < shdrSize {
+		msg := "e_shentsize %d is smaller than %d"
+		return nil, binutil.NewParseError("Elf32Ehdr", uint64(OFFSET_ELF32_E_SHENTSIZE), msg, elf32Ehdr.E_shentsize, shdrSize)
+	}
+	shnum := uint64(elf32Ehdr.E_shnum)
+	if shnum == 0 {
+		// more than SHN_LORESERVE sections, the number is in sh_size of section 0
+		err := binutil.CheckRange(bin, "Elf32_Shdr", offset, shdrSize)
+		if err != nil {
+			return nil, err
+		}
+		shnum = uint64(NewElf32Shdr(bin[offset:]).Sh_size)
+	}
+	for i := uint64(0); i < shnum; i++ {
+		err := binutil.CheckRange(bin, "Elf32_Shdr", offset, shdrSize)
+		if err != nil {
+			return nil, err
+		}
 		elfShdr := NewElf32Shdr(bin[offset:])
 		shTbl = append(shTbl, elfShdr)
-		offset += uint32(elf32Ehdr.E_shentsize)
+		offset += uint64(elf32Ehdr.E_shentsize)
 	}
-	return shTbl
+	return shTbl, nil
 }
 
 func (elf32Ehdr *Elf32Ehdr) GetSectionNames(strSec []byte) []string {
 	var sectionNames []string
 	pos := 0
-	size := len(strSec)
-	str := ""
-	for pos < size {
-		if strSec[pos] == 0 {
-			sectionNames = append(sectionNames, str)
-			str = ""
-		} else {
-			str += string(strSec[pos])
+	for pos < len(strSec) {
+		end := bytes.IndexByte(strSec[pos:], 0)
+		if end < 0 {
+			break
 		}
-		pos++
+		sectionNames = append(sectionNames, string(strSec[pos:pos+end]))
+		pos += end + 1
 	}
 	return sectionNames
 }
@@ -1283,11 +1444,7 @@ func (elfObj *Elf64Object) HasSection(name string) bool {
 }
 
 func (elfObj *Elf64Object) GetFuncIdxByAddr(addr uint64) int {
-	funcIdx, exist := elfObj.AddrFuncIdxMap[addr]
-	if !exist {
-		return -1
-	}
-	return funcIdx
+	return elfObj.FuncAddrIndex.Lookup(addr)
 }
 
 func (elfObj Elf64Object) GetExecPhOffset() uint64 {
@@ -1305,154 +1462,149 @@ func (elfObj Elf64Object) GetShByName(name string) *Elf64_Shdr {
 }
 
 func (elfObj Elf64Object) GetStrFromStrTbl(st_name Elf64_Word) string {
-	str := ""
-	offset := st_name
-	for elfObj.strtbl[offset] != 0 {
-		str += string(elfObj.strtbl[offset])
-		offset++
+	return elfObj.strtbl.Get(uint64(st_name))
+}
+
+// GetShstrndx returns the index of the section name table,
+// which is in sh_link of section 0 when it does not fit in e_shstrndx
+func (elfObj *Elf64Object) GetShstrndx() uint32 {
+	shstrndx := uint32(elfObj.Elf64Ehdr.E_shstrndx)
+	if shstrndx == SHN_XINDEX && 0 < len(elfObj.Shdrs) {
+		shstrndx = elfObj.Shdrs[0].Sh_link
+	}
+	return shstrndx
+}
+
+// getSymShndxTbl reads the SHT_SYMTAB_SHNDX section associated with .symtab
+func (elfObj *Elf64Object) getSymShndxTbl() []Elf64_Word {
+	tbl := []Elf64_Word{}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		return tbl
+	}
+	for _, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_SYMTAB_SHNDX || int(sh.Sh_link) != symTabIdx {
+			continue
+		}
+		bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+		cur := binutil.NewLeCursor("SHT_SYMTAB_SHNDX", bin)
+		for 4 <= cur.Len() {
+			tbl = append(tbl, cur.ReadU32())
+		}
+		break
+	}
+	return tbl
+}
+
+// GetSymShndx returns the section index of the symbol, resolving SHN_XINDEX
+// through the SHT_SYMTAB_SHNDX table
+func (elfObj *Elf64Object) GetSymShndx(symIdx int) uint32 {
+	shndx := uint32(elfObj.SymTbl[symIdx].St_shndx)
+	if shndx == SHN_XINDEX && symIdx < len(elfObj.SymShndxTbl) {
+		shndx = elfObj.SymShndxTbl[symIdx]
 	}
-	return str
+	return shndx
 }
 
-func (elf64Ehdr *Elf64Ehdr) GetSectionHeaders(bin []byte) []Elf64_Shdr {
+// IsSymInSection reports whether the symbol refers to a real section
+func (elfObj *Elf64Object) IsSymInSection(symIdx int) bool {
+	shndx := elfObj.SymTbl[symIdx].St_shndx
+	if shndx == SHN_XINDEX {
+		return symIdx < len(elfObj.SymShndxTbl)
+	}
+	return !isSpecialShndx(shndx)
+}
+
+func (elf64Ehdr *Elf64Ehdr) GetSectionHeaders(bin []byte) ([]Elf64_Shdr, error) {
 	var shTbl []Elf64_Shdr
 	offset := elf64Ehdr.E_shoff
-	for i := 0; i < int(elf64Ehdr.E_shnum); i++ {
+	if offset == 0 {
+		return shTbl, nil
+	}
+	shdrSize := uint64(unsafe.Sizeof(Elf64_Shdr{}))
+	if uint64(elf64Ehdr.E_shentsize) < shdrSize {
+		msg := "e_shentsize %d is smaller than %d"
+		return nil, binutil.NewParseError("Elf64Ehdr", uint64(OFFSET_ELF64_E_SHENTSIZE), msg, elf64Ehdr.E_shentsize, shdrSize)
+	}
+	shnum := uint64(elf64Ehdr.E_shnum)
+	if shnum == 0 {
+		// more than SHN_LORESERVE sections, the number is in sh_size of section 0
+		err := binutil.CheckRange(bin, "Elf64_Shdr", offset, shdrSize)
+		if err != nil {
+			return nil, err
+		}
+		shnum = NewElf64Shdr(bin[offset:]).Sh_size
+	}
+	for i := uint64(0); i < shnum; i++ {
+		err := binutil.CheckRange(bin, "Elf64_Shdr", offset, shdrSize)
+		if err != nil {
+			return nil, err
+		}
 		elfShdr := NewElf64Shdr(bin[offset:])
 		shTbl = append(shTbl, elfShdr)
 		offset += uint64(elf64Ehdr.E_shentsize)
 	}
-	return shTbl
+	return shTbl, nil
 }
 
 func (elf64Ehdr *Elf64Ehdr) GetSectionNames(strSec []byte) []string {
 	var sectionNames []string
 	pos := 0
-	size := len(strSec)
-	str := ""
-	for pos < size {
-		if strSec[pos] == 0 {
-			sectionNames = append(sectionNames, str)
-			str = ""
-		} else {
-			str += string(strSec[pos])
+	for pos < len(strSec) {
+		end := bytes.IndexByte(strSec[pos:], 0)
+		if end < 0 {
+			break
 		}
-		pos++
+		sectionNames = append(sectionNames, string(strSec[pos:pos+end]))
+		pos += end + 1
 	}
 	return sectionNames
 }
 func (elfObj *Elf32Object) getSectionName(sh_name Elf32_Word) string {
-	secName := ""
-	pos := sh_name
-	for {
-		if (elfObj.secNameStr[pos]) == 0 {
-			break
-		}
-		secName += string(elfObj.secNameStr[pos])
-		pos++
-	}
-	return secName
+	return elfObj.secNameStr.Get(uint64(sh_name))
 }
 func getElf32SymTbl(bin []byte) []Elf32_Sym {
-	len := len(bin)
-	var offset uintptr = 0
+	symSize := uint64(unsafe.Sizeof(Elf32_Sym{}))
+	cur := binutil.NewLeCursor("Elf32_Sym", bin)
 
-	symTbl := []Elf32_Sym{}
-	for int(offset) < len {
-		elf32Sym := NewElf32Sym(bin[offset:])
-		offset += unsafe.Sizeof(elf32Sym)
-		symTbl = append(symTbl, elf32Sym)
+	symTbl := make([]Elf32_Sym, 0, cur.Len()/symSize)
+	for symSize <= cur.Len() {
+		symTbl = append(symTbl, NewElf32Sym(cur.ReadBytes(symSize)))
 	}
 	return symTbl
 }
 
 func NewElf32Shdr(bin []byte) Elf32_Shdr {
 	var elf32Shdr = Elf32_Shdr{}
-	var offset uintptr = 0
-
-	size := unsafe.Sizeof(Elf32_Word(0))
-	elf32Shdr.Sh_name, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Shdr.Sh_type, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Shdr.Sh_flags, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Addr(0))
-	elf32Shdr.Sh_addr, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Addr(0))
-	elf32Shdr.Sh_offset, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Xword(0))
-	elf32Shdr.Sh_size, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Shdr.Sh_link, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Shdr.Sh_info, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Xword(0))
-	elf32Shdr.Sh_addralign, _ = binutil.FromLeToUInt32(bin[offset:])
-
-	size = unsafe.Sizeof(Elf32_Xword(0))
-	elf32Shdr.Sh_entsize, _ = binutil.FromLeToUInt32(bin[offset:])
-
+	cur := binutil.NewLeCursor("Elf32_Shdr", bin)
+
+	elf32Shdr.Sh_name = cur.ReadU32()
+	elf32Shdr.Sh_type = cur.ReadU32()
+	elf32Shdr.Sh_flags = cur.ReadU32()
+	elf32Shdr.Sh_addr = cur.ReadU32()
+	elf32Shdr.Sh_offset = cur.ReadU32()
+	elf32Shdr.Sh_size = cur.ReadU32()
+	elf32Shdr.Sh_link = cur.ReadU32()
+	elf32Shdr.Sh_info = cur.ReadU32()
+	elf32Shdr.Sh_addralign = cur.ReadU32()
+	elf32Shdr.Sh_entsize = cur.ReadU32()
 	return elf32Shdr
 }
 
 func NewElf64Shdr(bin []byte) Elf64_Shdr {
 	var elf64Shdr = Elf64_Shdr{}
-	var offset uintptr = 0
-
-	size := unsafe.Sizeof(Elf64_Word(0))
-	elf64Shdr.Sh_name, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Word(0))
-	elf64Shdr.Sh_type, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Shdr.Sh_flags, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Addr(0))
-	elf64Shdr.Sh_addr, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Addr(0))
-	elf64Shdr.Sh_offset, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Shdr.Sh_size, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Word(0))
-	elf64Shdr.Sh_link, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Word(0))
-	elf64Shdr.Sh_info, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Shdr.Sh_addralign, _ = binutil.FromLeToUInt64(bin[offset:])
-
-	size = unsafe.Sizeof(Elf64_Xword(0))
-	elf64Shdr.Sh_entsize, _ = binutil.FromLeToUInt64(bin[offset:])
-
+	cur := binutil.NewLeCursor("Elf64_Shdr", bin)
+
+	elf64Shdr.Sh_name = cur.ReadU32()
+	elf64Shdr.Sh_type = cur.ReadU32()
+	elf64Shdr.Sh_flags = cur.ReadU64()
+	elf64Shdr.Sh_addr = cur.ReadU64()
+	elf64Shdr.Sh_offset = cur.ReadU64()
+	elf64Shdr.Sh_size = cur.ReadU64()
+	elf64Shdr.Sh_link = cur.ReadU32()
+	elf64Shdr.Sh_info = cur.ReadU32()
+	elf64Shdr.Sh_addralign = cur.ReadU64()
+	elf64Shdr.Sh_entsize = cur.ReadU64()
 	return elf64Shdr
 }
 
@@ -1487,6 +1639,26 @@ const (
 	STB_WEAK   = 2
 )
 
+// st_other
+const (
+	STV_DEFAULT   = 0
+	STV_INTERNAL  = 1
+	STV_HIDDEN    = 2
+	STV_PROTECTED = 3
+)
+
+var symBinds = map[uint8]string{
+	STB_LOCAL:  "LOCAL",
+	STB_GLOBAL: "GLOBAL",
+	STB_WEAK:   "WEAK",
+}
+
+var symVisibilities = [4]string{
+	"DEFAULT",
+	"INTERNAL",
+	"HIDDEN",
+	"PROTECTED"}
+
 var symTypes [16]string = [16]string{
 	"NOTYPE",
 	"OBJECT",
@@ -1505,7 +1677,7 @@ var symTypes [16]string = [16]string{
 	"SPARC_REGISTER",
 	"HIPROC"}
 
-var specialShNdx = [9]uint16{
+var specialShNdx = [10]uint16{
 	SHN_UNDEF,
 	SHN_LORESERVE,
 	SHN_LOPROC,
@@ -1514,6 +1686,7 @@ var specialShNdx = [9]uint16{
 	SHN_HIPROC,
 	SHN_ABS,
 	SHN_COMMON,
+	SHN_XINDEX,
 	SHN_HIRESERVE}
 
 func isSpecialShndx(shndx Elf64_Half) bool {
@@ -1530,40 +1703,45 @@ func getSymType(st_info uint8) string {
 	return symTypes[idx]
 }
 
+func GetSymTypeName(st_info uint8) string {
+	return getSymType(st_info)
+}
+
+func GetSymBindName(st_info uint8) string {
+	bind, exist := symBinds[st_info>>4]
+	if !exist {
+		return fmt.Sprintf("BIND_%d", st_info>>4)
+	}
+	return bind
+}
+
+func GetSymVisibilityName(st_other uint8) string {
+	return symVisibilities[st_other&0x03]
+}
+
 func (elfObj *Elf64Object) ShowSymTbl() {
 	var secName = ""
 	for i, sym := range elfObj.SymTbl {
 		str := elfObj.GetStrFromStrTbl(sym.St_name)
 		symType := getSymType(sym.St_info)
-		if !isSpecialShndx(sym.St_shndx) {
-			secName = elfObj.getSectionName(elfObj.Shdrs[sym.St_shndx].Sh_name)
+		if elfObj.IsSymInSection(i) && int(elfObj.GetSymShndx(i)) < len(elfObj.Shdrs) {
+			secName = elfObj.getSectionName(elfObj.Shdrs[elfObj.GetSymShndx(i)].Sh_name)
 		}
 		fmt.Printf("[%d]: %016x    %d %s %s %s\n", i, sym.St_value, sym.St_size, symType, secName, str)
 	}
 }
 
 func (elfObj *Elf64Object) getSectionName(sh_name Elf64_Word) string {
-	secName := ""
-	pos := sh_name
-	for {
-		if (elfObj.secNameStr[pos]) == 0 {
-			break
-		}
-		secName += string(elfObj.secNameStr[pos])
-		pos++
-	}
-	return secName
+	return elfObj.secNameStr.Get(uint64(sh_name))
 }
 
 func getElf64SymTbl(bin []byte) []Elf64_Sym {
-	len := len(bin)
-	var offset uintptr = 0
+	symSize := uint64(unsafe.Sizeof(Elf64_Sym{}))
+	cur := binutil.NewLeCursor("Elf64_Sym", bin)
 
-	symTbl := []Elf64_Sym{}
-	for int(offset) < len {
-		elf64Sym := NewElf64Sym(bin[offset:])
-		offset += unsafe.Sizeof(elf64Sym)
-		symTbl = append(symTbl, elf64Sym)
+	symTbl := make([]Elf64_Sym, 0, cur.Len()/symSize)
+	for symSize <= cur.Len() {
+		symTbl = append(symTbl, NewElf64Sym(cur.ReadBytes(symSize)))
 	}
 	return symTbl
 }
@@ -1588,65 +1766,35 @@ type ElfFunctionInfo struct {
 
 func NewElf32Sym(bin []byte) Elf32_Sym {
 	elf32Sym := Elf32_Sym{}
-	var offset uintptr = 0
-
-	size := unsafe.Sizeof(Elf32_Word(0))
-	elf32Sym.St_name, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Addr(0))
-	elf32Sym.St_value, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf32_Word(0))
-	elf32Sym.St_size, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(uint8(0))
-	elf32Sym.St_info = bin[offset]
-	offset += size
-
-	size = unsafe.Sizeof(uint8(0))
-	elf32Sym.St_other = bin[offset]
-	offset += size
-
-	elf32Sym.St_shndx, _ = binutil.FromLeToUInt16(bin[offset:])
+	cur := binutil.NewLeCursor("Elf32_Sym", bin)
+
+	elf32Sym.St_name = cur.ReadU32()
+	elf32Sym.St_value = cur.ReadU32()
+	elf32Sym.St_size = cur.ReadU32()
+	elf32Sym.St_info = cur.ReadU8()
+	elf32Sym.St_other = cur.ReadU8()
+	elf32Sym.St_shndx = cur.ReadU16()
 	return elf32Sym
 }
 
 func NewElf64Sym(bin []byte) Elf64_Sym {
 	elf64Sym := Elf64_Sym{}
-	var offset uintptr = 0
-	size := unsafe.Sizeof(Elf64_Word(0))
-	elf64Sym.St_name, _ = binutil.FromLeToUInt32(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(uint8(0))
-
-	elf64Sym.St_info = bin[offset]
-	offset += size
-
-	size = unsafe.Sizeof(uint8(0))
-	elf64Sym.St_other = bin[offset]
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Half(0))
-	elf64Sym.St_shndx, _ = binutil.FromLeToUInt16(bin[offset:])
-	offset += size
-
-	size = unsafe.Sizeof(Elf64_Addr(0))
-	elf64Sym.St_value, _ = binutil.FromLeToUInt64(bin[offset:])
-	offset += size
-
-	elf64Sym.St_size, _ = binutil.FromLeToUInt64(bin[offset:])
+	cur := binutil.NewLeCursor("Elf64_Sym", bin)
+
+	elf64Sym.St_name = cur.ReadU32()
+	elf64Sym.St_info = cur.ReadU8()
+	elf64Sym.St_other = cur.ReadU8()
+	elf64Sym.St_shndx = cur.ReadU16()
+	elf64Sym.St_value = cur.ReadU64()
+	elf64Sym.St_size = cur.ReadU64()
 	return elf64Sym
 }
 
 func (elfObj *Elf64Object) getElf64Functions() []ElfFunctionInfo {
 	funcs := []ElfFunctionInfo{}
-	for _, sym := range elfObj.SymTbl {
+	for i, sym := range elfObj.SymTbl {
 		if sym.St_info&0x0F == STT_FUNC {
-			if isSpecialShndx(sym.St_shndx) {
+			if !elfObj.IsSymInSection(i) {
 				continue
 			}
 
@@ -1655,7 +1803,15 @@ func (elfObj *Elf64Object) getElf64Functions() []ElfFunctionInfo {
 			f.Addr = sym.St_value
 			f.Size = sym.St_size
 
-			sh := elfObj.Shdrs[sym.St_shndx]
+			shndx := elfObj.GetSymShndx(i)
+			if len(elfObj.Shdrs) <= int(shndx) {
+				continue
+			}
+			sh := elfObj.Shdrs[shndx]
+			if sh.Sh_type == SHT_NOBITS || uint64(sh.Sh_size) < f.Size {
+				// broken symbol without code or larger than its section, its range would take the line addresses of other functions
+				continue
+			}
 			f.SecName = elfObj.getSectionName(sh.Sh_name)
 			f.LineAddrs = map[uint64]LineAddrInfo{}
 			funcs = append(funcs, f)
@@ -1663,3 +1819,107 @@ func (elfObj *Elf64Object) getElf64Functions() []ElfFunctionInfo {
 	}
 	return funcs
 }
+
+func NewElf64Rela(bin []byte, hasAddend bool) Elf64_Rela {
+	elf64Rela := Elf64_Rela{}
+	cur := binutil.NewLeCursor("Elf64_Rela", bin)
+
+	elf64Rela.R_offset = cur.ReadU64()
+	elf64Rela.R_info = cur.ReadU64()
+	if hasAddend {
+		elf64Rela.R_addend = cur.ReadS64()
+	}
+	return elf64Rela
+}
+
+// GetRelocations reads a SHT_REL or SHT_RELA section, r_addend is 0 for SHT_REL
+func (elfObj *Elf64Object) GetRelocations(shIdx int) []Elf64_Rela {
+	sh := elfObj.Shdrs[shIdx]
+	entSize := uint64(unsafe.Sizeof(Elf64_Rela{}))
+	hasAddend := sh.Sh_type == SHT_RELA
+	if !hasAddend {
+		entSize = uint64(unsafe.Sizeof(Elf64_Rel{}))
+	}
+
+	relas := []Elf64_Rela{}
+	// SHT_NULL/SHT_NOBITS sections are not range checked by NewElf64
+	if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+		return relas
+	}
+	bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	cur := binutil.NewLeCursor("Elf64_Rela", bin)
+	for uint64(entSize) <= cur.Len() {
+		relas = append(relas, NewElf64Rela(cur.ReadBytes(uint64(entSize)), hasAddend))
+	}
+	return relas
+}
+
+// GetRelocSectionIdxs returns the relocation sections that refer to the symbol table at symTabIdx
+func (elfObj *Elf64Object) GetRelocSectionIdxs(symTabIdx int) []int {
+	shIdxs := []int{}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+			continue
+		}
+		if int(sh.Sh_link) == symTabIdx {
+			shIdxs = append(shIdxs, i)
+		}
+	}
+	return shIdxs
+}
+
+func (elfObj *Elf64Object) GetSectionName(shIdx int) string {
+	return elfObj.getSectionName(elfObj.Shdrs[shIdx].Sh_name)
+}
+
+func NewElf32Rela(bin []byte, hasAddend bool) Elf32_Rela {
+	elf32Rela := Elf32_Rela{}
+	cur := binutil.NewLeCursor("Elf32_Rela", bin)
+
+	elf32Rela.R_offset = cur.ReadU32()
+	elf32Rela.R_info = cur.ReadU32()
+	if hasAddend {
+		elf32Rela.R_addend = cur.ReadS32()
+	}
+	return elf32Rela
+}
+
+// GetRelocations reads a SHT_REL or SHT_RELA section, r_addend is 0 for SHT_REL
+func (elfObj *Elf32Object) GetRelocations(shIdx int) []Elf32_Rela {
+	sh := elfObj.Shdrs[shIdx]
+	entSize := uint32(unsafe.Sizeof(Elf32_Rela{}))
+	hasAddend := sh.Sh_type == SHT_RELA
+	if !hasAddend {
+		entSize = uint32(unsafe.Sizeof(Elf32_Rel{}))
+	}
+
+	relas := []Elf32_Rela{}
+	// SHT_NULL/SHT_NOBITS sections are not range checked by NewElf32
+	if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+		return relas
+	}
+	bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	cur := binutil.NewLeCursor("Elf32_Rela", bin)
+	for uint64(entSize) <= cur.Len() {
+		relas = append(relas, NewElf32Rela(cur.ReadBytes(uint64(entSize)), hasAddend))
+	}
+	return relas
+}
+
+// GetRelocSectionIdxs returns the relocation sections that refer to the symbol table at symTabIdx
+func (elfObj *Elf32Object) GetRelocSectionIdxs(symTabIdx int) []int {
+	shIdxs := []int{}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+			continue
+		}
+		if int(sh.Sh_link) == symTabIdx {
+			shIdxs = append(shIdxs, i)
+		}
+	}
+	return shIdxs
+}
+
+func (elfObj *Elf32Object) GetSectionName(shIdx int) string {
+	return elfObj.getSectionName(elfObj.Shdrs[shIdx].Sh_name)
+}
@@ -0,0 +1,89 @@
+package elf
+
+import (
+	"os"
+	"testing"
+)
+
+var elfSeeds = []string{
+	"../testdata/func_x86_64.o",
+	"../testdata/func_x86_64_dwarf4.o",
+	"../testdata/func_i386.o",
+	"../testdata/versioned_x86_64.so",
+	"../examples/func.obj",
+}
+
+func addSeeds(f *testing.F, paths []string) {
+	for _, path := range paths {
+		bin, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(bin)
+	}
+}
+
+func FuzzNewElf64(f *testing.F) {
+	addSeeds(f, elfSeeds)
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		elfObj, err := NewElf64("fuzz", bin)
+		if err != nil {
+			return
+		}
+		elfObj.Verify()
+		for i := range elfObj.Shdrs {
+			elfObj.GetSectionName(i)
+			elfObj.GetRelocations(i)
+			elfObj.GetRelocatedSectionBin(i)
+		}
+		for i := range elfObj.SymTbl {
+			elfObj.GetStrFromStrTbl(elfObj.SymTbl[i].St_name)
+		}
+		elfObj.ReadDynamic(elfObj.GetSectionBinByName(".dynamic"))
+		tab, err := elfObj.ReadDynSymbols()
+		if err == nil {
+			for _, sym := range tab.Symbols {
+				tab.Lookup(sym.Name)
+			}
+		}
+
+		out, err := NewElf64Writer(elfObj).Bytes()
+		if err != nil {
+			return
+		}
+		if _, err := NewElf64("fuzz", out); err != nil {
+			t.Fatalf("written object does not parse: %v", err)
+		}
+	})
+}
+
+func FuzzNewElf32(f *testing.F) {
+	addSeeds(f, elfSeeds)
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		elfObj, err := NewElf32("fuzz", bin)
+		if err != nil {
+			return
+		}
+		for i := range elfObj.Shdrs {
+			elfObj.GetRelocatedSectionBin(i)
+		}
+		for i := range elfObj.SymTbl {
+			elfObj.GetStrFromStrTbl(elfObj.SymTbl[i].St_name)
+		}
+		elfObj.ReadDynamic(elfObj.GetSectionBinByName(".dynamic"))
+		tab, err := elfObj.ReadDynSymbols()
+		if err == nil {
+			for _, sym := range tab.Symbols {
+				tab.Lookup(sym.Name)
+			}
+		}
+
+		out, err := NewElf32Writer(elfObj).Bytes()
+		if err != nil {
+			return
+		}
+		if _, err := NewElf32("fuzz", out); err != nil {
+			t.Fatalf("written object does not parse: %v", err)
+		}
+	})
+}
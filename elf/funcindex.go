@@ -0,0 +1,109 @@
+package elf
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+type funcAddrRange struct {
+	Start   uint64
+	Last    uint64 // inclusive, a function may run to the end of the address space
+	FuncIdx int
+}
+
+// FuncAddrIndex finds the function containing an address in O(log n).
+// It holds sorted ranges that do not overlap: where functions overlap the one
+// later in the function list owns the addresses, zero-size functions own none.
+type FuncAddrIndex struct {
+	ranges []funcAddrRange
+}
+
+// funcIdxHeap is a max-heap of function indexes
+type funcIdxHeap []int
+
+func (h funcIdxHeap) Len() int           { return len(h) }
+func (h funcIdxHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h funcIdxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *funcIdxHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *funcIdxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func NewFuncAddrIndex(funcs []ElfFunctionInfo) FuncAddrIndex {
+	type bound struct {
+		addr    uint64
+		funcIdx int
+		isStart bool
+		atTop   bool // the end of a function running to the end of the address space
+	}
+	bounds := []bound{}
+	for funcIdx, f := range funcs {
+		if f.Size == 0 {
+			continue
+		}
+		end := f.Addr + f.Size
+		if end <= f.Addr {
+			// the function runs to the end of the address space, these ends sort last as one bound
+			bounds = append(bounds, bound{f.Addr, funcIdx, true, false}, bound{0, funcIdx, false, true})
+			continue
+		}
+		bounds = append(bounds, bound{f.Addr, funcIdx, true, false}, bound{end, funcIdx, false, false})
+	}
+	sort.Slice(bounds, func(i, j int) bool {
+		if bounds[i].atTop != bounds[j].atTop {
+			return bounds[j].atTop
+		}
+		return bounds[i].addr < bounds[j].addr
+	})
+
+	// sweep the bounds keeping the functions covering the current address,
+	// ended functions are dropped once they reach the top of the heap
+	idx := FuncAddrIndex{}
+	active := funcIdxHeap{}
+	ended := make([]bool, len(funcs))
+	for i := 0; i < len(bounds); {
+		addr, atTop := bounds[i].addr, bounds[i].atTop
+		for ; i < len(bounds) && bounds[i].addr == addr && bounds[i].atTop == atTop; i++ {
+			if bounds[i].isStart {
+				heap.Push(&active, bounds[i].funcIdx)
+			} else {
+				ended[bounds[i].funcIdx] = true
+			}
+		}
+		for 0 < active.Len() && ended[active[0]] {
+			heap.Pop(&active)
+		}
+		if active.Len() == 0 || i == len(bounds) {
+			continue
+		}
+
+		// only ends are at the top, the range runs to the next bound
+		var last uint64 = math.MaxUint64
+		if !bounds[i].atTop {
+			last = bounds[i].addr - 1
+		}
+		funcIdx := active[0]
+		prev := len(idx.ranges) - 1
+		if 0 <= prev && idx.ranges[prev].Last+1 == addr && idx.ranges[prev].FuncIdx == funcIdx {
+			idx.ranges[prev].Last = last
+			continue
+		}
+		idx.ranges = append(idx.ranges, funcAddrRange{addr, last, funcIdx})
+	}
+	return idx
+}
+
+// Lookup returns the index of the function containing addr, or -1
+func (idx FuncAddrIndex) Lookup(addr uint64) int {
+	i := sort.Search(len(idx.ranges), func(i int) bool {
+		return addr <= idx.ranges[i].Last
+	})
+	if i < len(idx.ranges) && idx.ranges[i].Start <= addr {
+		return idx.ranges[i].FuncIdx
+	}
+	return -1
+}
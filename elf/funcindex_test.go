@@ -0,0 +1,107 @@
+package elf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lookupRef is the reference for FuncAddrIndex.Lookup: the last function of
+// funcs containing addr, zero-size functions contain no address
+func lookupRef(funcs []ElfFunctionInfo, addr uint64) int {
+	found := -1
+	for i, f := range funcs {
+		if f.Size == 0 || addr < f.Addr {
+			continue
+		}
+		// a function running past the end of the address space ends there
+		if f.Addr+f.Size < f.Addr || addr-f.Addr < f.Size {
+			found = i
+		}
+	}
+	return found
+}
+
+// checkFuncAddrIndex compares every address in [from, to] and the ranges of the index
+func checkFuncAddrIndex(t *testing.T, name string, funcs []ElfFunctionInfo, from uint64, to uint64) {
+	t.Helper()
+	idx := NewFuncAddrIndex(funcs)
+	for addr := from; ; addr++ {
+		if got, want := idx.Lookup(addr), lookupRef(funcs, addr); got != want {
+			t.Errorf("%s: Lookup(0x%x) = %d, want %d", name, addr, got, want)
+		}
+		if addr == to {
+			break
+		}
+	}
+	for i, r := range idx.ranges {
+		if r.Last < r.Start || (0 < i && r.Start <= idx.ranges[i-1].Last) {
+			t.Errorf("%s: range %d [0x%x, 0x%x] is empty or overlaps the previous one", name, i, r.Start, r.Last)
+		}
+	}
+}
+
+func newFuncs(ranges ...[2]uint64) []ElfFunctionInfo {
+	funcs := []ElfFunctionInfo{}
+	for _, r := range ranges {
+		funcs = append(funcs, ElfFunctionInfo{Addr: r[0], Size: r[1]})
+	}
+	return funcs
+}
+
+func TestFuncAddrIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		funcs []ElfFunctionInfo
+	}{
+		{"empty", newFuncs()},
+		{"single", newFuncs([2]uint64{0x10, 0x8})},
+		{"adjacent", newFuncs([2]uint64{0x10, 0x8}, [2]uint64{0x18, 0x8}, [2]uint64{0x20, 0x4})},
+		{"gap", newFuncs([2]uint64{0x10, 0x4}, [2]uint64{0x20, 0x4})},
+		{"unsorted", newFuncs([2]uint64{0x20, 0x4}, [2]uint64{0x10, 0x8}, [2]uint64{0x18, 0x8})},
+		{"zero size", newFuncs([2]uint64{0x10, 0x0}, [2]uint64{0x10, 0x8}, [2]uint64{0x14, 0x0})},
+		{"zero size only", newFuncs([2]uint64{0x10, 0x0}, [2]uint64{0x18, 0x0})},
+		{"overlapping later wins", newFuncs([2]uint64{0x10, 0x10}, [2]uint64{0x18, 0x10})},
+		{"overlapping earlier loses", newFuncs([2]uint64{0x18, 0x10}, [2]uint64{0x10, 0x10})},
+		{"nested later", newFuncs([2]uint64{0x10, 0x20}, [2]uint64{0x18, 0x8})},
+		{"nested earlier", newFuncs([2]uint64{0x18, 0x8}, [2]uint64{0x10, 0x20})},
+		{"nested deep", newFuncs([2]uint64{0x10, 0x30}, [2]uint64{0x14, 0x20}, [2]uint64{0x18, 0x8}, [2]uint64{0x30, 0x4})},
+		{"same range", newFuncs([2]uint64{0x10, 0x8}, [2]uint64{0x10, 0x8})},
+		{"same start", newFuncs([2]uint64{0x10, 0x10}, [2]uint64{0x10, 0x4})},
+		{"same end", newFuncs([2]uint64{0x10, 0x10}, [2]uint64{0x1c, 0x4})},
+		{"address 0", newFuncs([2]uint64{0x0, 0x4}, [2]uint64{0x4, 0x4})},
+	}
+	for _, test := range tests {
+		checkFuncAddrIndex(t, test.name, test.funcs, 0, 0x50)
+	}
+
+	// the end of the address space
+	top := newFuncs([2]uint64{math.MaxUint64 - 0xf, 0x10}, [2]uint64{math.MaxUint64 - 0x7, 0x100}, [2]uint64{math.MaxUint64, 0x1})
+	checkFuncAddrIndex(t, "end of address space", top, math.MaxUint64-0x20, math.MaxUint64)
+}
+
+func TestFuncAddrIndexRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		// every other set is at the end of the address space
+		var base uint64 = 0
+		if n%2 == 1 {
+			base = math.MaxUint64 - 63
+		}
+		funcs := []ElfFunctionInfo{}
+		for i := rng.Intn(12); 0 < i; i-- {
+			// small addresses make functions overlap, nest, touch and repeat
+			addr := base + uint64(rng.Intn(64))
+			size := uint64(rng.Intn(5))
+			if rng.Intn(4) == 0 {
+				size = uint64(rng.Intn(40))
+			}
+			funcs = append(funcs, ElfFunctionInfo{Addr: addr, Size: size})
+		}
+		// past the end of the address space the check wraps to address 0
+		checkFuncAddrIndex(t, "random", funcs, base, base+127)
+		if t.Failed() {
+			t.Fatalf("functions %v", funcs)
+		}
+	}
+}
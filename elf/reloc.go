@@ -0,0 +1,222 @@
+package elf
+
+import (
+	"fmt"
+
+	binutil "sym-exposer/binutil"
+)
+
+// relocation types of the data in debug sections
+const (
+	R_X86_64_NONE   = 0
+	R_X86_64_64     = 1  // Direct 64 bit
+	R_X86_64_32     = 10 // Direct 32 bit zero extended
+	R_X86_64_32S    = 11 // Direct 32 bit sign extended
+	R_386_NONE      = 0
+	R_386_32        = 1 // Direct 32 bit
+	R_ARM_NONE      = 0
+	R_ARM_ABS32     = 2 // Direct 32 bit
+	R_AARCH64_NONE  = 0
+	R_AARCH64_ABS64 = 257 // Direct 64 bit
+	R_AARCH64_ABS32 = 258 // Direct 32 bit
+	R_RISCV_NONE    = 0
+	R_RISCV_32      = 1 // Direct 32 bit
+	R_RISCV_64      = 2 // Direct 64 bit
+)
+
+// absRelocSize returns the size of the field an absolute relocation (S + A)
+// of the machine writes, 0 for the types that are not applied
+func absRelocSize(machine uint16, rType uint32) uint64 {
+	switch machine {
+	case MACHINE_ARCH_AMD:
+		switch rType {
+		case R_X86_64_64:
+			return 8
+		case R_X86_64_32, R_X86_64_32S:
+			return 4
+		}
+	case MACHINE_ARCH_X86:
+		if rType == R_386_32 {
+			return 4
+		}
+	case MACHINE_ARCH_ARM:
+		if rType == R_ARM_ABS32 {
+			return 4
+		}
+	case MACHINE_ARCH_ARM_AARCH64:
+		switch rType {
+		case R_AARCH64_ABS64:
+			return 8
+		case R_AARCH64_ABS32:
+			return 4
+		}
+	case MACHINE_ARCH_ARM_RISCV:
+		switch rType {
+		case R_RISCV_64:
+			return 8
+		case R_RISCV_32:
+			return 4
+		}
+	}
+	return 0
+}
+
+// applyReloc writes S + A to the field of size at offset, a SHT_REL relocation
+// takes A from the field
+func applyReloc(data []byte, offset uint64, size uint64, symValue uint64, addend int64, inPlace bool) error {
+	err := binutil.CheckRange(data, "relocation", offset, size)
+	if err != nil {
+		return err
+	}
+	if inPlace {
+		addend = int64(binutil.NewLeCursor("relocation", data[offset:]).ReadUint(int(size)))
+	}
+	w := binutil.NewLeWriter()
+	w.PutUint(int(size), symValue+uint64(addend))
+	copy(data[offset:], w.Bytes())
+	return nil
+}
+
+// GetRelocatedSectionBin returns the contents of the section with the relocations
+// of a relocatable object applied, like a linker placing every section at address 0.
+// Debug sections of a .o refer to other sections this way: without the relocations
+// every DW_FORM_strp and DW_AT_low_pc reads as 0. Only absolute relocations are
+// applied, other types and other object types return the section as is.
+func (elfObj *Elf64Object) GetRelocatedSectionBin(shIdx int) ([]byte, error) {
+	sh := elfObj.Shdrs[shIdx]
+	if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+		return nil, nil
+	}
+	bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	if elfObj.Elf64Ehdr.E_type != ET_REL {
+		return bin, nil
+	}
+
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		return bin, nil
+	}
+	var data []byte
+	for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+		relSh := elfObj.Shdrs[relIdx]
+		if int(relSh.Sh_info) != shIdx {
+			continue
+		}
+		if data == nil {
+			// the input may be a read-only mapping
+			data = append([]byte{}, bin...)
+		}
+		for i, rela := range elfObj.GetRelocations(relIdx) {
+			size := absRelocSize(elfObj.Elf64Ehdr.E_machine, ELF64_R_TYPE(rela.R_info))
+			symIdx := ELF64_R_SYM(rela.R_info)
+			if size == 0 || len(elfObj.SymTbl) <= int(symIdx) {
+				continue
+			}
+			symValue := elfObj.SymTbl[symIdx].St_value
+			err := applyReloc(data, rela.R_offset, size, symValue, rela.R_addend, relSh.Sh_type == SHT_REL)
+			if err != nil {
+				return nil, fmt.Errorf("%s [%d]: %w", elfObj.GetSectionName(relIdx), i, err)
+			}
+		}
+	}
+	if data == nil {
+		return bin, nil
+	}
+	return data, nil
+}
+
+// GetRelocatedSectionBinByName returns the relocated contents of the named section, nil if there is none
+func (elfObj *Elf64Object) GetRelocatedSectionBinByName(name string) ([]byte, error) {
+	shIdx, exist := elfObj.SectionNameMap[name]
+	if !exist {
+		return nil, nil
+	}
+	return elfObj.GetRelocatedSectionBin(shIdx)
+}
+
+// GetRelocatedSectionBinsByName returns the relocated contents of every SHT_PROGBITS
+// section named name in section order. g++ -fdebug-types-section puts each type unit
+// in a .debug_types of its own COMDAT group, each with its own relocations.
+func (elfObj *Elf64Object) GetRelocatedSectionBinsByName(name string) ([][]byte, error) {
+	bins := [][]byte{}
+	for shIdx, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_PROGBITS || elfObj.GetSectionName(shIdx) != name {
+			continue
+		}
+		bin, err := elfObj.GetRelocatedSectionBin(shIdx)
+		if err != nil {
+			return bins, err
+		}
+		bins = append(bins, bin)
+	}
+	return bins, nil
+}
+
+// GetRelocatedSectionBin is the ELF32 version of Elf64Object.GetRelocatedSectionBin
+func (elfObj *Elf32Object) GetRelocatedSectionBin(shIdx int) ([]byte, error) {
+	sh := elfObj.Shdrs[shIdx]
+	if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS {
+		return nil, nil
+	}
+	bin := elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+	if elfObj.Elf32Ehdr.E_type != ET_REL {
+		return bin, nil
+	}
+
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		return bin, nil
+	}
+	var data []byte
+	for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+		relSh := elfObj.Shdrs[relIdx]
+		if int(relSh.Sh_info) != shIdx {
+			continue
+		}
+		if data == nil {
+			// the input may be a read-only mapping
+			data = append([]byte{}, bin...)
+		}
+		for i, rela := range elfObj.GetRelocations(relIdx) {
+			size := absRelocSize(elfObj.Elf32Ehdr.E_machine, ELF32_R_TYPE(rela.R_info))
+			symIdx := ELF32_R_SYM(rela.R_info)
+			if size == 0 || len(elfObj.SymTbl) <= int(symIdx) {
+				continue
+			}
+			symValue := uint64(elfObj.SymTbl[symIdx].St_value)
+			err := applyReloc(data, uint64(rela.R_offset), size, symValue, int64(rela.R_addend), relSh.Sh_type == SHT_REL)
+			if err != nil {
+				return nil, fmt.Errorf("%s [%d]: %w", elfObj.GetSectionName(relIdx), i, err)
+			}
+		}
+	}
+	if data == nil {
+		return bin, nil
+	}
+	return data, nil
+}
+
+// GetRelocatedSectionBinByName is the ELF32 version of Elf64Object.GetRelocatedSectionBinByName
+func (elfObj *Elf32Object) GetRelocatedSectionBinByName(name string) ([]byte, error) {
+	shIdx, exist := elfObj.SectionNameMap[name]
+	if !exist {
+		return nil, nil
+	}
+	return elfObj.GetRelocatedSectionBin(shIdx)
+}
+
+// GetRelocatedSectionBinsByName is the ELF32 version of Elf64Object.GetRelocatedSectionBinsByName
+func (elfObj *Elf32Object) GetRelocatedSectionBinsByName(name string) ([][]byte, error) {
+	bins := [][]byte{}
+	for shIdx, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_PROGBITS || elfObj.GetSectionName(shIdx) != name {
+			continue
+		}
+		bin, err := elfObj.GetRelocatedSectionBin(shIdx)
+		if err != nil {
+			return bins, err
+		}
+		bins = append(bins, bin)
+	}
+	return bins, nil
+}
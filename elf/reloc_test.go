@@ -0,0 +1,118 @@
+package elf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAbsRelocSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine uint16
+		rType   uint32
+		want    uint64
+	}{
+		{"R_X86_64_64", MACHINE_ARCH_AMD, R_X86_64_64, 8},
+		{"R_X86_64_32", MACHINE_ARCH_AMD, R_X86_64_32, 4},
+		{"R_X86_64_32S", MACHINE_ARCH_AMD, R_X86_64_32S, 4},
+		{"R_X86_64_NONE", MACHINE_ARCH_AMD, R_X86_64_NONE, 0},
+		{"R_X86_64_PC32", MACHINE_ARCH_AMD, 2, 0},
+		{"R_386_32", MACHINE_ARCH_X86, R_386_32, 4},
+		{"R_386_PC32", MACHINE_ARCH_X86, 2, 0},
+		{"R_ARM_ABS32", MACHINE_ARCH_ARM, R_ARM_ABS32, 4},
+		{"R_ARM_REL32", MACHINE_ARCH_ARM, 3, 0},
+		{"R_AARCH64_ABS64", MACHINE_ARCH_ARM_AARCH64, R_AARCH64_ABS64, 8},
+		{"R_AARCH64_ABS32", MACHINE_ARCH_ARM_AARCH64, R_AARCH64_ABS32, 4},
+		{"R_AARCH64_PREL32", MACHINE_ARCH_ARM_AARCH64, 261, 0},
+		{"R_RISCV_64", MACHINE_ARCH_ARM_RISCV, R_RISCV_64, 8},
+		{"R_RISCV_32", MACHINE_ARCH_ARM_RISCV, R_RISCV_32, 4},
+		// the type numbers are per machine
+		{"R_X86_64_64 on ARM", MACHINE_ARCH_ARM, R_X86_64_64, 0},
+		{"unknown machine", MACHINE_ARCH_NONE, R_X86_64_64, 0},
+	}
+	for _, test := range tests {
+		if got := absRelocSize(test.machine, test.rType); got != test.want {
+			t.Errorf("%s: absRelocSize(%d, %d) = %d, want %d", test.name, test.machine, test.rType, got, test.want)
+		}
+	}
+}
+
+func TestApplyReloc(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		offset   uint64
+		machine  uint16
+		rType    uint32
+		symValue uint64
+		addend   int64
+		inPlace  bool
+		want     []byte
+	}{
+		{
+			name: "R_X86_64_64", machine: MACHINE_ARCH_AMD, rType: R_X86_64_64,
+			data:     []byte{0xAA, 0, 0, 0, 0, 0, 0, 0, 0, 0xBB},
+			offset:   1,
+			symValue: 0x1122334455667700, addend: 0x88,
+			want: []byte{0xAA, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0xBB},
+		},
+		{
+			name: "R_X86_64_32", machine: MACHINE_ARCH_AMD, rType: R_X86_64_32,
+			data:     []byte{0, 0, 0, 0, 0xBB},
+			symValue: 0x1000, addend: 0x34,
+			want: []byte{0x34, 0x10, 0, 0, 0xBB},
+		},
+		{
+			name: "R_X86_64_32 negative addend", machine: MACHINE_ARCH_AMD, rType: R_X86_64_32,
+			data:     []byte{0, 0, 0, 0},
+			symValue: 0x40, addend: -0x10,
+			want: []byte{0x30, 0, 0, 0},
+		},
+		{
+			name: "R_AARCH64_ABS64", machine: MACHINE_ARCH_ARM_AARCH64, rType: R_AARCH64_ABS64,
+			data:     make([]byte, 8),
+			symValue: 0x400000, addend: 0x8,
+			want: []byte{0x08, 0, 0x40, 0, 0, 0, 0, 0},
+		},
+		{
+			name: "R_AARCH64_ABS32", machine: MACHINE_ARCH_ARM_AARCH64, rType: R_AARCH64_ABS32,
+			data:     []byte{0xBB, 0, 0, 0, 0},
+			offset:   1,
+			symValue: 0x20, addend: 0x2,
+			want: []byte{0xBB, 0x22, 0, 0, 0},
+		},
+		{
+			// SHT_REL, the addend is the content of the field
+			name: "R_386_32", machine: MACHINE_ARCH_X86, rType: R_386_32,
+			data:     []byte{0x5D, 0, 0, 0},
+			symValue: 0x100, addend: 0x7777, inPlace: true,
+			want: []byte{0x5D, 0x01, 0, 0},
+		},
+		{
+			name: "R_ARM_ABS32", machine: MACHINE_ARCH_ARM, rType: R_ARM_ABS32,
+			data:     []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			symValue: 0x10, inPlace: true,
+			want: []byte{0x0F, 0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		size := absRelocSize(test.machine, test.rType)
+		err := applyReloc(test.data, test.offset, size, test.symValue, test.addend, test.inPlace)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(test.data, test.want) {
+			t.Errorf("%s: got % x, want % x", test.name, test.data, test.want)
+		}
+	}
+
+	// the field must be in the section
+	data := make([]byte, 6)
+	if err := applyReloc(data, 3, 4, 0x10, 0, false); err == nil {
+		t.Errorf("field past the end of the section: applyReloc() = nil, want an error")
+	}
+	if !bytes.Equal(data, make([]byte, 6)) {
+		t.Errorf("field past the end of the section: data changed to % x", data)
+	}
+}
@@ -0,0 +1,436 @@
+package elf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	binutil "sym-exposer/binutil"
+)
+
+const GRP_COMDAT = 0x1 // Mark group as COMDAT
+
+// newIdxMap maps old indexes to new ones after removing entries, removed entries map to -1
+func newIdxMap(removed []bool) []int {
+	idxMap := make([]int, len(removed))
+	newIdx := 0
+	for i := range removed {
+		if removed[i] {
+			idxMap[i] = -1
+			continue
+		}
+		idxMap[i] = newIdx
+		newIdx++
+	}
+	return idxMap
+}
+
+func isRelocSection(shType uint32) bool {
+	return shType == SHT_REL || shType == SHT_RELA
+}
+
+// hasInfoLink reports whether sh_info holds a section index
+func hasInfoLink(shType uint32, shFlags uint64) bool {
+	return isRelocSection(shType) || shFlags&SHF_INFO_LINK != 0
+}
+
+// groupHasMembers reports whether a SHT_GROUP section keeps any member after the removal
+func groupHasMembers(data []byte, removed []bool) bool {
+	for offset := 4; offset+4 <= len(data); offset += 4 {
+		member := binary.LittleEndian.Uint32(data[offset:])
+		if int(member) >= len(removed) || !removed[member] {
+			return true
+		}
+	}
+	return false
+}
+
+// remapGroup renumbers the members of a SHT_GROUP section, the first word is the group flags
+func remapGroup(data []byte, idxMap []int) []byte {
+	if len(data) < 4 {
+		return data
+	}
+	out := binutil.NewLeWriter()
+	out.PutBytes(data[:4])
+	for offset := 4; offset+4 <= len(data); offset += 4 {
+		member := binary.LittleEndian.Uint32(data[offset:])
+		if int(member) < len(idxMap) {
+			if idxMap[member] < 0 {
+				continue
+			}
+			member = uint32(idxMap[member])
+		}
+		out.PutU32(member)
+	}
+	return out.Bytes()
+}
+
+// markRemovedSections adds the relocation sections of removed sections and
+// the groups left without members to removed
+func markRemovedSections(removed []bool, shTypes []uint32, shInfos []uint32, datas [][]byte) {
+	for changed := true; changed; {
+		changed = false
+		for i := range removed {
+			if removed[i] {
+				continue
+			}
+			if isRelocSection(shTypes[i]) && 0 < shInfos[i] && int(shInfos[i]) < len(removed) && removed[shInfos[i]] {
+				removed[i] = true
+				changed = true
+			}
+			if shTypes[i] == SHT_GROUP && !groupHasMembers(datas[i], removed) {
+				removed[i] = true
+				changed = true
+			}
+		}
+	}
+}
+
+// AddSection appends a SHT_PROGBITS section without flags like objcopy --add-section
+// and returns its index, Shdr of the new section can be changed before writing
+func (w *Elf64Writer) AddSection(name string, data []byte) int {
+	sec := Elf64Section{}
+	sec.Name = name
+	sec.Shdr.Sh_type = SHT_PROGBITS
+	sec.Shdr.Sh_addralign = 1
+	sec.Data = append([]byte{}, data...)
+	w.Sections = append(w.Sections, sec)
+	return len(w.Sections) - 1
+}
+
+// RenameSection renames every section named oldName
+func (w *Elf64Writer) RenameSection(oldName string, newName string) error {
+	found := false
+	for i := range w.Sections {
+		if i != 0 && w.Sections[i].Name == oldName {
+			w.Sections[i].Name = newName
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("section %s not found", oldName)
+	}
+	return nil
+}
+
+// RemoveSections removes every section with one of the names together with their
+// relocation sections, the symbols defined in them and emptied groups.
+// sh_link, sh_info, group members and symbol indexes in relocations are renumbered.
+func (w *Elf64Writer) RemoveSections(names ...string) error {
+	removed := make([]bool, len(w.Sections))
+	for _, name := range names {
+		found := false
+		for i, sec := range w.Sections {
+			if i != 0 && sec.Name == name {
+				removed[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("section %s not found", name)
+		}
+	}
+	return w.removeSections(removed)
+}
+
+// rebuiltSections returns the indexes of .shstrtab, .symtab, its string table and
+// SHT_SYMTAB_SHNDX section, the sections Bytes generates
+func (w *Elf64Writer) rebuiltSections() []int {
+	idxs := []int{w.ShstrIdx, w.SymTabIdx}
+	if 0 <= w.SymTabIdx {
+		idxs = append(idxs, int(w.Sections[w.SymTabIdx].Shdr.Sh_link))
+	}
+	for i, sec := range w.Sections {
+		if sec.Shdr.Sh_type == SHT_SYMTAB_SHNDX && int(sec.Shdr.Sh_link) == w.SymTabIdx {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+func (w *Elf64Writer) removeSections(removed []bool) error {
+	shTypes := make([]uint32, len(w.Sections))
+	shInfos := make([]uint32, len(w.Sections))
+	datas := make([][]byte, len(w.Sections))
+	for i, sec := range w.Sections {
+		shTypes[i] = sec.Shdr.Sh_type
+		shInfos[i] = sec.Shdr.Sh_info
+		datas[i] = sec.Data
+	}
+	markRemovedSections(removed, shTypes, shInfos, datas)
+
+	// the tables the writer rebuilds must stay
+	for _, idx := range w.rebuiltSections() {
+		if 0 <= idx && idx < len(removed) && removed[idx] {
+			return fmt.Errorf("section %s cannot be removed", w.Sections[idx].Name)
+		}
+	}
+	idxMap := newIdxMap(removed)
+
+	symRemoved := make([]bool, len(w.Symbols))
+	for i, s := range w.Symbols {
+		if i != 0 && isSymInSection(s.Sym.St_shndx) && int(s.Shndx) < len(removed) && removed[s.Shndx] {
+			symRemoved[i] = true
+		}
+	}
+	symMap := newIdxMap(symRemoved)
+
+	sections := []Elf64Section{}
+	for i, sec := range w.Sections {
+		if removed[i] {
+			continue
+		}
+		sh := &sec.Shdr
+		// only relocation and group sections follow a removed section, like objcopy
+		if 0 < sh.Sh_link && int(sh.Sh_link) < len(idxMap) {
+			if idxMap[sh.Sh_link] < 0 {
+				return fmt.Errorf("section %s cannot be removed, %s links to it", w.Sections[sh.Sh_link].Name, sec.Name)
+			}
+			sh.Sh_link = uint32(idxMap[sh.Sh_link])
+		}
+		if hasInfoLink(sh.Sh_type, sh.Sh_flags) && 0 < sh.Sh_info && int(sh.Sh_info) < len(idxMap) {
+			if idxMap[sh.Sh_info] < 0 {
+				return fmt.Errorf("section %s cannot be removed, %s links to it", w.Sections[sh.Sh_info].Name, sec.Name)
+			}
+			sh.Sh_info = uint32(idxMap[sh.Sh_info])
+		}
+		if sh.Sh_type == SHT_GROUP {
+			sec.Data = remapGroup(sec.Data, idxMap)
+		}
+
+		// symbol references of the sections using .symtab
+		if 0 <= w.SymTabIdx && i != w.SymTabIdx && w.Sections[i].Shdr.Sh_link == uint32(w.SymTabIdx) {
+			if isRelocSection(sh.Sh_type) {
+				data, err := w.remapRelocSyms(sec, symMap)
+				if err != nil {
+					return err
+				}
+				sec.Data = data
+			}
+			if sh.Sh_type == SHT_GROUP && int(sh.Sh_info) < len(symMap) {
+				if symMap[sh.Sh_info] < 0 {
+					return fmt.Errorf("%s: signature symbol %s is in a removed section", sec.Name, w.Symbols[sh.Sh_info].Name)
+				}
+				sh.Sh_info = uint32(symMap[sh.Sh_info])
+			}
+		}
+		sections = append(sections, sec)
+	}
+
+	symbols := []Elf64Symbol{}
+	for i, s := range w.Symbols {
+		if symRemoved[i] {
+			continue
+		}
+		if isSymInSection(s.Sym.St_shndx) && int(s.Shndx) < len(idxMap) {
+			s.Shndx = uint32(idxMap[s.Shndx])
+		}
+		symbols = append(symbols, s)
+	}
+
+	w.Sections = sections
+	w.Symbols = symbols
+	if 0 <= w.ShstrIdx {
+		w.ShstrIdx = idxMap[w.ShstrIdx]
+	}
+	if 0 <= w.SymTabIdx {
+		w.SymTabIdx = idxMap[w.SymTabIdx]
+	}
+	return nil
+}
+
+func (w *Elf64Writer) remapRelocSyms(sec Elf64Section, symMap []int) ([]byte, error) {
+	entSize := int(unsafe.Sizeof(Elf64_Rela{}))
+	if sec.Shdr.Sh_type == SHT_REL {
+		entSize = int(unsafe.Sizeof(Elf64_Rel{}))
+	}
+	infoOffset := int(unsafe.Offsetof(Elf64_Rela{}.R_info))
+	data := append([]byte{}, sec.Data...)
+	for offset := 0; offset+entSize <= len(data); offset += entSize {
+		info := binary.LittleEndian.Uint64(data[offset+infoOffset:])
+		symIdx := ELF64_R_SYM(info)
+		if symIdx == 0 || int(symIdx) >= len(symMap) {
+			continue
+		}
+		if symMap[symIdx] < 0 {
+			sym := w.Symbols[symIdx]
+			return nil, fmt.Errorf("%s: relocation refers to symbol %d (%s) of removed section %s", sec.Name, symIdx, sym.Name, w.Sections[sym.Shndx].Name)
+		}
+		info = ELF64_R_INFO(uint32(symMap[symIdx]), ELF64_R_TYPE(info))
+		binary.LittleEndian.PutUint64(data[offset+infoOffset:], info)
+	}
+	return data, nil
+}
+
+// AddSection appends a SHT_PROGBITS section without flags like objcopy --add-section
+// and returns its index, Shdr of the new section can be changed before writing
+func (w *Elf32Writer) AddSection(name string, data []byte) int {
+	sec := Elf32Section{}
+	sec.Name = name
+	sec.Shdr.Sh_type = SHT_PROGBITS
+	sec.Shdr.Sh_addralign = 1
+	sec.Data = append([]byte{}, data...)
+	w.Sections = append(w.Sections, sec)
+	return len(w.Sections) - 1
+}
+
+// RenameSection renames every section named oldName
+func (w *Elf32Writer) RenameSection(oldName string, newName string) error {
+	found := false
+	for i := range w.Sections {
+		if i != 0 && w.Sections[i].Name == oldName {
+			w.Sections[i].Name = newName
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("section %s not found", oldName)
+	}
+	return nil
+}
+
+// RemoveSections is the ELF32 version of Elf64Writer.RemoveSections
+func (w *Elf32Writer) RemoveSections(names ...string) error {
+	removed := make([]bool, len(w.Sections))
+	for _, name := range names {
+		found := false
+		for i, sec := range w.Sections {
+			if i != 0 && sec.Name == name {
+				removed[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("section %s not found", name)
+		}
+	}
+	return w.removeSections(removed)
+}
+
+// rebuiltSections is the ELF32 version of Elf64Writer.rebuiltSections
+func (w *Elf32Writer) rebuiltSections() []int {
+	idxs := []int{w.ShstrIdx, w.SymTabIdx}
+	if 0 <= w.SymTabIdx {
+		idxs = append(idxs, int(w.Sections[w.SymTabIdx].Shdr.Sh_link))
+	}
+	for i, sec := range w.Sections {
+		if sec.Shdr.Sh_type == SHT_SYMTAB_SHNDX && int(sec.Shdr.Sh_link) == w.SymTabIdx {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+func (w *Elf32Writer) removeSections(removed []bool) error {
+	shTypes := make([]uint32, len(w.Sections))
+	shInfos := make([]uint32, len(w.Sections))
+	datas := make([][]byte, len(w.Sections))
+	for i, sec := range w.Sections {
+		shTypes[i] = sec.Shdr.Sh_type
+		shInfos[i] = sec.Shdr.Sh_info
+		datas[i] = sec.Data
+	}
+	markRemovedSections(removed, shTypes, shInfos, datas)
+
+	// the tables the writer rebuilds must stay
+	for _, idx := range w.rebuiltSections() {
+		if 0 <= idx && idx < len(removed) && removed[idx] {
+			return fmt.Errorf("section %s cannot be removed", w.Sections[idx].Name)
+		}
+	}
+	idxMap := newIdxMap(removed)
+
+	symRemoved := make([]bool, len(w.Symbols))
+	for i, s := range w.Symbols {
+		if i != 0 && isSymInSection(s.Sym.St_shndx) && int(s.Shndx) < len(removed) && removed[s.Shndx] {
+			symRemoved[i] = true
+		}
+	}
+	symMap := newIdxMap(symRemoved)
+
+	sections := []Elf32Section{}
+	for i, sec := range w.Sections {
+		if removed[i] {
+			continue
+		}
+		sh := &sec.Shdr
+		// only relocation and group sections follow a removed section, like objcopy
+		if 0 < sh.Sh_link && int(sh.Sh_link) < len(idxMap) {
+			if idxMap[sh.Sh_link] < 0 {
+				return fmt.Errorf("section %s cannot be removed, %s links to it", w.Sections[sh.Sh_link].Name, sec.Name)
+			}
+			sh.Sh_link = uint32(idxMap[sh.Sh_link])
+		}
+		if hasInfoLink(sh.Sh_type, uint64(sh.Sh_flags)) && 0 < sh.Sh_info && int(sh.Sh_info) < len(idxMap) {
+			if idxMap[sh.Sh_info] < 0 {
+				return fmt.Errorf("section %s cannot be removed, %s links to it", w.Sections[sh.Sh_info].Name, sec.Name)
+			}
+			sh.Sh_info = uint32(idxMap[sh.Sh_info])
+		}
+		if sh.Sh_type == SHT_GROUP {
+			sec.Data = remapGroup(sec.Data, idxMap)
+		}
+
+		// symbol references of the sections using .symtab
+		if 0 <= w.SymTabIdx && i != w.SymTabIdx && w.Sections[i].Shdr.Sh_link == uint32(w.SymTabIdx) {
+			if isRelocSection(sh.Sh_type) {
+				data, err := w.remapRelocSyms(sec, symMap)
+				if err != nil {
+					return err
+				}
+				sec.Data = data
+			}
+			if sh.Sh_type == SHT_GROUP && int(sh.Sh_info) < len(symMap) {
+				if symMap[sh.Sh_info] < 0 {
+					return fmt.Errorf("%s: signature symbol %s is in a removed section", sec.Name, w.Symbols[sh.Sh_info].Name)
+				}
+				sh.Sh_info = uint32(symMap[sh.Sh_info])
+			}
+		}
+		sections = append(sections, sec)
+	}
+
+	symbols := []Elf32Symbol{}
+	for i, s := range w.Symbols {
+		if symRemoved[i] {
+			continue
+		}
+		if isSymInSection(s.Sym.St_shndx) && int(s.Shndx) < len(idxMap) {
+			s.Shndx = uint32(idxMap[s.Shndx])
+		}
+		symbols = append(symbols, s)
+	}
+
+	w.Sections = sections
+	w.Symbols = symbols
+	if 0 <= w.ShstrIdx {
+		w.ShstrIdx = idxMap[w.ShstrIdx]
+	}
+	if 0 <= w.SymTabIdx {
+		w.SymTabIdx = idxMap[w.SymTabIdx]
+	}
+	return nil
+}
+
+func (w *Elf32Writer) remapRelocSyms(sec Elf32Section, symMap []int) ([]byte, error) {
+	entSize := int(unsafe.Sizeof(Elf32_Rela{}))
+	if sec.Shdr.Sh_type == SHT_REL {
+		entSize = int(unsafe.Sizeof(Elf32_Rel{}))
+	}
+	infoOffset := int(unsafe.Offsetof(Elf32_Rela{}.R_info))
+	data := append([]byte{}, sec.Data...)
+	for offset := 0; offset+entSize <= len(data); offset += entSize {
+		info := binary.LittleEndian.Uint32(data[offset+infoOffset:])
+		symIdx := ELF32_R_SYM(info)
+		if symIdx == 0 || int(symIdx) >= len(symMap) {
+			continue
+		}
+		if symMap[symIdx] < 0 {
+			sym := w.Symbols[symIdx]
+			return nil, fmt.Errorf("%s: relocation refers to symbol %d (%s) of removed section %s", sec.Name, symIdx, sym.Name, w.Sections[sym.Shndx].Name)
+		}
+		info = ELF32_R_INFO(uint32(symMap[symIdx]), ELF32_R_TYPE(info))
+		binary.LittleEndian.PutUint32(data[offset+infoOffset:], info)
+	}
+	return data, nil
+}
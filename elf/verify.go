@@ -0,0 +1,582 @@
+package elf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unsafe"
+)
+
+type fileRange struct {
+	Name  string
+	Start uint64
+	End   uint64
+}
+
+// Verify checks the structural invariants the linker relies on and returns every violation found.
+func (elfObj *Elf64Object) Verify() []error {
+	errs := []error{}
+	binSize := uint64(len(elfObj.Bin))
+	ehdr := elfObj.Elf64Ehdr
+
+	// string offsets of section names
+	for i, sh := range elfObj.Shdrs {
+		if !isValidStrOffset(elfObj.secNameStr.Bytes(), uint64(sh.Sh_name)) {
+			errs = append(errs, fmt.Errorf("section [%d]: sh_name 0x%x is out of .shstrtab", i, sh.Sh_name))
+		}
+	}
+
+	// file ranges must stay in the file and must not overlap
+	ranges := []fileRange{}
+	ranges = append(ranges, fileRange{"ELF header", 0, uint64(ehdr.E_ehsize)})
+	if 0 < len(elfObj.Shdrs) {
+		shEnd := ehdr.E_shoff + uint64(len(elfObj.Shdrs))*uint64(ehdr.E_shentsize)
+		ranges = append(ranges, fileRange{"section header table", ehdr.E_shoff, shEnd})
+	}
+	if 0 < ehdr.E_phnum {
+		phEnd := ehdr.E_phoff + uint64(ehdr.E_phnum)*uint64(ehdr.E_phentsize)
+		ranges = append(ranges, fileRange{"program header table", ehdr.E_phoff, phEnd})
+	}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS || sh.Sh_size == 0 {
+			continue
+		}
+		name := fmt.Sprintf("section [%d] %s", i, elfObj.safeSectionName(i))
+		ranges = append(ranges, fileRange{name, sh.Sh_offset, sh.Sh_offset + sh.Sh_size})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	for i, r := range ranges {
+		if binSize < r.End || r.End < r.Start {
+			errs = append(errs, fmt.Errorf("%s [0x%x, 0x%x) exceeds file size 0x%x", r.Name, r.Start, r.End, binSize))
+		}
+		if 0 < i && r.Start < ranges[i-1].End {
+			errs = append(errs, fmt.Errorf("%s overlaps %s at 0x%x", r.Name, ranges[i-1].Name, r.Start))
+		}
+	}
+
+	for symTabIdx, symTabSh := range elfObj.Shdrs {
+		if symTabSh.Sh_type != SHT_SYMTAB {
+			continue
+		}
+		errs = append(errs, elfObj.verifySymTbl(symTabIdx)...)
+	}
+	return errs
+}
+
+func (elfObj *Elf64Object) verifySymTbl(symTabIdx int) []error {
+	errs := []error{}
+	symTabSh := elfObj.Shdrs[symTabIdx]
+	symSize := uint64(unsafe.Sizeof(Elf64_Sym{}))
+	if symTabSh.Sh_size%symSize != 0 {
+		errs = append(errs, fmt.Errorf(".symtab size 0x%x is not a multiple of %d", symTabSh.Sh_size, symSize))
+	}
+	if int(symTabSh.Sh_link) >= len(elfObj.Shdrs) {
+		errs = append(errs, fmt.Errorf(".symtab sh_link %d is out of range", symTabSh.Sh_link))
+		return errs
+	}
+	strSh := elfObj.Shdrs[symTabSh.Sh_link]
+	var strtbl []byte
+	if strSh.Sh_offset+strSh.Sh_size <= uint64(len(elfObj.Bin)) {
+		strtbl = elfObj.Bin[strSh.Sh_offset : strSh.Sh_offset+strSh.Sh_size]
+	}
+
+	// locals precede globals, sh_info is the index of the first non-local symbol
+	firstGlobalIdx := len(elfObj.SymTbl)
+	for i, sym := range elfObj.SymTbl {
+		bind := sym.St_info >> 4
+		if bind != STB_LOCAL && firstGlobalIdx == len(elfObj.SymTbl) {
+			firstGlobalIdx = i
+		}
+		if bind == STB_LOCAL && firstGlobalIdx < i {
+			errs = append(errs, fmt.Errorf("symbol [%d] is local but follows global symbol [%d]", i, firstGlobalIdx))
+		}
+		if !isValidStrOffset(strtbl, uint64(sym.St_name)) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_name 0x%x is out of .strtab", i, sym.St_name))
+		}
+		if sym.St_shndx == SHN_XINDEX && i >= len(elfObj.SymShndxTbl) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_shndx is SHN_XINDEX but there is no SHT_SYMTAB_SHNDX entry", i))
+		} else if elfObj.IsSymInSection(i) && int(elfObj.GetSymShndx(i)) >= len(elfObj.Shdrs) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_shndx %d is out of range", i, elfObj.GetSymShndx(i)))
+		}
+	}
+	if 0 < len(elfObj.SymShndxTbl) && len(elfObj.SymShndxTbl) != len(elfObj.SymTbl) {
+		errs = append(errs, fmt.Errorf("SHT_SYMTAB_SHNDX has %d entries, expected %d", len(elfObj.SymShndxTbl), len(elfObj.SymTbl)))
+	}
+	if int(symTabSh.Sh_info) != firstGlobalIdx {
+		errs = append(errs, fmt.Errorf(".symtab sh_info is %d, expected %d", symTabSh.Sh_info, firstGlobalIdx))
+	}
+
+	// every relocation must refer to an existing symbol
+	for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+		relSh := elfObj.Shdrs[relIdx]
+		if uint64(len(elfObj.Bin)) < relSh.Sh_offset+relSh.Sh_size {
+			continue
+		}
+		for i, rela := range elfObj.GetRelocations(relIdx) {
+			symIdx := ELF64_R_SYM(rela.R_info)
+			if int(symIdx) >= len(elfObj.SymTbl) {
+				errs = append(errs, fmt.Errorf("%s [%d]: symbol index %d is out of range", elfObj.safeSectionName(relIdx), i, symIdx))
+			}
+		}
+	}
+	return errs
+}
+
+type symKey struct {
+	Name  string
+	Type  uint8
+	Shndx uint32
+	Value uint64
+	Size  uint64
+}
+
+// CompareSymbols checks that out has the same symbols as in, allowing only the
+// symbols in exposed to change from STB_LOCAL to STB_GLOBAL. Symbol order may differ.
+func CompareSymbols(in *Elf64Object, out *Elf64Object, exposed map[string]bool) []error {
+	errs := []error{}
+	if len(in.SymTbl) != len(out.SymTbl) {
+		errs = append(errs, fmt.Errorf("symbol count changed from %d to %d", len(in.SymTbl), len(out.SymTbl)))
+	}
+
+	outSyms := map[symKey][]Elf64_Sym{}
+	for i, sym := range out.SymTbl {
+		key := out.getSymKey(i)
+		outSyms[key] = append(outSyms[key], sym)
+	}
+
+	for i, sym := range in.SymTbl {
+		key := in.getSymKey(i)
+		candidates := outSyms[key]
+		if len(candidates) == 0 {
+			errs = append(errs, fmt.Errorf("symbol [%d] %s is missing or changed", i, key.Name))
+			continue
+		}
+
+		// prefer an unchanged entry when several symbols share the same key
+		matched := 0
+		for j, cand := range candidates {
+			if cand.St_info == sym.St_info && cand.St_other == sym.St_other {
+				matched = j
+				break
+			}
+		}
+		outSym := candidates[matched]
+		outSyms[key] = append(candidates[:matched], candidates[matched+1:]...)
+
+		if outSym.St_info == sym.St_info && outSym.St_other == sym.St_other {
+			continue
+		}
+		isExposed := exposed[key.Name] &&
+			sym.St_info>>4 == STB_LOCAL && outSym.St_info>>4 == STB_GLOBAL &&
+			outSym.St_other == sym.St_other
+		if !isExposed {
+			errs = append(errs, fmt.Errorf("symbol [%d] %s changed st_info 0x%02x -> 0x%02x, st_other 0x%02x -> 0x%02x",
+				i, key.Name, sym.St_info, outSym.St_info, sym.St_other, outSym.St_other))
+		}
+	}
+
+	for key, syms := range outSyms {
+		for range syms {
+			errs = append(errs, fmt.Errorf("unexpected symbol %s", key.Name))
+		}
+	}
+	return errs
+}
+
+type relocKey struct {
+	Offset uint64
+	Type   uint32
+	Sym    symKey
+	Addend int64
+}
+
+// CompareRelocations checks that every relocation of in resolves to the same
+// symbol in out. Exposing reorders the symbol table and remaps the symbol
+// indexes of the relocations, a wrong index makes the relocation refer to another symbol.
+// Relocation sections are matched by name and occurrence.
+func CompareRelocations(in *Elf64Object, out *Elf64Object) []error {
+	errs := []error{}
+	outRelIdxs := out.relocSectionsByName()
+	inRelIdxs := in.relocSectionsByName()
+	for inIdx := range in.Shdrs {
+		key, isReloc := in.relocSectionKey(inRelIdxs, inIdx)
+		if !isReloc {
+			continue
+		}
+		name := key.Name
+		outIdx, exist := outRelIdxs[key]
+		if !exist {
+			errs = append(errs, fmt.Errorf("relocation section %s is missing", name))
+			continue
+		}
+		inRelas := in.GetRelocations(inIdx)
+		outRelas := out.GetRelocations(outIdx)
+		if len(inRelas) != len(outRelas) {
+			errs = append(errs, fmt.Errorf("%s: relocation count changed from %d to %d", name, len(inRelas), len(outRelas)))
+			continue
+		}
+		for i := range inRelas {
+			inKey := in.getRelocKey(inRelas[i])
+			outKey := out.getRelocKey(outRelas[i])
+			if inKey != outKey {
+				errs = append(errs, fmt.Errorf("%s [%d]: relocation at 0x%x type %d of %s%+d changed to 0x%x type %d of %s%+d",
+					name, i, inKey.Offset, inKey.Type, inKey.Sym.describe(), inKey.Addend,
+					outKey.Offset, outKey.Type, outKey.Sym.describe(), outKey.Addend))
+			}
+		}
+	}
+	return errs
+}
+
+type sectionKey struct {
+	Name       string
+	Occurrence int
+}
+
+// relocSectionsByName returns the indexes of the relocation sections, by
+// name and occurrence since relocatable objects may repeat a name
+func (elfObj *Elf64Object) relocSectionsByName() map[sectionKey]int {
+	idxs := map[sectionKey]int{}
+	seen := map[string]int{}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+			continue
+		}
+		if uint64(len(elfObj.Bin)) < sh.Sh_offset+sh.Sh_size {
+			continue
+		}
+		name := elfObj.safeSectionName(i)
+		idxs[sectionKey{name, seen[name]}] = i
+		seen[name]++
+	}
+	return idxs
+}
+
+// relocSectionKey returns the key of the section at shIdx in idxs
+func (elfObj *Elf64Object) relocSectionKey(idxs map[sectionKey]int, shIdx int) (sectionKey, bool) {
+	name := elfObj.safeSectionName(shIdx)
+	for occurrence := 0; ; occurrence++ {
+		idx, exist := idxs[sectionKey{name, occurrence}]
+		if !exist {
+			return sectionKey{}, false
+		}
+		if idx == shIdx {
+			return sectionKey{name, occurrence}, true
+		}
+	}
+}
+
+func (elfObj *Elf64Object) getRelocKey(rela Elf64_Rela) relocKey {
+	key := relocKey{}
+	key.Offset = rela.R_offset
+	key.Type = ELF64_R_TYPE(rela.R_info)
+	key.Addend = int64(rela.R_addend)
+	symIdx := int(ELF64_R_SYM(rela.R_info))
+	if symIdx < len(elfObj.SymTbl) {
+		key.Sym = elfObj.getSymKey(symIdx)
+	} else {
+		key.Sym.Name = fmt.Sprintf("<symbol index %d>", symIdx)
+	}
+	return key
+}
+
+// describe names the symbol of key, section symbols have no name
+func (key symKey) describe() string {
+	if key.Name == "" {
+		return fmt.Sprintf("the symbol of type %d in section %d at 0x%x", key.Type, key.Shndx, key.Value)
+	}
+	return key.Name
+}
+
+func (elfObj *Elf64Object) getSymKey(symIdx int) symKey {
+	sym := elfObj.SymTbl[symIdx]
+	key := symKey{}
+	if isValidStrOffset(elfObj.strtbl.Bytes(), uint64(sym.St_name)) {
+		key.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+	}
+	key.Type = sym.St_info & 0x0F
+	key.Shndx = elfObj.GetSymShndx(symIdx)
+	key.Value = sym.St_value
+	key.Size = sym.St_size
+	return key
+}
+
+func (elfObj *Elf64Object) safeSectionName(shIdx int) string {
+	sh := elfObj.Shdrs[shIdx]
+	if !isValidStrOffset(elfObj.secNameStr.Bytes(), uint64(sh.Sh_name)) {
+		return ""
+	}
+	return elfObj.getSectionName(sh.Sh_name)
+}
+
+func isValidStrOffset(strtbl []byte, offset uint64) bool {
+	if uint64(len(strtbl)) <= offset {
+		return false
+	}
+	return bytes.IndexByte(strtbl[offset:], 0) >= 0
+}
+
+// Verify is the ELF32 version of Verify
+func (elfObj *Elf32Object) Verify() []error {
+	errs := []error{}
+	binSize := uint64(len(elfObj.Bin))
+	ehdr := elfObj.Elf32Ehdr
+
+	// string offsets of section names
+	for i, sh := range elfObj.Shdrs {
+		if !isValidStrOffset(elfObj.secNameStr.Bytes(), uint64(sh.Sh_name)) {
+			errs = append(errs, fmt.Errorf("section [%d]: sh_name 0x%x is out of .shstrtab", i, sh.Sh_name))
+		}
+	}
+
+	// file ranges must stay in the file and must not overlap
+	ranges := []fileRange{}
+	ranges = append(ranges, fileRange{"ELF header", 0, uint64(ehdr.E_ehsize)})
+	if 0 < len(elfObj.Shdrs) {
+		shEnd := uint64(ehdr.E_shoff) + uint64(len(elfObj.Shdrs))*uint64(ehdr.E_shentsize)
+		ranges = append(ranges, fileRange{"section header table", uint64(ehdr.E_shoff), shEnd})
+	}
+	if 0 < ehdr.E_phnum {
+		phEnd := uint64(ehdr.E_phoff) + uint64(ehdr.E_phnum)*uint64(ehdr.E_phentsize)
+		ranges = append(ranges, fileRange{"program header table", uint64(ehdr.E_phoff), phEnd})
+	}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_NULL || sh.Sh_type == SHT_NOBITS || sh.Sh_size == 0 {
+			continue
+		}
+		name := fmt.Sprintf("section [%d] %s", i, elfObj.safeSectionName(i))
+		ranges = append(ranges, fileRange{name, uint64(sh.Sh_offset), uint64(sh.Sh_offset) + uint64(sh.Sh_size)})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	for i, r := range ranges {
+		if binSize < r.End || r.End < r.Start {
+			errs = append(errs, fmt.Errorf("%s [0x%x, 0x%x) exceeds file size 0x%x", r.Name, r.Start, r.End, binSize))
+		}
+		if 0 < i && r.Start < ranges[i-1].End {
+			errs = append(errs, fmt.Errorf("%s overlaps %s at 0x%x", r.Name, ranges[i-1].Name, r.Start))
+		}
+	}
+
+	for symTabIdx, symTabSh := range elfObj.Shdrs {
+		if symTabSh.Sh_type != SHT_SYMTAB {
+			continue
+		}
+		errs = append(errs, elfObj.verifySymTbl(symTabIdx)...)
+	}
+	return errs
+}
+
+func (elfObj *Elf32Object) verifySymTbl(symTabIdx int) []error {
+	errs := []error{}
+	symTabSh := elfObj.Shdrs[symTabIdx]
+	symSize := uint32(unsafe.Sizeof(Elf32_Sym{}))
+	if symTabSh.Sh_size%symSize != 0 {
+		errs = append(errs, fmt.Errorf(".symtab size 0x%x is not a multiple of %d", symTabSh.Sh_size, symSize))
+	}
+	if int(symTabSh.Sh_link) >= len(elfObj.Shdrs) {
+		errs = append(errs, fmt.Errorf(".symtab sh_link %d is out of range", symTabSh.Sh_link))
+		return errs
+	}
+	strSh := elfObj.Shdrs[symTabSh.Sh_link]
+	var strtbl []byte
+	if uint64(strSh.Sh_offset)+uint64(strSh.Sh_size) <= uint64(len(elfObj.Bin)) {
+		strtbl = elfObj.Bin[strSh.Sh_offset : strSh.Sh_offset+strSh.Sh_size]
+	}
+
+	// locals precede globals, sh_info is the index of the first non-local symbol
+	firstGlobalIdx := len(elfObj.SymTbl)
+	for i, sym := range elfObj.SymTbl {
+		bind := sym.St_info >> 4
+		if bind != STB_LOCAL && firstGlobalIdx == len(elfObj.SymTbl) {
+			firstGlobalIdx = i
+		}
+		if bind == STB_LOCAL && firstGlobalIdx < i {
+			errs = append(errs, fmt.Errorf("symbol [%d] is local but follows global symbol [%d]", i, firstGlobalIdx))
+		}
+		if !isValidStrOffset(strtbl, uint64(sym.St_name)) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_name 0x%x is out of .strtab", i, sym.St_name))
+		}
+		if sym.St_shndx == SHN_XINDEX && i >= len(elfObj.SymShndxTbl) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_shndx is SHN_XINDEX but there is no SHT_SYMTAB_SHNDX entry", i))
+		} else if elfObj.IsSymInSection(i) && int(elfObj.GetSymShndx(i)) >= len(elfObj.Shdrs) {
+			errs = append(errs, fmt.Errorf("symbol [%d]: st_shndx %d is out of range", i, elfObj.GetSymShndx(i)))
+		}
+	}
+	if 0 < len(elfObj.SymShndxTbl) && len(elfObj.SymShndxTbl) != len(elfObj.SymTbl) {
+		errs = append(errs, fmt.Errorf("SHT_SYMTAB_SHNDX has %d entries, expected %d", len(elfObj.SymShndxTbl), len(elfObj.SymTbl)))
+	}
+	if int(symTabSh.Sh_info) != firstGlobalIdx {
+		errs = append(errs, fmt.Errorf(".symtab sh_info is %d, expected %d", symTabSh.Sh_info, firstGlobalIdx))
+	}
+
+	// every relocation must refer to an existing symbol
+	for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+		relSh := elfObj.Shdrs[relIdx]
+		if uint64(len(elfObj.Bin)) < uint64(relSh.Sh_offset)+uint64(relSh.Sh_size) {
+			continue
+		}
+		for i, rela := range elfObj.GetRelocations(relIdx) {
+			symIdx := ELF32_R_SYM(rela.R_info)
+			if int(symIdx) >= len(elfObj.SymTbl) {
+				errs = append(errs, fmt.Errorf("%s [%d]: symbol index %d is out of range", elfObj.safeSectionName(relIdx), i, symIdx))
+			}
+		}
+	}
+	return errs
+}
+
+// CompareSymbols32 is the ELF32 version of CompareSymbols
+func CompareSymbols32(in *Elf32Object, out *Elf32Object, exposed map[string]bool) []error {
+	errs := []error{}
+	if len(in.SymTbl) != len(out.SymTbl) {
+		errs = append(errs, fmt.Errorf("symbol count changed from %d to %d", len(in.SymTbl), len(out.SymTbl)))
+	}
+
+	outSyms := map[symKey][]Elf32_Sym{}
+	for i, sym := range out.SymTbl {
+		key := out.getSymKey(i)
+		outSyms[key] = append(outSyms[key], sym)
+	}
+
+	for i, sym := range in.SymTbl {
+		key := in.getSymKey(i)
+		candidates := outSyms[key]
+		if len(candidates) == 0 {
+			errs = append(errs, fmt.Errorf("symbol [%d] %s is missing or changed", i, key.Name))
+			continue
+		}
+
+		// prefer an unchanged entry when several symbols share the same key
+		matched := 0
+		for j, cand := range candidates {
+			if cand.St_info == sym.St_info && cand.St_other == sym.St_other {
+				matched = j
+				break
+			}
+		}
+		outSym := candidates[matched]
+		outSyms[key] = append(candidates[:matched], candidates[matched+1:]...)
+
+		if outSym.St_info == sym.St_info && outSym.St_other == sym.St_other {
+			continue
+		}
+		isExposed := exposed[key.Name] &&
+			sym.St_info>>4 == STB_LOCAL && outSym.St_info>>4 == STB_GLOBAL &&
+			outSym.St_other == sym.St_other
+		if !isExposed {
+			errs = append(errs, fmt.Errorf("symbol [%d] %s changed st_info 0x%02x -> 0x%02x, st_other 0x%02x -> 0x%02x",
+				i, key.Name, sym.St_info, outSym.St_info, sym.St_other, outSym.St_other))
+		}
+	}
+
+	for key, syms := range outSyms {
+		for range syms {
+			errs = append(errs, fmt.Errorf("unexpected symbol %s", key.Name))
+		}
+	}
+	return errs
+}
+
+// CompareRelocations32 is the ELF32 version of CompareRelocations
+func CompareRelocations32(in *Elf32Object, out *Elf32Object) []error {
+	errs := []error{}
+	outRelIdxs := out.relocSectionsByName()
+	inRelIdxs := in.relocSectionsByName()
+	for inIdx := range in.Shdrs {
+		key, isReloc := in.relocSectionKey(inRelIdxs, inIdx)
+		if !isReloc {
+			continue
+		}
+		name := key.Name
+		outIdx, exist := outRelIdxs[key]
+		if !exist {
+			errs = append(errs, fmt.Errorf("relocation section %s is missing", name))
+			continue
+		}
+		inRelas := in.GetRelocations(inIdx)
+		outRelas := out.GetRelocations(outIdx)
+		if len(inRelas) != len(outRelas) {
+			errs = append(errs, fmt.Errorf("%s: relocation count changed from %d to %d", name, len(inRelas), len(outRelas)))
+			continue
+		}
+		for i := range inRelas {
+			inKey := in.getRelocKey(inRelas[i])
+			outKey := out.getRelocKey(outRelas[i])
+			if inKey != outKey {
+				errs = append(errs, fmt.Errorf("%s [%d]: relocation at 0x%x type %d of %s%+d changed to 0x%x type %d of %s%+d",
+					name, i, inKey.Offset, inKey.Type, inKey.Sym.describe(), inKey.Addend,
+					outKey.Offset, outKey.Type, outKey.Sym.describe(), outKey.Addend))
+			}
+		}
+	}
+	return errs
+}
+
+func (elfObj *Elf32Object) relocSectionsByName() map[sectionKey]int {
+	idxs := map[sectionKey]int{}
+	seen := map[string]int{}
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type != SHT_REL && sh.Sh_type != SHT_RELA {
+			continue
+		}
+		if uint64(len(elfObj.Bin)) < uint64(sh.Sh_offset)+uint64(sh.Sh_size) {
+			continue
+		}
+		name := elfObj.safeSectionName(i)
+		idxs[sectionKey{name, seen[name]}] = i
+		seen[name]++
+	}
+	return idxs
+}
+
+func (elfObj *Elf32Object) relocSectionKey(idxs map[sectionKey]int, shIdx int) (sectionKey, bool) {
+	name := elfObj.safeSectionName(shIdx)
+	for occurrence := 0; ; occurrence++ {
+		idx, exist := idxs[sectionKey{name, occurrence}]
+		if !exist {
+			return sectionKey{}, false
+		}
+		if idx == shIdx {
+			return sectionKey{name, occurrence}, true
+		}
+	}
+}
+
+func (elfObj *Elf32Object) getRelocKey(rela Elf32_Rela) relocKey {
+	key := relocKey{}
+	key.Offset = uint64(rela.R_offset)
+	key.Type = ELF32_R_TYPE(rela.R_info)
+	key.Addend = int64(rela.R_addend)
+	symIdx := int(ELF32_R_SYM(rela.R_info))
+	if symIdx < len(elfObj.SymTbl) {
+		key.Sym = elfObj.getSymKey(symIdx)
+	} else {
+		key.Sym.Name = fmt.Sprintf("<symbol index %d>", symIdx)
+	}
+	return key
+}
+
+func (elfObj *Elf32Object) getSymKey(symIdx int) symKey {
+	sym := elfObj.SymTbl[symIdx]
+	key := symKey{}
+	if isValidStrOffset(elfObj.strtbl.Bytes(), uint64(sym.St_name)) {
+		key.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+	}
+	key.Type = sym.St_info & 0x0F
+	key.Shndx = elfObj.GetSymShndx(symIdx)
+	key.Value = uint64(sym.St_value)
+	key.Size = uint64(sym.St_size)
+	return key
+}
+
+func (elfObj *Elf32Object) safeSectionName(shIdx int) string {
+	sh := elfObj.Shdrs[shIdx]
+	if !isValidStrOffset(elfObj.secNameStr.Bytes(), uint64(sh.Sh_name)) {
+		return ""
+	}
+	return elfObj.getSectionName(sh.Sh_name)
+}
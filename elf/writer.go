@@ -0,0 +1,710 @@
+package elf
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	binutil "sym-exposer/binutil"
+)
+
+// sections aligned beyond this are rejected rather than padding the file with zeroes
+const MAX_SECTION_ALIGN = 1 << 24
+
+// checkAlign rejects sh_addralign values that are not 0, 1 or a power of two
+func checkAlign(shIdx int, name string, align uint64) error {
+	if align&(align-1) != 0 || MAX_SECTION_ALIGN < align {
+		return fmt.Errorf("section [%d] %s: unsupported sh_addralign 0x%x", shIdx, name, align)
+	}
+	return nil
+}
+
+func alignUp(offset uint64, align uint64) uint64 {
+	if align <= 1 {
+		return offset
+	}
+	return (offset + align - 1) / align * align
+}
+
+// isSymInSection reports whether the writer takes the section index of the symbol from Shndx
+func isSymInSection(st_shndx uint16) bool {
+	return st_shndx == SHN_XINDEX || !isSpecialShndx(st_shndx)
+}
+
+// Elf64Section is a section of Elf64Writer. Sh_name, Sh_offset and Sh_size
+// (except for SHT_NOBITS) are computed from Name and Data when writing.
+// Data shares memory with the parsed object, which may be a read-only
+// mapping, so it is replaced instead of written to.
+type Elf64Section struct {
+	Name string
+	Shdr Elf64_Shdr
+	Data []byte
+}
+
+// Elf64Symbol is a .symtab entry of Elf64Writer. St_name is computed from Name,
+// Shndx replaces St_shndx unless St_shndx is a special index such as SHN_UNDEF or SHN_ABS.
+type Elf64Symbol struct {
+	Name  string
+	Sym   Elf64_Sym
+	Shndx uint32
+}
+
+// Elf64Writer lays out an ELF64 object again from its sections, so that
+// sections can change their size. .shstrtab, .symtab, its string table and
+// SHT_SYMTAB_SHNDX section are rebuilt from the section names and Symbols.
+// When the object has program headers, SHF_ALLOC sections keep their file
+// offset and size and only the other sections are moved.
+type Elf64Writer struct {
+	Ehdr      Elf64Ehdr
+	Phdrs     []Elf64Phdr
+	Sections  []Elf64Section
+	Symbols   []Elf64Symbol
+	ShstrIdx  int // -1 when there is no section name table
+	SymTabIdx int // -1 when there is no .symtab
+	orig      []byte
+}
+
+func NewElf64Writer(elfObj *Elf64Object) *Elf64Writer {
+	w := Elf64Writer{}
+	w.Ehdr = elfObj.Elf64Ehdr
+	w.Ehdr.E_ident = append([]byte{}, elfObj.Elf64Ehdr.E_ident...)
+	w.Phdrs = append([]Elf64Phdr{}, elfObj.Phdrs...)
+	w.Sections = []Elf64Section{}
+	for i, sh := range elfObj.Shdrs {
+		sec := Elf64Section{}
+		sec.Name = elfObj.GetSectionName(i)
+		sec.Shdr = sh
+		if sh.Sh_type != SHT_NULL && sh.Sh_type != SHT_NOBITS {
+			end := sh.Sh_offset + sh.Sh_size
+			sec.Data = elfObj.Bin[sh.Sh_offset:end:end]
+		}
+		w.Sections = append(w.Sections, sec)
+	}
+
+	w.ShstrIdx = -1
+	if 0 < len(elfObj.Shdrs) {
+		w.ShstrIdx = int(elfObj.GetShstrndx())
+	}
+	w.SymTabIdx = -1
+	w.Symbols = []Elf64Symbol{}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if exist && elfObj.Shdrs[symTabIdx].Sh_type == SHT_SYMTAB {
+		w.SymTabIdx = symTabIdx
+		for i, sym := range elfObj.SymTbl {
+			s := Elf64Symbol{}
+			s.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+			s.Sym = sym
+			s.Shndx = elfObj.GetSymShndx(i)
+			w.Symbols = append(w.Symbols, s)
+		}
+	}
+	w.orig = elfObj.Bin
+	return &w
+}
+
+// GetSectionIdx returns the index of the first section named name, or -1
+func (w *Elf64Writer) GetSectionIdx(name string) int {
+	for i, sec := range w.Sections {
+		if sec.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *Elf64Writer) buildSymTab(shdrs []Elf64_Shdr, datas [][]byte, shstrTab *binutil.StrTabBuilder) error {
+	symTabSec := w.Sections[w.SymTabIdx]
+	strIdx := int(symTabSec.Shdr.Sh_link)
+	if strIdx <= 0 || len(w.Sections) <= strIdx || w.Sections[strIdx].Shdr.Sh_type != SHT_STRTAB {
+		return fmt.Errorf("%s: sh_link %d is not a string table", symTabSec.Name, strIdx)
+	}
+	strTab := binutil.NewStrTabBuilder()
+	if strIdx == w.ShstrIdx {
+		strTab = shstrTab
+	}
+	shndxIdx := -1
+	for i, sec := range w.Sections {
+		if sec.Shdr.Sh_type == SHT_SYMTAB_SHNDX && int(sec.Shdr.Sh_link) == w.SymTabIdx {
+			shndxIdx = i
+			break
+		}
+	}
+
+	symSize := uint64(unsafe.Sizeof(Elf64_Sym{}))
+	symBin := binutil.NewLeWriter()
+	shndxBin := binutil.NewLeWriter()
+	shndxBin.Pad(uint64(len(w.Symbols)) * 4)
+	firstGlobal := len(w.Symbols)
+	for i, s := range w.Symbols {
+		sym := s.Sym
+		sym.St_name = strTab.Add(s.Name)
+		if isSymInSection(sym.St_shndx) {
+			if s.Shndx < SHN_LORESERVE {
+				sym.St_shndx = Elf64_Section(s.Shndx)
+			} else if shndxIdx < 0 {
+				return fmt.Errorf("symbol [%d] %s: section index %d needs a SHT_SYMTAB_SHNDX section", i, s.Name, s.Shndx)
+			} else {
+				sym.St_shndx = SHN_XINDEX
+				shndxBin.Seek(uint64(i) * 4)
+				shndxBin.PutU32(s.Shndx)
+			}
+		}
+		// locals must precede globals
+		if sym.St_info>>4 != STB_LOCAL {
+			if firstGlobal == len(w.Symbols) {
+				firstGlobal = i
+			}
+		} else if firstGlobal < i {
+			return fmt.Errorf("symbol [%d] %s: local symbol follows a global symbol", i, s.Name)
+		}
+		PutElf64Sym(symBin, sym)
+	}
+
+	datas[w.SymTabIdx] = symBin.Bytes()
+	shdrs[w.SymTabIdx].Sh_info = uint32(firstGlobal)
+	shdrs[w.SymTabIdx].Sh_entsize = symSize
+	if shndxIdx >= 0 {
+		datas[shndxIdx] = shndxBin.Bytes()
+	}
+	if strIdx != w.ShstrIdx {
+		datas[strIdx] = strTab.Bytes()
+	}
+	return nil
+}
+
+// Bytes serializes the object, the writer itself is not modified
+func (w *Elf64Writer) Bytes() ([]byte, error) {
+	shnum := len(w.Sections)
+	shdrs := make([]Elf64_Shdr, shnum)
+	datas := make([][]byte, shnum)
+	for i, sec := range w.Sections {
+		shdrs[i] = sec.Shdr
+		datas[i] = sec.Data
+	}
+	if 0 < shnum && shdrs[0].Sh_type != SHT_NULL {
+		return nil, errors.New("section [0] must be SHT_NULL")
+	}
+	if w.ShstrIdx >= shnum || (0 < shnum && w.ShstrIdx < 0) {
+		return nil, fmt.Errorf("section name table index %d is out of range (%d sections)", w.ShstrIdx, shnum)
+	}
+	if w.SymTabIdx >= shnum {
+		return nil, fmt.Errorf(".symtab index %d is out of range (%d sections)", w.SymTabIdx, shnum)
+	}
+
+	shstrTab := binutil.NewStrTabBuilder()
+	for i, sec := range w.Sections {
+		shdrs[i].Sh_name = shstrTab.Add(sec.Name)
+	}
+	if 0 <= w.SymTabIdx {
+		err := w.buildSymTab(shdrs, datas, shstrTab)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if 0 <= w.ShstrIdx {
+		datas[w.ShstrIdx] = shstrTab.Bytes()
+	}
+
+	// sections of a loaded image keep their place, the rest goes behind it
+	ehdrSize := uint64(ELF64_EHDR_SIZE)
+	phdrSize := uint64(unsafe.Sizeof(Elf64Phdr{}))
+	shdrSize := uint64(unsafe.Sizeof(Elf64_Shdr{}))
+	end := ehdrSize
+	fixed := make([]bool, shnum)
+	if 0 < len(w.Phdrs) {
+		end = max(end, w.Ehdr.E_phoff+uint64(len(w.Phdrs))*phdrSize)
+		for i, phdr := range w.Phdrs {
+			if uint64(len(w.orig)) < phdr.P_offset || uint64(len(w.orig))-phdr.P_offset < phdr.P_filesz {
+				return nil, fmt.Errorf("segment [%d] is not in the file", i)
+			}
+			end = max(end, phdr.P_offset+phdr.P_filesz)
+		}
+		for i, sh := range shdrs {
+			if sh.Sh_type == SHT_NULL || sh.Sh_flags&SHF_ALLOC == 0 {
+				continue
+			}
+			fixed[i] = true
+			if sh.Sh_type == SHT_NOBITS {
+				continue
+			}
+			if uint64(len(datas[i])) != sh.Sh_size {
+				return nil, fmt.Errorf("section [%d] %s: SHF_ALLOC section cannot change its size", i, w.Sections[i].Name)
+			}
+			end = max(end, sh.Sh_offset+sh.Sh_size)
+		}
+	}
+
+	offset := end
+	for i, sh := range shdrs {
+		if i == 0 || fixed[i] {
+			continue
+		}
+		err := checkAlign(i, w.Sections[i].Name, sh.Sh_addralign)
+		if err != nil {
+			return nil, err
+		}
+		offset = alignUp(offset, sh.Sh_addralign)
+		shdrs[i].Sh_offset = offset
+		if sh.Sh_type != SHT_NOBITS {
+			shdrs[i].Sh_size = uint64(len(datas[i]))
+			offset += shdrs[i].Sh_size
+		}
+	}
+
+	ehdr := w.Ehdr
+	ehdr.E_ehsize = Elf64_Half(ehdrSize)
+	ehdr.E_phnum = Elf64_Half(len(w.Phdrs))
+	if 0 < len(w.Phdrs) {
+		ehdr.E_phentsize = Elf64_Half(phdrSize)
+	} else {
+		ehdr.E_phoff = 0
+	}
+	ehdr.E_shoff = 0
+	ehdr.E_shnum = 0
+	ehdr.E_shstrndx = SHN_UNDEF
+	total := offset
+	if 0 < shnum {
+		ehdr.E_shoff = alignUp(offset, 8)
+		ehdr.E_shentsize = Elf64_Half(shdrSize)
+		total = ehdr.E_shoff + uint64(shnum)*shdrSize
+
+		// extended numbering keeps the real values in section 0
+		shdrs[0].Sh_size = 0
+		shdrs[0].Sh_link = 0
+		if shnum < SHN_LORESERVE {
+			ehdr.E_shnum = Elf64_Half(shnum)
+		} else {
+			shdrs[0].Sh_size = uint64(shnum)
+		}
+		if w.ShstrIdx < SHN_LORESERVE {
+			ehdr.E_shstrndx = Elf64_Half(w.ShstrIdx)
+		} else {
+			ehdr.E_shstrndx = SHN_XINDEX
+			shdrs[0].Sh_link = uint32(w.ShstrIdx)
+		}
+	}
+
+	// size the file once, the parts are then written at their offsets
+	out := binutil.NewLeWriter()
+	out.Seek(total)
+	out.Seek(0)
+	if 0 < len(w.Phdrs) {
+		// keep the bytes of the segments that are not in any section
+		out.PutBytes(w.orig[:min(end, uint64(len(w.orig)))])
+		out.Seek(0)
+	}
+	putElf64Ehdr(out, ehdr)
+	out.Seek(ehdr.E_phoff)
+	for _, phdr := range w.Phdrs {
+		putElf64Phdr(out, phdr)
+	}
+	for i, sh := range shdrs {
+		if sh.Sh_type != SHT_NULL && sh.Sh_type != SHT_NOBITS {
+			out.Seek(sh.Sh_offset)
+			out.PutBytes(datas[i])
+		}
+	}
+	out.Seek(ehdr.E_shoff)
+	for _, sh := range shdrs {
+		PutElf64Shdr(out, sh)
+	}
+	return out.Bytes(), nil
+}
+
+// Elf32Section is a section of Elf32Writer, see Elf64Section
+type Elf32Section struct {
+	Name string
+	Shdr Elf32_Shdr
+	Data []byte
+}
+
+// Elf32Symbol is a .symtab entry of Elf32Writer, see Elf64Symbol
+type Elf32Symbol struct {
+	Name  string
+	Sym   Elf32_Sym
+	Shndx uint32
+}
+
+// Elf32Writer is the ELF32 version of Elf64Writer
+type Elf32Writer struct {
+	Ehdr      Elf32Ehdr
+	Phdrs     []Elf32Phdr
+	Sections  []Elf32Section
+	Symbols   []Elf32Symbol
+	ShstrIdx  int // -1 when there is no section name table
+	SymTabIdx int // -1 when there is no .symtab
+	orig      []byte
+}
+
+func NewElf32Writer(elfObj *Elf32Object) *Elf32Writer {
+	w := Elf32Writer{}
+	w.Ehdr = elfObj.Elf32Ehdr
+	w.Ehdr.E_ident = append([]byte{}, elfObj.Elf32Ehdr.E_ident...)
+	w.Phdrs = append([]Elf32Phdr{}, elfObj.Phdrs...)
+	w.Sections = []Elf32Section{}
+	for i, sh := range elfObj.Shdrs {
+		sec := Elf32Section{}
+		sec.Name = elfObj.GetSectionName(i)
+		sec.Shdr = sh
+		if sh.Sh_type != SHT_NULL && sh.Sh_type != SHT_NOBITS {
+			end := sh.Sh_offset + sh.Sh_size
+			sec.Data = elfObj.Bin[sh.Sh_offset:end:end]
+		}
+		w.Sections = append(w.Sections, sec)
+	}
+
+	w.ShstrIdx = -1
+	if 0 < len(elfObj.Shdrs) {
+		w.ShstrIdx = int(elfObj.GetShstrndx())
+	}
+	w.SymTabIdx = -1
+	w.Symbols = []Elf32Symbol{}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if exist && elfObj.Shdrs[symTabIdx].Sh_type == SHT_SYMTAB {
+		w.SymTabIdx = symTabIdx
+		for i, sym := range elfObj.SymTbl {
+			s := Elf32Symbol{}
+			s.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+			s.Sym = sym
+			s.Shndx = elfObj.GetSymShndx(i)
+			w.Symbols = append(w.Symbols, s)
+		}
+	}
+	w.orig = elfObj.Bin
+	return &w
+}
+
+// GetSectionIdx returns the index of the first section named name, or -1
+func (w *Elf32Writer) GetSectionIdx(name string) int {
+	for i, sec := range w.Sections {
+		if sec.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *Elf32Writer) buildSymTab(shdrs []Elf32_Shdr, datas [][]byte, shstrTab *binutil.StrTabBuilder) error {
+	symTabSec := w.Sections[w.SymTabIdx]
+	strIdx := int(symTabSec.Shdr.Sh_link)
+	if strIdx <= 0 || len(w.Sections) <= strIdx || w.Sections[strIdx].Shdr.Sh_type != SHT_STRTAB {
+		return fmt.Errorf("%s: sh_link %d is not a string table", symTabSec.Name, strIdx)
+	}
+	strTab := binutil.NewStrTabBuilder()
+	if strIdx == w.ShstrIdx {
+		strTab = shstrTab
+	}
+	shndxIdx := -1
+	for i, sec := range w.Sections {
+		if sec.Shdr.Sh_type == SHT_SYMTAB_SHNDX && int(sec.Shdr.Sh_link) == w.SymTabIdx {
+			shndxIdx = i
+			break
+		}
+	}
+
+	symSize := uint64(unsafe.Sizeof(Elf32_Sym{}))
+	symBin := binutil.NewLeWriter()
+	shndxBin := binutil.NewLeWriter()
+	shndxBin.Pad(uint64(len(w.Symbols)) * 4)
+	firstGlobal := len(w.Symbols)
+	for i, s := range w.Symbols {
+		sym := s.Sym
+		sym.St_name = strTab.Add(s.Name)
+		if isSymInSection(sym.St_shndx) {
+			if s.Shndx < SHN_LORESERVE {
+				sym.St_shndx = Elf32_Section(s.Shndx)
+			} else if shndxIdx < 0 {
+				return fmt.Errorf("symbol [%d] %s: section index %d needs a SHT_SYMTAB_SHNDX section", i, s.Name, s.Shndx)
+			} else {
+				sym.St_shndx = SHN_XINDEX
+				shndxBin.Seek(uint64(i) * 4)
+				shndxBin.PutU32(s.Shndx)
+			}
+		}
+		// locals must precede globals
+		if sym.St_info>>4 != STB_LOCAL {
+			if firstGlobal == len(w.Symbols) {
+				firstGlobal = i
+			}
+		} else if firstGlobal < i {
+			return fmt.Errorf("symbol [%d] %s: local symbol follows a global symbol", i, s.Name)
+		}
+		PutElf32Sym(symBin, sym)
+	}
+
+	datas[w.SymTabIdx] = symBin.Bytes()
+	shdrs[w.SymTabIdx].Sh_info = uint32(firstGlobal)
+	shdrs[w.SymTabIdx].Sh_entsize = uint32(symSize)
+	if shndxIdx >= 0 {
+		datas[shndxIdx] = shndxBin.Bytes()
+	}
+	if strIdx != w.ShstrIdx {
+		datas[strIdx] = strTab.Bytes()
+	}
+	return nil
+}
+
+// Bytes serializes the object, the writer itself is not modified
+func (w *Elf32Writer) Bytes() ([]byte, error) {
+	shnum := len(w.Sections)
+	shdrs := make([]Elf32_Shdr, shnum)
+	datas := make([][]byte, shnum)
+	for i, sec := range w.Sections {
+		shdrs[i] = sec.Shdr
+		datas[i] = sec.Data
+	}
+	if 0 < shnum && shdrs[0].Sh_type != SHT_NULL {
+		return nil, errors.New("section [0] must be SHT_NULL")
+	}
+	if w.ShstrIdx >= shnum || (0 < shnum && w.ShstrIdx < 0) {
+		return nil, fmt.Errorf("section name table index %d is out of range (%d sections)", w.ShstrIdx, shnum)
+	}
+	if w.SymTabIdx >= shnum {
+		return nil, fmt.Errorf(".symtab index %d is out of range (%d sections)", w.SymTabIdx, shnum)
+	}
+
+	shstrTab := binutil.NewStrTabBuilder()
+	for i, sec := range w.Sections {
+		shdrs[i].Sh_name = shstrTab.Add(sec.Name)
+	}
+	if 0 <= w.SymTabIdx {
+		err := w.buildSymTab(shdrs, datas, shstrTab)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if 0 <= w.ShstrIdx {
+		datas[w.ShstrIdx] = shstrTab.Bytes()
+	}
+
+	// sections of a loaded image keep their place, the rest goes behind it
+	ehdrSize := uint64(ELF32_EHDR_SIZE)
+	phdrSize := uint64(unsafe.Sizeof(Elf32Phdr{}))
+	shdrSize := uint64(unsafe.Sizeof(Elf32_Shdr{}))
+	end := ehdrSize
+	fixed := make([]bool, shnum)
+	if 0 < len(w.Phdrs) {
+		end = max(end, uint64(w.Ehdr.E_phoff)+uint64(len(w.Phdrs))*phdrSize)
+		for i, phdr := range w.Phdrs {
+			if uint64(len(w.orig)) < uint64(phdr.P_offset)+uint64(phdr.P_filesz) {
+				return nil, fmt.Errorf("segment [%d] is not in the file", i)
+			}
+			end = max(end, uint64(phdr.P_offset)+uint64(phdr.P_filesz))
+		}
+		for i, sh := range shdrs {
+			if sh.Sh_type == SHT_NULL || sh.Sh_flags&SHF_ALLOC == 0 {
+				continue
+			}
+			fixed[i] = true
+			if sh.Sh_type == SHT_NOBITS {
+				continue
+			}
+			if uint64(len(datas[i])) != uint64(sh.Sh_size) {
+				return nil, fmt.Errorf("section [%d] %s: SHF_ALLOC section cannot change its size", i, w.Sections[i].Name)
+			}
+			end = max(end, uint64(sh.Sh_offset)+uint64(sh.Sh_size))
+		}
+	}
+
+	offset := end
+	for i, sh := range shdrs {
+		if i == 0 || fixed[i] {
+			continue
+		}
+		err := checkAlign(i, w.Sections[i].Name, uint64(sh.Sh_addralign))
+		if err != nil {
+			return nil, err
+		}
+		offset = alignUp(offset, uint64(sh.Sh_addralign))
+		shdrs[i].Sh_offset = Elf32_Off(offset)
+		if sh.Sh_type != SHT_NOBITS {
+			shdrs[i].Sh_size = Elf32_Word(len(datas[i]))
+			offset += uint64(len(datas[i]))
+		}
+	}
+
+	ehdr := w.Ehdr
+	ehdr.E_ehsize = Elf32_Half(ehdrSize)
+	ehdr.E_phnum = Elf32_Half(len(w.Phdrs))
+	if 0 < len(w.Phdrs) {
+		ehdr.E_phentsize = Elf32_Half(phdrSize)
+	} else {
+		ehdr.E_phoff = 0
+	}
+	ehdr.E_shoff = 0
+	ehdr.E_shnum = 0
+	ehdr.E_shstrndx = SHN_UNDEF
+	total := offset
+	if 0 < shnum {
+		shoff := alignUp(offset, 4)
+		total = shoff + uint64(shnum)*shdrSize
+		ehdr.E_shoff = Elf32_Off(shoff)
+		ehdr.E_shentsize = Elf32_Half(shdrSize)
+
+		// extended numbering keeps the real values in section 0
+		shdrs[0].Sh_size = 0
+		shdrs[0].Sh_link = 0
+		if shnum < SHN_LORESERVE {
+			ehdr.E_shnum = Elf32_Half(shnum)
+		} else {
+			shdrs[0].Sh_size = uint32(shnum)
+		}
+		if w.ShstrIdx < SHN_LORESERVE {
+			ehdr.E_shstrndx = Elf32_Half(w.ShstrIdx)
+		} else {
+			ehdr.E_shstrndx = SHN_XINDEX
+			shdrs[0].Sh_link = uint32(w.ShstrIdx)
+		}
+	}
+	if 0xFFFFFFFF < total {
+		return nil, fmt.Errorf("ELF32 object would be %d bytes", total)
+	}
+
+	// size the file once, the parts are then written at their offsets
+	out := binutil.NewLeWriter()
+	out.Seek(total)
+	out.Seek(0)
+	if 0 < len(w.Phdrs) {
+		// keep the bytes of the segments that are not in any section
+		out.PutBytes(w.orig[:min(end, uint64(len(w.orig)))])
+		out.Seek(0)
+	}
+	putElf32Ehdr(out, ehdr)
+	out.Seek(uint64(ehdr.E_phoff))
+	for _, phdr := range w.Phdrs {
+		putElf32Phdr(out, phdr)
+	}
+	for i, sh := range shdrs {
+		if sh.Sh_type != SHT_NULL && sh.Sh_type != SHT_NOBITS {
+			out.Seek(uint64(sh.Sh_offset))
+			out.PutBytes(datas[i])
+		}
+	}
+	out.Seek(uint64(ehdr.E_shoff))
+	for _, sh := range shdrs {
+		PutElf32Shdr(out, sh)
+	}
+	return out.Bytes(), nil
+}
+
+func putElf64Ehdr(w *binutil.Writer, ehdr Elf64Ehdr) {
+	w.PutFixedBytes(ehdr.E_ident, EI_NIDENT)
+	w.PutU16(ehdr.E_type)
+	w.PutU16(ehdr.E_machine)
+	w.PutU32(ehdr.E_version)
+	w.PutU64(ehdr.E_entry)
+	w.PutU64(ehdr.E_phoff)
+	w.PutU64(ehdr.E_shoff)
+	w.PutU32(ehdr.E_flags)
+	w.PutU16(ehdr.E_ehsize)
+	w.PutU16(ehdr.E_phentsize)
+	w.PutU16(ehdr.E_phnum)
+	w.PutU16(ehdr.E_shentsize)
+	w.PutU16(ehdr.E_shnum)
+	w.PutU16(ehdr.E_shstrndx)
+}
+
+func putElf32Ehdr(w *binutil.Writer, ehdr Elf32Ehdr) {
+	w.PutFixedBytes(ehdr.E_ident, EI_NIDENT)
+	w.PutU16(ehdr.E_type)
+	w.PutU16(ehdr.E_machine)
+	w.PutU32(ehdr.E_version)
+	w.PutU32(ehdr.E_entry)
+	w.PutU32(ehdr.E_phoff)
+	w.PutU32(ehdr.E_shoff)
+	w.PutU32(ehdr.E_flags)
+	w.PutU16(ehdr.E_ehsize)
+	w.PutU16(ehdr.E_phentsize)
+	w.PutU16(ehdr.E_phnum)
+	w.PutU16(ehdr.E_shentsize)
+	w.PutU16(ehdr.E_shnum)
+	w.PutU16(ehdr.E_shstrndx)
+}
+
+func putElf64Phdr(w *binutil.Writer, phdr Elf64Phdr) {
+	w.PutU32(phdr.P_type)
+	w.PutU32(phdr.P_flags)
+	w.PutU64(phdr.P_offset)
+	w.PutU64(phdr.P_vaddr)
+	w.PutU64(phdr.P_paddr)
+	w.PutU64(phdr.P_filesz)
+	w.PutU64(phdr.P_memsz)
+	w.PutU64(phdr.P_align)
+}
+
+func putElf32Phdr(w *binutil.Writer, phdr Elf32Phdr) {
+	w.PutU32(phdr.P_type)
+	w.PutU32(phdr.P_offset)
+	w.PutU32(phdr.P_vaddr)
+	w.PutU32(phdr.P_paddr)
+	w.PutU32(phdr.P_filesz)
+	w.PutU32(phdr.P_memsz)
+	w.PutU32(phdr.P_flags)
+	w.PutU32(phdr.P_align)
+}
+
+// PutElf64Shdr writes a section header as it is laid out in a file
+func PutElf64Shdr(w *binutil.Writer, sh Elf64_Shdr) {
+	w.PutU32(sh.Sh_name)
+	w.PutU32(sh.Sh_type)
+	w.PutU64(sh.Sh_flags)
+	w.PutU64(sh.Sh_addr)
+	w.PutU64(sh.Sh_offset)
+	w.PutU64(sh.Sh_size)
+	w.PutU32(sh.Sh_link)
+	w.PutU32(sh.Sh_info)
+	w.PutU64(sh.Sh_addralign)
+	w.PutU64(sh.Sh_entsize)
+}
+
+// PutElf32Shdr is the ELF32 version of PutElf64Shdr
+func PutElf32Shdr(w *binutil.Writer, sh Elf32_Shdr) {
+	w.PutU32(sh.Sh_name)
+	w.PutU32(sh.Sh_type)
+	w.PutU32(sh.Sh_flags)
+	w.PutU32(sh.Sh_addr)
+	w.PutU32(sh.Sh_offset)
+	w.PutU32(sh.Sh_size)
+	w.PutU32(sh.Sh_link)
+	w.PutU32(sh.Sh_info)
+	w.PutU32(sh.Sh_addralign)
+	w.PutU32(sh.Sh_entsize)
+}
+
+// PutElf64Sym writes a symbol table entry as it is laid out in a file
+func PutElf64Sym(w *binutil.Writer, sym Elf64_Sym) {
+	w.PutU32(sym.St_name)
+	w.PutU8(sym.St_info)
+	w.PutU8(sym.St_other)
+	w.PutU16(sym.St_shndx)
+	w.PutU64(sym.St_value)
+	w.PutU64(sym.St_size)
+}
+
+// PutElf32Sym is the ELF32 version of PutElf64Sym
+func PutElf32Sym(w *binutil.Writer, sym Elf32_Sym) {
+	w.PutU32(sym.St_name)
+	w.PutU32(sym.St_value)
+	w.PutU32(sym.St_size)
+	w.PutU8(sym.St_info)
+	w.PutU8(sym.St_other)
+	w.PutU16(sym.St_shndx)
+}
+
+// PutElf64Rela writes a relocation, without the addend for an SHT_REL entry
+func PutElf64Rela(w *binutil.Writer, rela Elf64_Rela, withAddend bool) {
+	w.PutU64(rela.R_offset)
+	w.PutU64(rela.R_info)
+	if withAddend {
+		w.PutS64(rela.R_addend)
+	}
+}
+
+// PutElf32Rela is the ELF32 version of PutElf64Rela
+func PutElf32Rela(w *binutil.Writer, rela Elf32_Rela, withAddend bool) {
+	w.PutU32(rela.R_offset)
+	w.PutU32(rela.R_info)
+	if withAddend {
+		w.PutS32(rela.R_addend)
+	}
+}
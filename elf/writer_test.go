@@ -0,0 +1,494 @@
+package elf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the contents of an object by name, section and symbol indexes change when
+// sections are removed and the tables the writer rebuilds differ in layout
+type objView struct {
+	Sections []secView
+	Symbols  []symView
+}
+
+type secView struct {
+	Name      string
+	Type      uint32
+	Flags     uint64
+	Addr      uint64
+	Link      string
+	Info      string
+	Addralign uint64
+	Entsize   uint64
+	Data      string    // raw contents, the member names of a group
+	Relocs    []relView // relocations against .symtab
+}
+
+type symView struct {
+	Name    string
+	Info    uint8
+	Other   uint8
+	Section string
+	Value   uint64
+	Size    uint64
+}
+
+type relView struct {
+	Offset uint64
+	Type   uint32
+	Sym    symView
+	Addend int64
+}
+
+func readTestElf64(t *testing.T, path string) *Elf64Object {
+	t.Helper()
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elfObj, err := NewElf64(path, bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return elfObj
+}
+
+func readTestElf32(t *testing.T, path string) *Elf32Object {
+	t.Helper()
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elfObj, err := NewElf32(path, bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return elfObj
+}
+
+// roundTrip64 writes w and parses the output again, which must pass Verify
+func roundTrip64(t *testing.T, name string, w *Elf64Writer) *Elf64Object {
+	t.Helper()
+	bin, err := w.Bytes()
+	if err != nil {
+		t.Fatalf("%s: Bytes() failed: %v", name, err)
+	}
+	elfObj, err := NewElf64(name, bin)
+	if err != nil {
+		t.Fatalf("%s: cannot parse the output: %v", name, err)
+	}
+	for _, err := range elfObj.Verify() {
+		t.Errorf("%s: %v", name, err)
+	}
+	return elfObj
+}
+
+// roundTrip32 is the ELF32 version of roundTrip64
+func roundTrip32(t *testing.T, name string, w *Elf32Writer) *Elf32Object {
+	t.Helper()
+	bin, err := w.Bytes()
+	if err != nil {
+		t.Fatalf("%s: Bytes() failed: %v", name, err)
+	}
+	elfObj, err := NewElf32(name, bin)
+	if err != nil {
+		t.Fatalf("%s: cannot parse the output: %v", name, err)
+	}
+	for _, err := range elfObj.Verify() {
+		t.Errorf("%s: %v", name, err)
+	}
+	return elfObj
+}
+
+func groupMemberNames(data []byte, sectionName func(int) string) string {
+	if len(data) < 4 {
+		return string(data)
+	}
+	names := []string{fmt.Sprintf("flags 0x%x", binary.LittleEndian.Uint32(data))}
+	for offset := 4; offset+4 <= len(data); offset += 4 {
+		names = append(names, sectionName(int(binary.LittleEndian.Uint32(data[offset:]))))
+	}
+	return strings.Join(names, " ")
+}
+
+func newElf64View(elfObj *Elf64Object) objView {
+	view := objView{}
+	sectionName := func(shIdx int) string {
+		if shIdx < len(elfObj.Shdrs) {
+			return elfObj.GetSectionName(shIdx)
+		}
+		return fmt.Sprintf("<section %d>", shIdx)
+	}
+	symTabIdx := -1
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_SYMTAB {
+			symTabIdx = i
+		}
+	}
+	for i := range elfObj.SymTbl {
+		view.Symbols = append(view.Symbols, elf64SymView(elfObj, i))
+	}
+
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		sec := secView{}
+		sec.Name = elfObj.GetSectionName(i)
+		sec.Type = sh.Sh_type
+		sec.Flags = sh.Sh_flags
+		sec.Addr = sh.Sh_addr
+		sec.Addralign = sh.Sh_addralign
+		sec.Entsize = sh.Sh_entsize
+		if 0 < sh.Sh_link {
+			sec.Link = sectionName(int(sh.Sh_link))
+		}
+		switch {
+		case hasInfoLink(sh.Sh_type, sh.Sh_flags):
+			sec.Info = sectionName(int(sh.Sh_info))
+		case sh.Sh_type == SHT_GROUP && int(sh.Sh_link) == symTabIdx && int(sh.Sh_info) < len(view.Symbols):
+			sec.Info = view.Symbols[sh.Sh_info].Name
+		case sh.Sh_type != SHT_SYMTAB:
+			sec.Info = fmt.Sprint(sh.Sh_info)
+		}
+
+		rebuilt := i == int(elfObj.GetShstrndx()) || i == symTabIdx || sh.Sh_type == SHT_SYMTAB_SHNDX ||
+			(0 <= symTabIdx && i == int(elfObj.Shdrs[symTabIdx].Sh_link))
+		isSymTabReloc := (sh.Sh_type == SHT_REL || sh.Sh_type == SHT_RELA) && int(sh.Sh_link) == symTabIdx
+		data := []byte{}
+		if sh.Sh_type != SHT_NOBITS {
+			data = elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+		}
+		switch {
+		case rebuilt || sh.Sh_type == SHT_NOBITS:
+		case isSymTabReloc:
+			for _, rela := range elfObj.GetRelocations(i) {
+				rel := relView{Offset: rela.R_offset, Type: ELF64_R_TYPE(rela.R_info), Addend: rela.R_addend}
+				rel.Sym = view.Symbols[ELF64_R_SYM(rela.R_info)]
+				sec.Relocs = append(sec.Relocs, rel)
+			}
+		case sh.Sh_type == SHT_GROUP:
+			sec.Data = groupMemberNames(data, sectionName)
+		default:
+			sec.Data = string(data)
+		}
+		view.Sections = append(view.Sections, sec)
+	}
+	return view
+}
+
+func elf64SymView(elfObj *Elf64Object, symIdx int) symView {
+	sym := elfObj.SymTbl[symIdx]
+	s := symView{}
+	s.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+	s.Info = sym.St_info
+	s.Other = sym.St_other
+	s.Section = fmt.Sprintf("<st_shndx 0x%x>", sym.St_shndx)
+	if elfObj.IsSymInSection(symIdx) {
+		s.Section = elfObj.GetSectionName(int(elfObj.GetSymShndx(symIdx)))
+	}
+	s.Value = sym.St_value
+	s.Size = sym.St_size
+	return s
+}
+
+// newElf32View is the ELF32 version of newElf64View
+func newElf32View(elfObj *Elf32Object) objView {
+	view := objView{}
+	sectionName := func(shIdx int) string {
+		if shIdx < len(elfObj.Shdrs) {
+			return elfObj.GetSectionName(shIdx)
+		}
+		return fmt.Sprintf("<section %d>", shIdx)
+	}
+	symTabIdx := -1
+	for i, sh := range elfObj.Shdrs {
+		if sh.Sh_type == SHT_SYMTAB {
+			symTabIdx = i
+		}
+	}
+	for i := range elfObj.SymTbl {
+		view.Symbols = append(view.Symbols, elf32SymView(elfObj, i))
+	}
+
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		sec := secView{}
+		sec.Name = elfObj.GetSectionName(i)
+		sec.Type = sh.Sh_type
+		sec.Flags = uint64(sh.Sh_flags)
+		sec.Addr = uint64(sh.Sh_addr)
+		sec.Addralign = uint64(sh.Sh_addralign)
+		sec.Entsize = uint64(sh.Sh_entsize)
+		if 0 < sh.Sh_link {
+			sec.Link = sectionName(int(sh.Sh_link))
+		}
+		switch {
+		case hasInfoLink(sh.Sh_type, uint64(sh.Sh_flags)):
+			sec.Info = sectionName(int(sh.Sh_info))
+		case sh.Sh_type == SHT_GROUP && int(sh.Sh_link) == symTabIdx && int(sh.Sh_info) < len(view.Symbols):
+			sec.Info = view.Symbols[sh.Sh_info].Name
+		case sh.Sh_type != SHT_SYMTAB:
+			sec.Info = fmt.Sprint(sh.Sh_info)
+		}
+
+		rebuilt := i == int(elfObj.GetShstrndx()) || i == symTabIdx || sh.Sh_type == SHT_SYMTAB_SHNDX ||
+			(0 <= symTabIdx && i == int(elfObj.Shdrs[symTabIdx].Sh_link))
+		isSymTabReloc := (sh.Sh_type == SHT_REL || sh.Sh_type == SHT_RELA) && int(sh.Sh_link) == symTabIdx
+		data := []byte{}
+		if sh.Sh_type != SHT_NOBITS {
+			data = elfObj.Bin[sh.Sh_offset : sh.Sh_offset+sh.Sh_size]
+		}
+		switch {
+		case rebuilt || sh.Sh_type == SHT_NOBITS:
+		case isSymTabReloc:
+			for _, rela := range elfObj.GetRelocations(i) {
+				rel := relView{Offset: uint64(rela.R_offset), Type: ELF32_R_TYPE(rela.R_info), Addend: int64(rela.R_addend)}
+				rel.Sym = view.Symbols[ELF32_R_SYM(rela.R_info)]
+				sec.Relocs = append(sec.Relocs, rel)
+			}
+		case sh.Sh_type == SHT_GROUP:
+			sec.Data = groupMemberNames(data, sectionName)
+		default:
+			sec.Data = string(data)
+		}
+		view.Sections = append(view.Sections, sec)
+	}
+	return view
+}
+
+func elf32SymView(elfObj *Elf32Object, symIdx int) symView {
+	sym := elfObj.SymTbl[symIdx]
+	s := symView{}
+	s.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+	s.Info = sym.St_info
+	s.Other = sym.St_other
+	s.Section = fmt.Sprintf("<st_shndx 0x%x>", sym.St_shndx)
+	if elfObj.IsSymInSection(symIdx) {
+		s.Section = elfObj.GetSectionName(int(elfObj.GetSymShndx(symIdx)))
+	}
+	s.Value = uint64(sym.St_value)
+	s.Size = uint64(sym.St_size)
+	return s
+}
+
+// renameView is view after RenameSection(oldName, newName)
+func renameView(view objView, oldName string, newName string) objView {
+	rename := func(name string) string {
+		if name == oldName {
+			return newName
+		}
+		return name
+	}
+	out := objView{}
+	for _, s := range view.Symbols {
+		s.Section = rename(s.Section)
+		out.Symbols = append(out.Symbols, s)
+	}
+	for _, sec := range view.Sections {
+		sec.Name = rename(sec.Name)
+		sec.Link = rename(sec.Link)
+		if hasInfoLink(sec.Type, sec.Flags) {
+			sec.Info = rename(sec.Info)
+		}
+		relocs := []relView{}
+		for _, rel := range sec.Relocs {
+			rel.Sym.Section = rename(rel.Sym.Section)
+			relocs = append(relocs, rel)
+		}
+		if sec.Relocs != nil {
+			sec.Relocs = relocs
+		}
+		if sec.Type == SHT_GROUP {
+			names := strings.Split(sec.Data, " ")
+			for i := 2; i < len(names); i++ {
+				names[i] = rename(names[i])
+			}
+			sec.Data = strings.Join(names, " ")
+		}
+		out.Sections = append(out.Sections, sec)
+	}
+	return out
+}
+
+// removeView is view after RemoveSections(name) of a section without groups,
+// its relocation sections and symbols go with it
+func removeView(view objView, name string) objView {
+	out := objView{}
+	for _, s := range view.Symbols {
+		if s.Section != name {
+			out.Symbols = append(out.Symbols, s)
+		}
+	}
+	for _, sec := range view.Sections {
+		if sec.Name == name || ((sec.Type == SHT_REL || sec.Type == SHT_RELA) && sec.Info == name) {
+			continue
+		}
+		out.Sections = append(out.Sections, sec)
+	}
+	return out
+}
+
+// compareViews reports the first differences of got and want
+func compareViews(t *testing.T, name string, got objView, want objView) {
+	t.Helper()
+	if len(got.Sections) != len(want.Sections) {
+		t.Errorf("%s: %d sections, want %d", name, len(got.Sections), len(want.Sections))
+	}
+	for i := 0; i < min(len(got.Sections), len(want.Sections)); i++ {
+		if !reflect.DeepEqual(got.Sections[i], want.Sections[i]) {
+			g, w := got.Sections[i], want.Sections[i]
+			g.Data, w.Data = fmt.Sprintf("%d bytes", len(g.Data)), fmt.Sprintf("%d bytes", len(w.Data))
+			t.Errorf("%s: section [%d] %s differs:\n got %+v\nwant %+v", name, i+1, want.Sections[i].Name, g, w)
+		}
+	}
+	if !reflect.DeepEqual(got.Symbols, want.Symbols) {
+		t.Errorf("%s: symbols differ:\n got %+v\nwant %+v", name, got.Symbols, want.Symbols)
+	}
+}
+
+var elf64TestObjects = []string{
+	"../testdata/func_x86_64.o",
+	"../testdata/func_x86_64_dwarf4.o",
+	"../testdata/func_x86_64_split.o",
+	"../testdata/types_x86_64_dwarf4.o",
+	"../testdata/versioned_x86_64.so",
+}
+
+func TestElf64WriterRoundTrip(t *testing.T) {
+	for _, path := range elf64TestObjects {
+		elfObj := readTestElf64(t, path)
+		out := roundTrip64(t, path, NewElf64Writer(elfObj))
+		compareViews(t, path, newElf64View(out), newElf64View(elfObj))
+	}
+}
+
+func TestElf32WriterRoundTrip(t *testing.T) {
+	path := "../testdata/func_i386.o"
+	elfObj := readTestElf32(t, path)
+	out := roundTrip32(t, path, NewElf32Writer(elfObj))
+	compareViews(t, path, newElf32View(out), newElf32View(elfObj))
+}
+
+func TestElf64WriterEditSections(t *testing.T) {
+	path := "../testdata/func_x86_64.o"
+	elfObj := readTestElf64(t, path)
+	want := newElf64View(elfObj)
+
+	// the relocations and symbols of .text refer to it by index
+	w := NewElf64Writer(elfObj)
+	err := w.RenameSection(".text", ".text.renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := roundTrip64(t, "rename .text", w)
+	compareViews(t, "rename .text", newElf64View(out), renameView(want, ".text", ".text.renamed"))
+
+	// .rela.eh_frame goes with .eh_frame, the sections behind them move down
+	w = NewElf64Writer(elfObj)
+	err = w.RemoveSections(".eh_frame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = roundTrip64(t, "remove .eh_frame", w)
+	compareViews(t, "remove .eh_frame", newElf64View(out), removeView(want, ".eh_frame"))
+
+	// the added section is written as is
+	w = NewElf64Writer(elfObj)
+	w.AddSection(".note.added", []byte("added"))
+	out = roundTrip64(t, "add .note.added", w)
+	added := secView{Name: ".note.added", Type: SHT_PROGBITS, Info: "0", Addralign: 1, Data: "added"}
+	want.Sections = append(want.Sections, added)
+	compareViews(t, "add .note.added", newElf64View(out), want)
+}
+
+func TestElf32WriterEditSections(t *testing.T) {
+	path := "../testdata/func_i386.o"
+	elfObj := readTestElf32(t, path)
+	want := newElf32View(elfObj)
+
+	w := NewElf32Writer(elfObj)
+	err := w.RenameSection(".text", ".text.renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := roundTrip32(t, "rename .text", w)
+	compareViews(t, "rename .text", newElf32View(out), renameView(want, ".text", ".text.renamed"))
+
+	// SHT_REL, .rel.eh_frame goes with .eh_frame
+	w = NewElf32Writer(elfObj)
+	err = w.RemoveSections(".eh_frame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = roundTrip32(t, "remove .eh_frame", w)
+	compareViews(t, "remove .eh_frame", newElf32View(out), removeView(want, ".eh_frame"))
+
+	// .debug_info refers to the section symbol of .debug_line
+	w = NewElf32Writer(elfObj)
+	err = w.RemoveSections(".debug_line")
+	if err == nil {
+		t.Errorf("RemoveSections(.debug_line) = nil, want an error")
+	}
+}
+
+func TestElf64WriterExtendedNumbering(t *testing.T) {
+	path := "../testdata/func_x86_64.o"
+	elfObj := readTestElf64(t, path)
+	want := newElf64View(elfObj)
+	w := NewElf64Writer(elfObj)
+
+	// e_shnum, e_shstrndx and st_shndx do not hold indexes from SHN_LORESERVE on
+	shndx := Elf64Section{Name: ".symtab_shndx"}
+	shndx.Shdr.Sh_type = SHT_SYMTAB_SHNDX
+	shndx.Shdr.Sh_link = uint32(w.SymTabIdx)
+	shndx.Shdr.Sh_addralign = 4
+	shndx.Shdr.Sh_entsize = 4
+	w.Sections = append(w.Sections, shndx)
+	for len(w.Sections) < SHN_LORESERVE+0x100 {
+		w.AddSection(".added", []byte{1})
+	}
+	lastIdx := w.AddSection(".added.last", []byte{2})
+	sym := Elf64Symbol{Name: "added_sym", Shndx: uint32(lastIdx)}
+	sym.Sym.St_info = STB_GLOBAL<<4 | STT_OBJECT
+	sym.Sym.St_shndx = 1
+	sym.Sym.St_size = 1
+	w.Symbols = append(w.Symbols, sym)
+
+	// the section name table moves behind the added sections
+	w.Sections[w.ShstrIdx].Name = ".shstrtab.old"
+	strtab := Elf64Section{Name: ".shstrtab"}
+	strtab.Shdr.Sh_type = SHT_STRTAB
+	strtab.Shdr.Sh_addralign = 1
+	w.Sections = append(w.Sections, strtab)
+	w.ShstrIdx = len(w.Sections) - 1
+
+	out := roundTrip64(t, "extended numbering", w)
+	if out.Elf64Ehdr.E_shnum != 0 || out.Elf64Ehdr.E_shstrndx != SHN_XINDEX {
+		t.Errorf("e_shnum %d, e_shstrndx 0x%x, want 0 and SHN_XINDEX", out.Elf64Ehdr.E_shnum, out.Elf64Ehdr.E_shstrndx)
+	}
+	if len(out.Shdrs) != len(w.Sections) || int(out.GetShstrndx()) != w.ShstrIdx {
+		t.Fatalf("%d sections, .shstrtab [%d], want %d and [%d]", len(out.Shdrs), out.GetShstrndx(), len(w.Sections), w.ShstrIdx)
+	}
+	got := newElf64View(out)
+
+	// the original sections keep their contents, the old name table is an ordinary string table
+	oldShstrIdx := int(elfObj.GetShstrndx())
+	oldShstr := elfObj.Shdrs[oldShstrIdx]
+	want.Sections[oldShstrIdx-1].Name = ".shstrtab.old"
+	want.Sections[oldShstrIdx-1].Data = string(elfObj.Bin[oldShstr.Sh_offset : oldShstr.Sh_offset+oldShstr.Sh_size])
+	added := symView{Name: "added_sym", Info: STB_GLOBAL<<4 | STT_OBJECT, Section: ".added.last", Size: 1}
+	want.Symbols = append(want.Symbols, added)
+	got.Sections = got.Sections[:len(want.Sections)]
+	compareViews(t, "extended numbering", got, want)
+	if sym := out.SymTbl[len(out.SymTbl)-1]; sym.St_shndx != SHN_XINDEX {
+		t.Errorf("added_sym st_shndx 0x%x, want SHN_XINDEX", sym.St_shndx)
+	}
+}
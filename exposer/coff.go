@@ -0,0 +1,36 @@
+package exposer
+
+import (
+	coff "sym-exposer/coff"
+	manifest "sym-exposer/manifest"
+)
+
+// COFF has no ordering between static and external symbols, exposing a
+// function only changes its storage class in place
+func (e *Exposer) exposeCoff(path string, bin []byte, report *Report) error {
+	coffObj, err := coff.NewCoff(path, bin)
+	if err != nil {
+		return err
+	}
+	changes := report.Changes
+	for _, sym := range coffObj.Symbols {
+		if sym.StorageClass != coff.IMAGE_SYM_CLASS_STATIC || !sym.IsFunction() || sym.SectionNumber <= 0 {
+			continue
+		}
+		if !e.selected(sym.Name) {
+			continue
+		}
+		symChange := manifest.SymbolChange{}
+		symChange.Index = sym.Index
+		symChange.NewIndex = sym.Index
+		symChange.Name = sym.Name
+		symChange.OldInfo = sym.StorageClass
+		symChange.NewInfo = coff.IMAGE_SYM_CLASS_EXTERNAL
+		changes.AddSymbol(symChange)
+		report.Exposed = append(report.Exposed, sym.Name)
+
+		offset := uint64(coffObj.CoffHdr.PointerToSymbolTable) + uint64(sym.Index)*coff.SYMBOL_SIZE
+		changes.Write(bin, offset+coff.OFFSET_SYMBOL_STORAGE_CLASS, []byte{symChange.NewInfo})
+	}
+	return nil
+}
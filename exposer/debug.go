@@ -0,0 +1,81 @@
+package exposer
+
+import (
+	"errors"
+	"fmt"
+	elf "sym-exposer/elf"
+)
+
+// debugEditor is implemented by elf.Elf64Writer and elf.Elf32Writer
+type debugEditor interface {
+	StripDebug() error
+	OnlyKeepDebug()
+	AddDebugLink(debugName string, debugBin []byte) (int, error)
+	Bytes() ([]byte, error)
+}
+
+func newDebugEditor(path string, bin []byte) (debugEditor, error) {
+	if elf.IsELF64(bin) {
+		elfObj, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return nil, err
+		}
+		return elf.NewElf64Writer(elfObj), nil
+	}
+	if elf.IsELF32(bin) {
+		elfObj, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return nil, err
+		}
+		return elf.NewElf32Writer(elfObj), nil
+	}
+	msg := fmt.Sprintf("%s: debug sections can be stripped from ELF objects only", path)
+	return nil, errors.New(msg)
+}
+
+// StripDebug returns a copy of the ELF object bin without its .debug_* sections.
+// The offsets change, so a manifest of bin cannot restore the result.
+func StripDebug(path string, bin []byte) ([]byte, error) {
+	editor, err := newDebugEditor(path, bin)
+	if err != nil {
+		return nil, err
+	}
+	err = editor.StripDebug()
+	if err != nil {
+		return nil, err
+	}
+	return editor.Bytes()
+}
+
+// SplitDebug is StripDebug that also returns the debug sections as a separate
+// debug file. The stripped object gets a .gnu_debuglink section with debugName,
+// the file name the debug file is saved as, and its CRC32.
+func SplitDebug(path string, bin []byte, debugName string) ([]byte, []byte, error) {
+	debugEditor, err := newDebugEditor(path, bin)
+	if err != nil {
+		return nil, nil, err
+	}
+	debugEditor.OnlyKeepDebug()
+	debugBin, err := debugEditor.Bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	editor, err := newDebugEditor(path, bin)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = editor.StripDebug()
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = editor.AddDebugLink(debugName, debugBin)
+	if err != nil {
+		return nil, nil, err
+	}
+	stripped, err := editor.Bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return stripped, debugBin, nil
+}
@@ -0,0 +1,316 @@
+package exposer
+
+import (
+	"errors"
+	"fmt"
+	binutil "sym-exposer/binutil"
+	elf "sym-exposer/elf"
+	manifest "sym-exposer/manifest"
+)
+
+// symOrder is the new symbol table order, locals must precede globals, so
+// exposed functions are moved behind the remaining locals and become the first globals
+type symOrder struct {
+	order      []int    // new index to old index
+	newSymIdxs []uint32 // old index to new index
+	numLocals  int
+	numExposed int
+}
+
+func newSymOrder(numSyms int, isGlobal func(i int) bool, isExposed func(i int) bool) symOrder {
+	locals := []int{}
+	exposed := []int{}
+	globals := []int{}
+	for i := 0; i < numSyms; i++ {
+		if isGlobal(i) {
+			globals = append(globals, i)
+		} else if isExposed(i) {
+			exposed = append(exposed, i)
+		} else {
+			locals = append(locals, i)
+		}
+	}
+	o := symOrder{}
+	o.order = append(append(locals, exposed...), globals...)
+	o.newSymIdxs = make([]uint32, len(o.order))
+	for newIdx, oldIdx := range o.order {
+		o.newSymIdxs[oldIdx] = uint32(newIdx)
+	}
+	o.numLocals = len(locals)
+	o.numExposed = len(exposed)
+	return o
+}
+
+func (o *symOrder) isExposed(newIdx int) bool {
+	return o.numLocals <= newIdx && newIdx < o.numLocals+o.numExposed
+}
+
+// elfImage is what exposing reads of an ELF32 or ELF64 object. The structures
+// are widened to their ELF64 types and written back in the class of the object.
+type elfImage struct {
+	is64        bool
+	shoff       uint64
+	shdrs       []elf.Elf64_Shdr
+	syms        []elf.Elf64_Sym
+	symShndxTbl []uint32
+	symTabIdx   int
+	sectionName func(shIdx int) string
+	symName     func(symIdx int) string
+	relocIdxs   []int
+	relocs      func(relIdx int) []elf.Elf64_Rela // R_info in the ELF64 layout
+}
+
+func newElf64Image(path string, bin []byte) (*elfImage, error) {
+	elfObj, err := elf.NewElf64(path, bin)
+	if err != nil {
+		return nil, err
+	}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		msg := fmt.Sprintf("%s: not found .symtab section", path)
+		return nil, errors.New(msg)
+	}
+	return &elfImage{
+		is64:        true,
+		shoff:       elfObj.Elf64Ehdr.E_shoff,
+		shdrs:       elfObj.Shdrs,
+		syms:        elfObj.SymTbl,
+		symShndxTbl: elfObj.SymShndxTbl,
+		symTabIdx:   symTabIdx,
+		sectionName: elfObj.GetSectionName,
+		symName:     func(i int) string { return elfObj.GetStrFromStrTbl(elfObj.SymTbl[i].St_name) },
+		relocIdxs:   elfObj.GetRelocSectionIdxs(symTabIdx),
+		relocs:      elfObj.GetRelocations,
+	}, nil
+}
+
+func newElf32Image(path string, bin []byte) (*elfImage, error) {
+	elfObj, err := elf.NewElf32(path, bin)
+	if err != nil {
+		return nil, err
+	}
+	symTabIdx, exist := elfObj.SectionNameMap[".symtab"]
+	if !exist {
+		msg := fmt.Sprintf("%s: not found .symtab section", path)
+		return nil, errors.New(msg)
+	}
+	img := &elfImage{
+		shoff:       uint64(elfObj.Elf32Ehdr.E_shoff),
+		symShndxTbl: elfObj.SymShndxTbl,
+		symTabIdx:   symTabIdx,
+		sectionName: elfObj.GetSectionName,
+		symName:     func(i int) string { return elfObj.GetStrFromStrTbl(elfObj.SymTbl[i].St_name) },
+		relocIdxs:   elfObj.GetRelocSectionIdxs(symTabIdx),
+	}
+	for _, sh := range elfObj.Shdrs {
+		img.shdrs = append(img.shdrs, elf.Elf64_Shdr{
+			Sh_name: sh.Sh_name, Sh_type: sh.Sh_type, Sh_flags: uint64(sh.Sh_flags),
+			Sh_addr: uint64(sh.Sh_addr), Sh_offset: uint64(sh.Sh_offset), Sh_size: uint64(sh.Sh_size),
+			Sh_link: sh.Sh_link, Sh_info: sh.Sh_info, Sh_addralign: uint64(sh.Sh_addralign), Sh_entsize: uint64(sh.Sh_entsize),
+		})
+	}
+	for _, sym := range elfObj.SymTbl {
+		img.syms = append(img.syms, elf.Elf64_Sym{
+			St_name: sym.St_name, St_info: sym.St_info, St_other: sym.St_other,
+			St_shndx: sym.St_shndx, St_value: uint64(sym.St_value), St_size: uint64(sym.St_size),
+		})
+	}
+	img.relocs = func(relIdx int) []elf.Elf64_Rela {
+		relas := []elf.Elf64_Rela{}
+		for _, rela := range elfObj.GetRelocations(relIdx) {
+			relas = append(relas, elf.Elf64_Rela{
+				R_offset: uint64(rela.R_offset),
+				R_info:   elf.ELF64_R_INFO(elf.ELF32_R_SYM(rela.R_info), elf.ELF32_R_TYPE(rela.R_info)),
+				R_addend: int64(rela.R_addend),
+			})
+		}
+		return relas
+	}
+	return img, nil
+}
+
+// the entries are written in the class of the object
+
+func (img *elfImage) putShdr(w *binutil.Writer, sh elf.Elf64_Shdr) {
+	if img.is64 {
+		elf.PutElf64Shdr(w, sh)
+		return
+	}
+	elf.PutElf32Shdr(w, elf.Elf32_Shdr{
+		Sh_name: sh.Sh_name, Sh_type: sh.Sh_type, Sh_flags: uint32(sh.Sh_flags),
+		Sh_addr: uint32(sh.Sh_addr), Sh_offset: uint32(sh.Sh_offset), Sh_size: uint32(sh.Sh_size),
+		Sh_link: sh.Sh_link, Sh_info: sh.Sh_info, Sh_addralign: uint32(sh.Sh_addralign), Sh_entsize: uint32(sh.Sh_entsize),
+	})
+}
+
+func (img *elfImage) putSym(w *binutil.Writer, sym elf.Elf64_Sym) {
+	if img.is64 {
+		elf.PutElf64Sym(w, sym)
+		return
+	}
+	elf.PutElf32Sym(w, elf.Elf32_Sym{
+		St_name: sym.St_name, St_value: uint32(sym.St_value), St_size: uint32(sym.St_size),
+		St_info: sym.St_info, St_other: sym.St_other, St_shndx: sym.St_shndx,
+	})
+}
+
+func (img *elfImage) putRela(w *binutil.Writer, rela elf.Elf64_Rela, withAddend bool) {
+	if img.is64 {
+		elf.PutElf64Rela(w, rela, withAddend)
+		return
+	}
+	info := elf.ELF32_R_INFO(elf.ELF64_R_SYM(rela.R_info), elf.ELF64_R_TYPE(rela.R_info))
+	elf.PutElf32Rela(w, elf.Elf32_Rela{R_offset: uint32(rela.R_offset), R_info: info, R_addend: int32(rela.R_addend)}, withAddend)
+}
+
+// writeEntries writes the entries of a table at offset, only the bytes that
+// change are recorded, so a re-encoded entry patches the fields that changed
+func writeEntries(changes *manifest.Manifest, bin []byte, offset uint64, idxs []int, put func(w *binutil.Writer, idx int)) {
+	for _, idx := range idxs {
+		w := binutil.NewLeWriter()
+		put(w, idx)
+		entry := w.Bytes()
+		entryOffset := offset + uint64(idx)*w.Len()
+		old := bin[entryOffset : entryOffset+w.Len()]
+		start, end := 0, len(entry)
+		for start < end && entry[start] == old[start] {
+			start++
+		}
+		for start < end && entry[end-1] == old[end-1] {
+			end--
+		}
+		if start < end {
+			changes.Write(bin, entryOffset+uint64(start), entry[start:end])
+		}
+	}
+}
+
+func (e *Exposer) exposeElf64(path string, bin []byte, report *Report) error {
+	img, err := newElf64Image(path, bin)
+	if err != nil {
+		return err
+	}
+	return e.exposeElf(img, bin, report)
+}
+
+func (e *Exposer) exposeElf32(path string, bin []byte, report *Report) error {
+	img, err := newElf32Image(path, bin)
+	if err != nil {
+		return err
+	}
+	return e.exposeElf(img, bin, report)
+}
+
+func (e *Exposer) exposeElf(img *elfImage, bin []byte, report *Report) error {
+	changes := report.Changes
+	if e.opts.Trace != nil {
+		for i, sh := range img.shdrs {
+			fmt.Fprintf(e.opts.Trace, "section name: %s, sh_link: %d sh_info: %d\n", img.sectionName(i), sh.Sh_link, sh.Sh_info)
+		}
+	}
+
+	o := newSymOrder(len(img.syms),
+		func(i int) bool { return img.syms[i].St_info>>4 != elf.STB_LOCAL },
+		func(i int) bool {
+			return img.syms[i].St_info&0x0F == elf.STT_FUNC && e.selected(img.symName(i))
+		})
+
+	newIdxs := make([]int, len(o.order))
+	syms := make([]elf.Elf64_Sym, len(o.order))
+	for newIdx, oldIdx := range o.order {
+		newIdxs[newIdx] = newIdx
+		sym := img.syms[oldIdx]
+		syms[newIdx] = sym
+		if !o.isExposed(newIdx) {
+			continue
+		}
+		// set STB_GLOBAL if symbol is STB_LOCAL
+		syms[newIdx].St_info = (elf.STB_GLOBAL << 4) | elf.STT_FUNC&0x0F
+
+		symChange := manifest.SymbolChange{}
+		symChange.Index = uint32(oldIdx)
+		symChange.NewIndex = uint32(newIdx)
+		symChange.Name = img.symName(oldIdx)
+		symChange.OldInfo = sym.St_info
+		symChange.NewInfo = syms[newIdx].St_info
+		symChange.OldOther = sym.St_other
+		symChange.NewOther = sym.St_other
+		changes.AddSymbol(symChange)
+		report.Exposed = append(report.Exposed, symChange.Name)
+	}
+	symTabSh := img.shdrs[img.symTabIdx]
+	writeEntries(changes, bin, symTabSh.Sh_offset, newIdxs, func(w *binutil.Writer, newIdx int) {
+		img.putSym(w, syms[newIdx])
+	})
+
+	// extended section indexes are parallel to the symbol table
+	if 0 < len(img.symShndxTbl) {
+		for _, sh := range img.shdrs {
+			if sh.Sh_type != elf.SHT_SYMTAB_SHNDX || int(sh.Sh_link) != img.symTabIdx {
+				continue
+			}
+			writeEntries(changes, bin, sh.Sh_offset, newIdxs, func(w *binutil.Writer, newIdx int) {
+				if oldIdx := o.order[newIdx]; oldIdx < len(img.symShndxTbl) {
+					w.PutU32(img.symShndxTbl[oldIdx])
+				} else {
+					w.PutBytes(bin[sh.Sh_offset+uint64(newIdx)*4:][:4])
+				}
+			})
+		}
+	}
+
+	// sh_info of .symtab must be last local symbol index + 1,
+	// section groups refer to their signature symbol by sh_info
+	shdrs := append([]elf.Elf64_Shdr{}, img.shdrs...)
+	shIdxs := []int{}
+	lastLocalSymIdx := uint32(o.numLocals)
+	if symTabSh.Sh_info != lastLocalSymIdx {
+		secChange := manifest.SectionChange{}
+		secChange.Index = uint32(img.symTabIdx)
+		secChange.Name = ".symtab"
+		secChange.OldInfo = symTabSh.Sh_info
+		secChange.NewInfo = lastLocalSymIdx
+		changes.AddSection(secChange)
+		shdrs[img.symTabIdx].Sh_info = lastLocalSymIdx
+		shIdxs = append(shIdxs, img.symTabIdx)
+	}
+	for i, sh := range img.shdrs {
+		if sh.Sh_type != elf.SHT_GROUP || int(sh.Sh_link) != img.symTabIdx {
+			continue
+		}
+		if int(sh.Sh_info) >= len(o.newSymIdxs) || o.newSymIdxs[sh.Sh_info] == sh.Sh_info {
+			continue
+		}
+		secChange := manifest.SectionChange{}
+		secChange.Index = uint32(i)
+		secChange.Name = img.sectionName(i)
+		secChange.OldInfo = sh.Sh_info
+		secChange.NewInfo = o.newSymIdxs[sh.Sh_info]
+		changes.AddSection(secChange)
+		shdrs[i].Sh_info = secChange.NewInfo
+		shIdxs = append(shIdxs, i)
+	}
+
+	// symbol indexes in relocations follow the new order
+	for _, relIdx := range img.relocIdxs {
+		relSh := img.shdrs[relIdx]
+		relas := img.relocs(relIdx)
+		relaIdxs := []int{}
+		for i, rela := range relas {
+			symIdx := elf.ELF64_R_SYM(rela.R_info)
+			if int(symIdx) >= len(o.newSymIdxs) || o.newSymIdxs[symIdx] == symIdx {
+				continue
+			}
+			relas[i].R_info = elf.ELF64_R_INFO(o.newSymIdxs[symIdx], elf.ELF64_R_TYPE(rela.R_info))
+			relaIdxs = append(relaIdxs, i)
+		}
+		writeEntries(changes, bin, relSh.Sh_offset, relaIdxs, func(w *binutil.Writer, i int) {
+			img.putRela(w, relas[i], relSh.Sh_type == elf.SHT_RELA)
+		})
+	}
+
+	writeEntries(changes, bin, img.shoff, shIdxs, func(w *binutil.Writer, i int) {
+		img.putShdr(w, shdrs[i])
+	})
+	return nil
+}
@@ -0,0 +1,226 @@
+package exposer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	coff "sym-exposer/coff"
+	elf "sym-exposer/elf"
+	fileutil "sym-exposer/fileutil"
+	manifest "sym-exposer/manifest"
+)
+
+const (
+	FORMAT_ELF32 = "ELF32"
+	FORMAT_ELF64 = "ELF64"
+	FORMAT_COFF  = "COFF"
+)
+
+type Options struct {
+	// Filter selects the local functions to expose by name, nil exposes all of them
+	Filter func(name string) bool
+	// Verify checks the exposed ELF object before it is returned
+	Verify bool
+	// Trace receives the section listing of ELF objects when it is not nil
+	Trace io.Writer
+}
+
+type Exposer struct {
+	opts Options
+}
+
+// Report describes what Expose changed, Changes can be saved and used to restore the input
+type Report struct {
+	Path    string
+	Format  string
+	Exposed []string
+	// Declarations of the exposed functions found in the DWARF of an ELF object
+	Declarations []Declaration
+	Changes      *manifest.Manifest
+}
+
+// VerifyError is returned when the exposed object fails verification
+type VerifyError struct {
+	Path     string
+	Problems []error
+}
+
+func (err *VerifyError) Error() string {
+	return fmt.Sprintf("%s: %d problem(s) found", err.Path, len(err.Problems))
+}
+
+func New(opts Options) *Exposer {
+	return &Exposer{opts: opts}
+}
+
+// Expose reads a whole ELF32, ELF64 or x64 COFF object from r and returns a copy
+// with its local functions made global. If r has a Name method (e.g. *os.File)
+// the name is used in errors and in the manifest. An *os.File is mapped instead
+// of read, other readers with a Size method (e.g. *bytes.Reader) are read in one go.
+func (e *Exposer) Expose(r io.ReaderAt) ([]byte, Report, error) {
+	path := ""
+	if named, ok := r.(interface{ Name() string }); ok {
+		path = named.Name()
+	}
+	if f, ok := r.(*os.File); ok {
+		m, err := fileutil.MapFile(f)
+		if err != nil {
+			return nil, Report{}, err
+		}
+		defer m.Close()
+		return e.ExposeBytes(path, m.Bytes)
+	}
+
+	size := int64(-1)
+	if sized, ok := r.(interface{ Size() int64 }); ok {
+		size = sized.Size()
+	}
+	bin, err := fileutil.ReadAllAt(r, size)
+	if err != nil {
+		return nil, Report{}, err
+	}
+	return e.ExposeBytes(path, bin)
+}
+
+// ExposeBytes is Expose for an object already in memory, bin is not modified
+func (e *Exposer) ExposeBytes(path string, bin []byte) ([]byte, Report, error) {
+	out := make([]byte, len(bin))
+	copy(out, bin)
+
+	report := Report{}
+	report.Path = path
+	report.Exposed = []string{}
+	report.Declarations = []Declaration{}
+	report.Changes = manifest.New(path, bin)
+
+	var err error
+	switch {
+	case elf.IsELF64(bin):
+		report.Format = FORMAT_ELF64
+		err = e.exposeElf64(path, out, &report)
+	case elf.IsELF32(bin):
+		report.Format = FORMAT_ELF32
+		err = e.exposeElf32(path, out, &report)
+	case coff.IsCoffX64(bin):
+		report.Format = FORMAT_COFF
+		err = e.exposeCoff(path, out, &report)
+	default:
+		msg := fmt.Sprintf("%s: unsupported object format", path)
+		err = errors.New(msg)
+	}
+	if err != nil {
+		return nil, Report{}, err
+	}
+	report.Changes.Finish(out)
+
+	// the relocations of the DWARF refer to the symbol order of the input
+	switch report.Format {
+	case FORMAT_ELF64:
+		in, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return nil, Report{}, err
+		}
+		report.Declarations = findDeclarations(in, report.Exposed)
+	case FORMAT_ELF32:
+		in, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return nil, Report{}, err
+		}
+		report.Declarations = findDeclarations(in, report.Exposed)
+	}
+
+	if e.opts.Verify {
+		err = verifyExposed(path, bin, out, report)
+		if err != nil {
+			return nil, Report{}, err
+		}
+	}
+	return out, report, nil
+}
+
+// verifyExposed runs Verify on the input and exposed ELF objects
+func verifyExposed(path string, bin []byte, out []byte, report Report) error {
+	switch report.Format {
+	case FORMAT_ELF64:
+		in, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return err
+		}
+		exposed, err := elf.NewElf64(path, out)
+		if err != nil {
+			return err
+		}
+		return Verify(in, exposed, report.Exposed)
+	case FORMAT_ELF32:
+		in, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return err
+		}
+		exposed, err := elf.NewElf32(path, out)
+		if err != nil {
+			return err
+		}
+		return Verify32(in, exposed, report.Exposed)
+	}
+	msg := fmt.Sprintf("%s: verify supports ELF objects only", path)
+	return errors.New(msg)
+}
+
+func (e *Exposer) selected(name string) bool {
+	return e.opts.Filter == nil || e.opts.Filter(name)
+}
+
+// LocalFunctions returns the names of the local functions of elfObj, the
+// symbols Expose changes when no filter is set
+func LocalFunctions(elfObj *elf.Elf64Object) []string {
+	names := []string{}
+	for _, sym := range elfObj.SymTbl {
+		if sym.St_info>>4 == elf.STB_LOCAL && sym.St_info&0x0F == elf.STT_FUNC {
+			names = append(names, elfObj.GetStrFromStrTbl(sym.St_name))
+		}
+	}
+	return names
+}
+
+// LocalFunctions32 is the ELF32 version of LocalFunctions
+func LocalFunctions32(elfObj *elf.Elf32Object) []string {
+	names := []string{}
+	for _, sym := range elfObj.SymTbl {
+		if sym.St_info>>4 == elf.STB_LOCAL && sym.St_info&0x0F == elf.STT_FUNC {
+			names = append(names, elfObj.GetStrFromStrTbl(sym.St_name))
+		}
+	}
+	return names
+}
+
+// Verify checks the structure of out, that only the exposed symbols differ from
+// in and that the relocations of out still resolve to the same symbols
+func Verify(in *elf.Elf64Object, out *elf.Elf64Object, exposed []string) error {
+	exposedMap := map[string]bool{}
+	for _, name := range exposed {
+		exposedMap[name] = true
+	}
+	errs := out.Verify()
+	errs = append(errs, elf.CompareSymbols(in, out, exposedMap)...)
+	errs = append(errs, elf.CompareRelocations(in, out)...)
+	if 0 < len(errs) {
+		return &VerifyError{Path: out.Path, Problems: errs}
+	}
+	return nil
+}
+
+// Verify32 is the ELF32 version of Verify
+func Verify32(in *elf.Elf32Object, out *elf.Elf32Object, exposed []string) error {
+	exposedMap := map[string]bool{}
+	for _, name := range exposed {
+		exposedMap[name] = true
+	}
+	errs := out.Verify()
+	errs = append(errs, elf.CompareSymbols32(in, out, exposedMap)...)
+	errs = append(errs, elf.CompareRelocations32(in, out)...)
+	if 0 < len(errs) {
+		return &VerifyError{Path: out.Path, Problems: errs}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package exposer
+
+import (
+	dwarf "sym-exposer/dwarf"
+	elf "sym-exposer/elf"
+	logger "sym-exposer/logger"
+)
+
+// Declaration is where an exposed function is declared, taken from its DW_TAG_subprogram
+type Declaration struct {
+	Symbol   string
+	File     string // as named in the line table
+	Line     uint64
+	External bool // DW_AT_external, false for the static functions Expose is for
+}
+
+// findDeclarations looks up the exposed functions of elfObj in its DWARF,
+// functions without debug information are left out
+func findDeclarations(elfObj elf.ElfObject, exposed []string) []Declaration {
+	decls := []Declaration{}
+	if !elfObj.HasSection(".debug_info") {
+		return decls
+	}
+	// the declarations only add to the report, broken DWARF does not stop the exposure
+	cuInfos, err := dwarf.ReadElfDebugInfo(elfObj)
+	if err != nil {
+		logger.DLog("%s: %s\n", elfObj.GetPath(), err)
+	}
+
+	exposedMap := map[string]bool{}
+	for _, name := range exposed {
+		exposedMap[name] = true
+	}
+	for _, f := range elfObj.GetFuncsInfos() {
+		if !exposedMap[f.Name] {
+			continue
+		}
+		_, dbgFunc, found := dwarf.FindFunc(cuInfos, f)
+		if !found {
+			continue
+		}
+		decl := Declaration{}
+		decl.Symbol = f.Name
+		decl.File = dbgFunc.DeclFile
+		decl.Line = dbgFunc.DeclLine
+		decl.External = dbgFunc.External
+		decls = append(decls, decl)
+	}
+	return decls
+}
@@ -0,0 +1,114 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const BACKUP_SUFFIX = ".orig"
+
+// WriteFileAtomic writes bin to a temporary file next to path and renames it
+// over path once the data is on disk, so readers never see a partial file.
+// modTime is applied to the new file when it is not nil.
+func WriteFileAtomic(path string, bin []byte, perm os.FileMode, modTime *time.Time) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = tmp.Write(bin); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp always uses 0600
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if modTime != nil {
+		if err = os.Chtimes(tmpPath, *modTime, *modTime); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// ReplaceFile rewrites path in place with bin, keeping its permission bits and
+// modification time. A symlink is followed, the file it points to is rewritten.
+// When backup is true the original content stays available as the rewritten
+// path + BACKUP_SUFFIX.
+func ReplaceFile(path string, bin []byte, backup bool) error {
+	// the rename would replace the link itself
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if backup {
+		err = backupFile(path, fi)
+		if err != nil {
+			return err
+		}
+	}
+
+	modTime := fi.ModTime()
+	return WriteFileAtomic(path, bin, fi.Mode().Perm(), &modTime)
+}
+
+// backupFile keeps the content of path as path + BACKUP_SUFFIX. An existing
+// backup is kept, after a previous run it holds the original and path does not.
+func backupFile(path string, fi os.FileInfo) error {
+	backupPath := path + BACKUP_SUFFIX
+	_, err := os.Lstat(backupPath)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	// a hard link keeps the original inode alive after the rename, no copy needed
+	if os.Link(path, backupPath) == nil {
+		return nil
+	}
+
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	modTime := fi.ModTime()
+	return WriteFileAtomic(backupPath, bin, fi.Mode().Perm(), &modTime)
+}
+
+func syncDir(dir string) {
+	// not supported on every platform, the rename itself is already done
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
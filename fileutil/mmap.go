@@ -0,0 +1,86 @@
+package fileutil
+
+import (
+	"io"
+	"math"
+	"os"
+)
+
+// MappedFile is the content of a file, mapped read-only into memory when
+// possible. Bytes must not be written to and is not valid after Close.
+type MappedFile struct {
+	Bytes  []byte
+	Info   os.FileInfo
+	mapped bool
+}
+
+// Map opens path and maps it with MapFile, the file itself is closed again
+func Map(path string) (*MappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return MapFile(f)
+}
+
+// MapFile maps f read-only. Files that cannot be mapped (empty files, pipes,
+// platforms without mmap) are read into memory instead.
+func MapFile(f *os.File) (*MappedFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m := MappedFile{}
+	m.Info = fi
+	if !fi.Mode().IsRegular() {
+		m.Bytes, err = io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+
+	size := fi.Size()
+	if 0 < size && size <= math.MaxInt {
+		bin, err := mmapFile(f, int(size))
+		if err == nil {
+			m.Bytes = bin
+			m.mapped = true
+			return &m, nil
+		}
+	}
+	m.Bytes, err = ReadAllAt(f, size)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Close unmaps the file
+func (m *MappedFile) Close() error {
+	bin := m.Bytes
+	m.Bytes = nil
+	if !m.mapped {
+		return nil
+	}
+	m.mapped = false
+	return munmapFile(bin)
+}
+
+// ReadAllAt reads the first size bytes of r, unlike a single Read it does not
+// stop at short reads. A negative size reads until EOF.
+func ReadAllAt(r io.ReaderAt, size int64) ([]byte, error) {
+	if size < 0 {
+		return io.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	}
+	if math.MaxInt < size {
+		return nil, io.ErrShortBuffer
+	}
+	bin := make([]byte, size)
+	_, err := io.ReadFull(io.NewSectionReader(r, 0, size), bin)
+	if err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
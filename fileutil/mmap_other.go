@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap is not used on this platform, MapFile reads the file instead
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("mmap is not supported")
+}
+
+func munmapFile(bin []byte) error {
+	return nil
+}
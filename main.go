@@ -1,163 +1,699 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	binutil "sym-exposer/binutil"
+	"path/filepath"
+	"strings"
+	bindgen "sym-exposer/bindgen"
+	coff "sym-exposer/coff"
 	elf "sym-exposer/elf"
-	"unsafe"
+	exposer "sym-exposer/exposer"
+	fileutil "sym-exposer/fileutil"
+	manifest "sym-exposer/manifest"
+	objdiff "sym-exposer/objdiff"
 )
 
+func usage() {
+	fmt.Println("Usage sym-exporser [-verify] [-manifest <changes.json>] [-strip-debug | -split-debug <file.debug>] <target.obj> <sym_exposed.obj>")
+	fmt.Println("      sym-exporser -i [-backup] [-verify] [-manifest <changes.json>] [-strip-debug | -split-debug <file.debug>] <target.obj>")
+	fmt.Println("      sym-exporser restore <changes.json> <sym_exposed.obj> <restored.obj>")
+	fmt.Println("      sym-exporser restore -i [-backup] <changes.json> <sym_exposed.obj>")
+	fmt.Println("      sym-exporser verify [-manifest <changes.json>] <target.obj> <sym_exposed.obj>")
+	fmt.Println("      sym-exporser diff [-json] [-dynamic] <old.obj> <new.obj>")
+	fmt.Println("      sym-exporser dynsyms [-json] [-all] <lib.so>")
+	fmt.Println("      sym-exporser gen-header [-lang c|c++] [-manifest <changes.json>] [-o <out.h>] <target.obj>")
+	fmt.Println("      sym-exporser gen-go [-manifest <changes.json>] [-pkg <name>] [-ldflags <flags>] [-o <out.go>] <target.obj>")
+	fmt.Println("      sym-exporser gen-rust [-manifest <changes.json>] [-link <lib>] [-o <out.rs>] <target.obj>")
+	fmt.Println("      sym-exporser sections [-add-section <name>=<file>] [-remove-section <name>] [-rename-section <old>=<new>] <target.obj> <out.obj>")
+	fmt.Println("      sym-exporser sections -i [-backup] [-add-section ...] [-remove-section ...] [-rename-section ...] <target.obj>")
+}
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage sym-exporser <target.obj> <sym_exposed.obj>")
-		os.Exit(-1)
+	if 1 < len(os.Args) {
+		switch os.Args[1] {
+		case "expose":
+			runExpose(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "sections":
+			runSections(os.Args[2:])
+			return
+		case "dynsyms":
+			runDynSyms(os.Args[2:])
+			return
+		case "gen-header":
+			runGenHeader(os.Args[2:])
+			return
+		case "gen-go":
+			runGenGo(os.Args[2:])
+			return
+		case "gen-rust":
+			runGenRust(os.Args[2:])
+			return
+		}
 	}
-	// check target module is exist
-	var filePath = os.Args[1]
-	fi, err := os.Stat(filePath)
+	runExpose(os.Args[1:])
+}
+
+func exitOnError(err error) {
 	if err != nil {
 		fmt.Println("Error: ", err)
 		os.Exit(-1)
 	}
+}
+
+func writeOutput(bin []byte, srcPath string, fi os.FileInfo, dstPath string, inPlace bool, backup bool) error {
+	if inPlace {
+		fmt.Println(srcPath)
+		return fileutil.ReplaceFile(srcPath, bin, backup)
+	}
+	fmt.Println(dstPath)
+	return fileutil.WriteFileAtomic(dstPath, bin, fi.Mode().Perm(), nil)
+}
+
+func printVerifyError(err error) {
+	var verifyErr *exposer.VerifyError
+	if errors.As(err, &verifyErr) {
+		for _, problem := range verifyErr.Problems {
+			fmt.Println("verify:", problem)
+		}
+	}
+}
+
+// printExposed lists the exposed functions with their declarations when the object has DWARF
+func printExposed(report exposer.Report) {
+	decls := map[string]exposer.Declaration{}
+	for _, decl := range report.Declarations {
+		decls[decl.Symbol] = decl
+	}
+	for _, name := range report.Exposed {
+		decl, exist := decls[name]
+		if exist {
+			fmt.Printf("exposed: %s %s:%d\n", name, decl.File, decl.Line)
+		} else {
+			fmt.Printf("exposed: %s\n", name)
+		}
+	}
+}
+
+func runExpose(args []string) {
+	flags := flag.NewFlagSet("expose", flag.ExitOnError)
+	inPlace := flags.Bool("i", false, "rewrite <target.obj> in place")
+	backup := flags.Bool("backup", false, "keep <target.obj>"+fileutil.BACKUP_SUFFIX+" when rewriting in place")
+	manifestPath := flags.String("manifest", "", "write the list of changes to this file (used by restore)")
+	verify := flags.Bool("verify", false, "check the exposed object before writing it")
+	stripDebug := flags.Bool("strip-debug", false, "remove the .debug_* sections from the exposed object (ELF only)")
+	splitDebug := flags.String("split-debug", "", "move the .debug_* sections to this file and add a .gnu_debuglink to it (ELF only)")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if (*inPlace && len(args) != 1) || (!*inPlace && len(args) != 2) {
+		flags.Usage()
+		os.Exit(-1)
+	}
+	if *backup && !*inPlace {
+		exitOnError(errors.New("-backup requires -i"))
+	}
+	if *stripDebug && *splitDebug != "" {
+		exitOnError(errors.New("-strip-debug and -split-debug cannot be used together"))
+	}
+	if (*stripDebug || *splitDebug != "") && *manifestPath != "" {
+		// restore writes back at the offsets of the unstripped object
+		exitOnError(errors.New("-manifest cannot be used with -strip-debug or -split-debug"))
+	}
 
+	var filePath = args[0]
 	f, err := os.Open(filePath)
+	exitOnError(err)
+	fi, err := f.Stat()
+	exitOnError(err)
+
+	opts := exposer.Options{}
+	opts.Verify = *verify
+	opts.Trace = os.Stdout
+	bin, report, err := exposer.New(opts).Expose(f)
+	f.Close()
+	printVerifyError(err)
+	exitOnError(err)
+	printExposed(report)
+
+	if *stripDebug {
+		bin, err = exposer.StripDebug(filePath, bin)
+		exitOnError(err)
+	}
+	if *splitDebug != "" {
+		var debugBin []byte
+		bin, debugBin, err = exposer.SplitDebug(filePath, bin, filepath.Base(*splitDebug))
+		exitOnError(err)
+		fmt.Println(*splitDebug)
+		err = fileutil.WriteFileAtomic(*splitDebug, debugBin, fi.Mode().Perm(), nil)
+		exitOnError(err)
+	}
+
+	dstPath := ""
+	if !*inPlace {
+		dstPath = args[1]
+	}
+	err = writeOutput(bin, filePath, fi, dstPath, *inPlace, *backup)
 	if err != nil {
-		fmt.Println("Error: ", err)
+		fmt.Println("Error writing to file:", err)
 		os.Exit(-1)
 	}
+
+	if *manifestPath != "" {
+		err = report.Changes.Save(*manifestPath)
+		exitOnError(err)
+	}
+}
+
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	inPlace := flags.Bool("i", false, "rewrite <sym_exposed.obj> in place")
+	backup := flags.Bool("backup", false, "keep <sym_exposed.obj>"+fileutil.BACKUP_SUFFIX+" when rewriting in place")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if (*inPlace && len(args) != 2) || (!*inPlace && len(args) != 3) {
+		flags.Usage()
+		os.Exit(-1)
+	}
+	if *backup && !*inPlace {
+		exitOnError(errors.New("-backup requires -i"))
+	}
+
+	changes, err := manifest.Load(args[0])
+	exitOnError(err)
+
+	filePath := args[1]
+	in, err := fileutil.Map(filePath)
+	exitOnError(err)
+	defer in.Close()
+
+	restored, err := changes.Restore(in.Bytes)
+	exitOnError(err)
+
+	dstPath := ""
+	if !*inPlace {
+		dstPath = args[2]
+	}
+	err = writeOutput(restored, filePath, in.Info, dstPath, *inPlace, *backup)
+	if err != nil {
+		fmt.Println("Error writing to file:", err)
+		os.Exit(-1)
+	}
+}
+
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "take the exposed symbols from this manifest")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 2 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	inFile, err := fileutil.Map(args[0])
+	exitOnError(err)
+	defer inFile.Close()
+	outFile, err := fileutil.Map(args[1])
+	exitOnError(err)
+	defer outFile.Close()
+	var exposed []string
+	if *manifestPath != "" {
+		changes, err := manifest.Load(*manifestPath)
+		exitOnError(err)
+		exposed = []string{}
+		for _, sym := range changes.Symbols {
+			exposed = append(exposed, sym.Name)
+		}
+	}
+
+	switch {
+	case elf.IsELF64(inFile.Bytes) && elf.IsELF64(outFile.Bytes):
+		var in, out *elf.Elf64Object
+		in, err = elf.NewElf64(args[0], inFile.Bytes)
+		exitOnError(err)
+		out, err = elf.NewElf64(args[1], outFile.Bytes)
+		exitOnError(err)
+		if exposed == nil {
+			exposed = exposer.LocalFunctions(in)
+		}
+		err = exposer.Verify(in, out, exposed)
+	case elf.IsELF32(inFile.Bytes) && elf.IsELF32(outFile.Bytes):
+		var in, out *elf.Elf32Object
+		in, err = elf.NewElf32(args[0], inFile.Bytes)
+		exitOnError(err)
+		out, err = elf.NewElf32(args[1], outFile.Bytes)
+		exitOnError(err)
+		if exposed == nil {
+			exposed = exposer.LocalFunctions32(in)
+		}
+		err = exposer.Verify32(in, out, exposed)
+	default:
+		err = errors.New("verify needs two ELF objects of the same class")
+	}
+	printVerifyError(err)
+	exitOnError(err)
+	fmt.Printf("%s: OK\n", args[1])
+}
+
+func loadDiffObject(path string, dynamic bool) (objdiff.Object, error) {
+	f, err := fileutil.Map(path)
+	if err != nil {
+		return objdiff.Object{}, err
+	}
+	// objdiff.Object copies what it needs
 	defer f.Close()
+	bin := f.Bytes
+	if dynamic {
+		return loadDynamicObject(path, bin)
+	}
+	if elf.IsELF64(bin) {
+		elfObj, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return objdiff.Object{}, err
+		}
+		return objdiff.FromElf64(elfObj), nil
+	}
+	if elf.IsELF32(bin) {
+		elfObj, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return objdiff.Object{}, err
+		}
+		return objdiff.FromElf32(elfObj), nil
+	}
+	if coff.IsCoffX64(bin) {
+		coffObj, err := coff.NewCoff(path, bin)
+		if err != nil {
+			return objdiff.Object{}, err
+		}
+		return objdiff.FromCoff(coffObj), nil
+	}
+	msg := fmt.Sprintf("%s: unsupported object format", path)
+	return objdiff.Object{}, errors.New(msg)
+}
+
+// loadDynamicObject takes the symbols of .dynsym with their versions
+func loadDynamicObject(path string, bin []byte) (objdiff.Object, error) {
+	if elf.IsELF64(bin) {
+		elfObj, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return objdiff.Object{}, err
+		}
+		return objdiff.FromElf64Dynamic(elfObj)
+	}
+	if elf.IsELF32(bin) {
+		elfObj, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return objdiff.Object{}, err
+		}
+		return objdiff.FromElf32Dynamic(elfObj)
+	}
+	msg := fmt.Sprintf("%s: dynamic symbols are supported for ELF objects only", path)
+	return objdiff.Object{}, errors.New(msg)
+}
 
-	bin := make([]byte, fi.Size())
-	f.Read(bin)
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the differences as JSON")
+	dynamic := flags.Bool("dynamic", false, "compare the dynamic symbols (.dynsym) of shared libraries or executables")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 2 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	oldObj, err := loadDiffObject(args[0], *dynamic)
+	exitOnError(err)
+	newObj, err := loadDiffObject(args[1], *dynamic)
+	exitOnError(err)
+	if oldObj.Format != newObj.Format {
+		msg := fmt.Sprintf("cannot compare %s object with %s object", oldObj.Format, newObj.Format)
+		exitOnError(errors.New(msg))
+	}
+
+	report := objdiff.Compare(oldObj, newObj)
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		exitOnError(enc.Encode(report))
+	} else {
+		report.WriteText(os.Stdout)
+	}
 
+	// exit status follows diff(1)
+	if report.HasDiff() {
+		os.Exit(1)
+	}
+}
+
+func runDynSyms(args []string) {
+	flags := flag.NewFlagSet("dynsyms", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the symbols as JSON")
+	all := flags.Bool("all", false, "list every dynamic symbol, not only the exported ones")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	obj, err := loadDiffObject(args[0], true)
+	exitOnError(err)
+	syms := []objdiff.Symbol{}
+	for _, sym := range obj.Symbols {
+		if *all || sym.IsExported() {
+			syms = append(syms, sym)
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		exitOnError(enc.Encode(syms))
+		return
+	}
+	for _, s := range syms {
+		fmt.Printf("%016x %8d %-7s %-6s %-9s %-12s %s\n", s.Value, s.Size, s.Type, s.Bind, s.Visibility, s.Section, s.Name)
+	}
+}
+
+// exposedFunctions returns the functions expose changes in bin, or the symbols
+// of the manifest when it is given
+func exposedFunctions(path string, bin []byte, manifestPath string) ([]string, error) {
+	if manifestPath != "" {
+		changes, err := manifest.Load(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, sym := range changes.Symbols {
+			names = append(names, sym.Name)
+		}
+		return names, nil
+	}
+	_, report, err := exposer.New(exposer.Options{}).ExposeBytes(path, bin)
+	if err != nil {
+		return nil, err
+	}
+	return report.Exposed, nil
+}
+
+// loadBindings loads the program of the exposed functions of path. The DWARF tree
+// shares memory with the returned mapping, close it after the bindings are written.
+func loadBindings(path string, manifestPath string) (*bindgen.Program, *fileutil.MappedFile, error) {
+	f, err := fileutil.Map(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	prog, err := loadProgram(path, f.Bytes, manifestPath)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return prog, f, nil
+}
+
+func loadProgram(path string, bin []byte, manifestPath string) (*bindgen.Program, error) {
+	names, err := exposedFunctions(path, bin, manifestPath)
+	if err != nil {
+		return nil, err
+	}
 	if elf.IsELF64(bin) {
-		ehdr := elf.NewElf64Ehdr(bin)
-		shdrOffset := ehdr.E_shoff
-
-		shdrs := ehdr.GetSectionHeaders(bin)
-		strSh := shdrs[ehdr.E_shstrndx]
-		secNameStr := bin[strSh.Sh_offset : strSh.Sh_offset+strSh.Sh_size]
-		sectionNameMap := make(map[string]int)
-		for i, sh := range shdrs {
-			secName := ""
-			pos := sh.Sh_name
-			for {
-				if (secNameStr[pos]) == 0 {
-					break
-				}
-				secName += string(secNameStr[pos])
-				pos++
-			}
-			fmt.Printf("section name: %s, sh_link: %d sh_info: %d\n", secName, sh.Sh_link, sh.Sh_info)
-			sectionNameMap[secName] = i
-		}
-
-		strShIdx, exist := sectionNameMap[".strtab"]
-		if !exist {
-			panic("not found .strtab section")
-		}
-		shStrSec := shdrs[strShIdx]
-		endOffset := shStrSec.Sh_offset + shStrSec.Sh_size
-		strtbl := bin[shStrSec.Sh_offset:endOffset]
-		symTabShIdx, exist := sectionNameMap[".symtab"]
-
-		shdrSize := uint64(unsafe.Sizeof(elf.Elf64_Shdr{}))
-		symTabShdrOffset := shdrOffset + uint64(symTabShIdx)*shdrSize
-		if !exist {
-			panic("not found .symtab section")
-		}
-		symTabSh := shdrs[symTabShIdx]
-		endOffset = symTabSh.Sh_offset + symTabSh.Sh_size
-		var offset uint64 = symTabSh.Sh_offset
-
-		var localSymIdx uint32 = 0
-		var lastLocalSymIdx uint32 = 0
-		for offset < endOffset {
-			elf64Sym := elf.Elf64_Sym{}
-			elf64Sym.St_name, _ = binutil.FromLeToUInt32(bin[offset:])
-			offset += uint64(unsafe.Sizeof(elf.Elf64_Word(0)))
-
-			symName := ""
-			strOffset := elf64Sym.St_name
-			for strtbl[strOffset] != 0 {
-				symName += string(strtbl[strOffset])
-				strOffset++
-			}
-
-			elf64Sym.St_info = bin[offset]
-			bind := (elf64Sym.St_info >> 4)
-			if bind == elf.STB_LOCAL {
-				localSymIdx++
-			}
-
-			// set STB_GLOBAL if symbol is STB_LOCAL
-			lastLocalSymIdx = localSymIdx
-			if elf64Sym.St_info&0x0F == elf.STT_FUNC {
-				if bind == elf.STB_LOCAL {
-					bin[offset] = (elf.STB_GLOBAL << 4) | elf.STT_FUNC&0x0F
-					lastLocalSymIdx = localSymIdx - 1
-				}
-			}
-			offset += uint64(unsafe.Sizeof(uint8(0)))
-
-			// skip st_other
-			elf64Sym.St_other = bin[offset]
-			offset += uint64(unsafe.Sizeof(uint8(0)))
-
-			// skip st_shndx
-			elf64Sym.St_shndx, _ = binutil.FromLeToUInt16(bin[offset:])
-			offset += uint64(unsafe.Sizeof(elf.Elf64_Half(0)))
-
-			// skip st_value
-			elf64Sym.St_value, _ = binutil.FromLeToUInt64(bin[offset:])
-			offset += uint64(unsafe.Sizeof(elf.Elf64_Addr(0)))
-
-			// skip st_size
-			elf64Sym.St_size, _ = binutil.FromLeToUInt64(bin[offset:])
-			offset += uint64(unsafe.Sizeof(elf.Elf64_Xword(0)))
-		}
-
-		// need to update section header info
-		var elf64Shdr = elf.Elf64_Shdr{}
-		offset = symTabShdrOffset
-
-		elf64Shdr.Sh_name, _ = binutil.FromLeToUInt32(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Word(0)))
-
-		elf64Shdr.Sh_type, _ = binutil.FromLeToUInt32(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Word(0)))
-
-		elf64Shdr.Sh_flags, _ = binutil.FromLeToUInt64(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Xword(0)))
-
-		elf64Shdr.Sh_addr, _ = binutil.FromLeToUInt64(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Addr(0)))
-
-		elf64Shdr.Sh_offset, _ = binutil.FromLeToUInt64(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Addr(0)))
-
-		elf64Shdr.Sh_size, _ = binutil.FromLeToUInt64(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Xword(0)))
-
-		elf64Shdr.Sh_link, _ = binutil.FromLeToUInt32(bin[offset:])
-		offset += uint64(unsafe.Sizeof(elf.Elf64_Word(0)))
-
-		// update sh_info, sh_info must be last local symbol index + 1
-		bytes := binutil.FromUint32ToLeBytes(lastLocalSymIdx)
-		bin[offset] = bytes[0]
-		bin[offset+1] = bytes[1]
-		bin[offset+2] = bytes[2]
-		bin[offset+3] = bytes[3]
-
-		fmt.Println(os.Args[2])
-		err := os.WriteFile(os.Args[2], bin, 0644)
+		elfObj, err := elf.NewElf64(path, bin)
 		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
+			return nil, err
+		}
+		return bindgen.Load(elfObj, names)
+	}
+	if elf.IsELF32(bin) {
+		elfObj, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return nil, err
+		}
+		return bindgen.Load(elfObj, names)
+	}
+	msg := fmt.Sprintf("%s: bindings are generated from the DWARF of ELF objects only", path)
+	return nil, errors.New(msg)
+}
+
+func runGenHeader(args []string) {
+	flags := flag.NewFlagSet("gen-header", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "declare the symbols of this manifest instead of the local functions")
+	outPath := flags.String("o", "", "write the header to this file instead of stdout")
+	lang := flags.String("lang", "", "c or c++, by default c++ when a function is in a C++ unit")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	prog, f, err := loadBindings(args[0], *manifestPath)
+	exitOnError(err)
+	defer f.Close()
+	if *lang == "" {
+		*lang = "c"
+		if prog.IsCPlusPlus() {
+			*lang = "c++"
+		}
+	}
+	var write func(w io.Writer, guard string)
+	ext := ".h"
+	switch *lang {
+	case "c":
+		write = prog.WriteCHeader
+	case "c++":
+		write, ext = prog.WriteCppHeader, ".hpp"
+	default:
+		msg := fmt.Sprintf("unknown header language %s", *lang)
+		exitOnError(errors.New(msg))
+	}
+
+	if *outPath == "" {
+		write(os.Stdout, bindgen.HeaderGuard(filepath.Base(args[0])+ext))
+		return
+	}
+	var header strings.Builder
+	write(&header, bindgen.HeaderGuard(filepath.Base(*outPath)))
+	err = fileutil.WriteFileAtomic(*outPath, []byte(header.String()), 0644, nil)
+	exitOnError(err)
+}
+
+func runGenGo(args []string) {
+	flags := flag.NewFlagSet("gen-go", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "declare the symbols of this manifest instead of the local functions")
+	outPath := flags.String("o", "", "write the Go file to this file instead of stdout")
+	pkg := flags.String("pkg", "exposed", "package of the Go file")
+	ldflags := flags.String("ldflags", "", "#cgo LDFLAGS of the Go file, like ${SRCDIR}/sym_exposed.o")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	prog, f, err := loadBindings(args[0], *manifestPath)
+	exitOnError(err)
+	defer f.Close()
+	if *outPath == "" {
+		prog.WriteGo(os.Stdout, *pkg, *ldflags)
+		return
+	}
+	var src strings.Builder
+	prog.WriteGo(&src, *pkg, *ldflags)
+	err = fileutil.WriteFileAtomic(*outPath, []byte(src.String()), 0644, nil)
+	exitOnError(err)
+}
+
+func runGenRust(args []string) {
+	flags := flag.NewFlagSet("gen-rust", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "declare the symbols of this manifest instead of the local functions")
+	outPath := flags.String("o", "", "write the Rust module to this file instead of stdout")
+	link := flags.String("link", "", "library the extern block links, like sym_exposed for libsym_exposed.a")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	prog, f, err := loadBindings(args[0], *manifestPath)
+	exitOnError(err)
+	defer f.Close()
+	if *outPath == "" {
+		prog.WriteRust(os.Stdout, *link)
+		return
+	}
+	var src strings.Builder
+	prog.WriteRust(&src, *link)
+	err = fileutil.WriteFileAtomic(*outPath, []byte(src.String()), 0644, nil)
+	exitOnError(err)
+}
+
+// stringList collects the values of a flag given several times
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(val string) error {
+	*list = append(*list, val)
+	return nil
+}
+
+// sectionEditor is implemented by elf.Elf64Writer and elf.Elf32Writer
+type sectionEditor interface {
+	AddSection(name string, data []byte) int
+	RemoveSections(names ...string) error
+	RenameSection(oldName string, newName string) error
+	Bytes() ([]byte, error)
+}
+
+func editSections(path string, bin []byte, adds []string, removes []string, renames []string) ([]byte, error) {
+	var editor sectionEditor
+	if elf.IsELF64(bin) {
+		elfObj, err := elf.NewElf64(path, bin)
+		if err != nil {
+			return nil, err
 		}
+		editor = elf.NewElf64Writer(elfObj)
+	} else if elf.IsELF32(bin) {
+		elfObj, err := elf.NewElf32(path, bin)
+		if err != nil {
+			return nil, err
+		}
+		editor = elf.NewElf32Writer(elfObj)
 	} else {
-		fmt.Printf("%s is not ELF64\n", filePath)
+		msg := fmt.Sprintf("%s: sections supports ELF objects only", path)
+		return nil, errors.New(msg)
+	}
+
+	// same order as objcopy: remove, rename, then add
+	if 0 < len(removes) {
+		err := editor.RemoveSections(removes...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, rename := range renames {
+		oldName, newName, ok := strings.Cut(rename, "=")
+		if !ok {
+			msg := fmt.Sprintf("-rename-section %s: expected <old>=<new>", rename)
+			return nil, errors.New(msg)
+		}
+		err := editor.RenameSection(oldName, newName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, add := range adds {
+		name, filePath, ok := strings.Cut(add, "=")
+		if !ok {
+			msg := fmt.Sprintf("-add-section %s: expected <name>=<file>", add)
+			return nil, errors.New(msg)
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		editor.AddSection(name, data)
+	}
+	return editor.Bytes()
+}
+
+func runSections(args []string) {
+	flags := flag.NewFlagSet("sections", flag.ExitOnError)
+	inPlace := flags.Bool("i", false, "rewrite <target.obj> in place")
+	backup := flags.Bool("backup", false, "keep <target.obj>"+fileutil.BACKUP_SUFFIX+" when rewriting in place")
+	var adds, removes, renames stringList
+	flags.Var(&adds, "add-section", "add a section `<name>=<file>` with the contents of file (repeatable)")
+	flags.Var(&removes, "remove-section", "remove every section named `name` (repeatable)")
+	flags.Var(&renames, "rename-section", "rename sections `<old>=<new>` (repeatable)")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if (*inPlace && len(args) != 1) || (!*inPlace && len(args) != 2) {
+		flags.Usage()
+		os.Exit(-1)
+	}
+	if *backup && !*inPlace {
+		exitOnError(errors.New("-backup requires -i"))
+	}
+
+	filePath := args[0]
+	in, err := fileutil.Map(filePath)
+	exitOnError(err)
+	defer in.Close()
+
+	out, err := editSections(filePath, in.Bytes, adds, removes, renames)
+	exitOnError(err)
+
+	dstPath := ""
+	if !*inPlace {
+		dstPath = args[1]
+	}
+	err = writeOutput(out, filePath, in.Info, dstPath, *inPlace, *backup)
+	if err != nil {
+		fmt.Println("Error writing to file:", err)
 		os.Exit(-1)
 	}
 }
@@ -0,0 +1,157 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	fileutil "sym-exposer/fileutil"
+)
+
+const MANIFEST_VERSION = 1
+
+// Patch is a single byte-level change, old and new are hex encoded
+type Patch struct {
+	Offset uint64 `json:"offset"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// SymbolChange records an exposed symbol, for COFF objects OldInfo and NewInfo
+// hold the StorageClass and the index does not change
+type SymbolChange struct {
+	Index    uint32 `json:"index"`
+	NewIndex uint32 `json:"new_index"`
+	Name     string `json:"name"`
+	OldInfo  uint8  `json:"old_st_info"`
+	NewInfo  uint8  `json:"new_st_info"`
+	OldOther uint8  `json:"old_st_other"`
+	NewOther uint8  `json:"new_st_other"`
+}
+
+type SectionChange struct {
+	Index   uint32 `json:"index"`
+	Name    string `json:"name"`
+	OldInfo uint32 `json:"old_sh_info"`
+	NewInfo uint32 `json:"new_sh_info"`
+}
+
+type Manifest struct {
+	Version      int             `json:"version"`
+	InputPath    string          `json:"input_path"`
+	InputSHA256  string          `json:"input_sha256"`
+	OutputSHA256 string          `json:"output_sha256"`
+	Symbols      []SymbolChange  `json:"symbols"`
+	Sections     []SectionChange `json:"sections"`
+	Patches      []Patch         `json:"patches"`
+}
+
+func New(path string, orig []byte) *Manifest {
+	m := Manifest{}
+	m.Version = MANIFEST_VERSION
+	m.InputPath = path
+	m.InputSHA256 = hashOf(orig)
+	m.Symbols = []SymbolChange{}
+	m.Sections = []SectionChange{}
+	m.Patches = []Patch{}
+	return &m
+}
+
+// Write stores val into bin at offset and records the change
+func (m *Manifest) Write(bin []byte, offset uint64, val []byte) {
+	end := offset + uint64(len(val))
+	if bytes.Equal(bin[offset:end], val) {
+		return
+	}
+	patch := Patch{}
+	patch.Offset = offset
+	patch.Old = hex.EncodeToString(bin[offset:end])
+	patch.New = hex.EncodeToString(val)
+	copy(bin[offset:end], val)
+	m.Patches = append(m.Patches, patch)
+}
+
+func (m *Manifest) AddSymbol(sym SymbolChange) {
+	m.Symbols = append(m.Symbols, sym)
+}
+
+func (m *Manifest) AddSection(sec SectionChange) {
+	m.Sections = append(m.Sections, sec)
+}
+
+// Finish must be called once all changes are written to bin
+func (m *Manifest) Finish(bin []byte) {
+	m.OutputSHA256 = hashOf(bin)
+}
+
+// Save writes m to path atomically, restore needs the whole manifest
+func (m *Manifest) Save(path string) error {
+	bin, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	bin = append(bin, '\n')
+	return fileutil.WriteFileAtomic(path, bin, 0644, nil)
+}
+
+func Load(path string) (*Manifest, error) {
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := Manifest{}
+	err = json.Unmarshal(bin, &m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != MANIFEST_VERSION {
+		msg := fmt.Sprintf("unsupported manifest version %d", m.Version)
+		return nil, errors.New(msg)
+	}
+	return &m, nil
+}
+
+// Restore applies the recorded patches in reverse order to a copy of bin and
+// checks that the result is identical to the original input.
+func (m *Manifest) Restore(bin []byte) ([]byte, error) {
+	if hashOf(bin) != m.OutputSHA256 {
+		return nil, errors.New("object does not match the manifest output (modified after exposing?)")
+	}
+
+	restored := make([]byte, len(bin))
+	copy(restored, bin)
+	for i := len(m.Patches) - 1; i >= 0; i-- {
+		patch := m.Patches[i]
+		oldVal, err := hex.DecodeString(patch.Old)
+		if err != nil {
+			return nil, err
+		}
+		newVal, err := hex.DecodeString(patch.New)
+		if err != nil {
+			return nil, err
+		}
+		end := patch.Offset + uint64(len(newVal))
+		if len(oldVal) != len(newVal) || end > uint64(len(restored)) {
+			msg := fmt.Sprintf("invalid patch at offset 0x%x", patch.Offset)
+			return nil, errors.New(msg)
+		}
+		if !bytes.Equal(restored[patch.Offset:end], newVal) {
+			msg := fmt.Sprintf("unexpected bytes at offset 0x%x", patch.Offset)
+			return nil, errors.New(msg)
+		}
+		copy(restored[patch.Offset:end], oldVal)
+	}
+
+	if hashOf(restored) != m.InputSHA256 {
+		return nil, errors.New("restored object does not match the original hash")
+	}
+	return restored, nil
+}
+
+func hashOf(bin []byte) string {
+	sum := sha256.Sum256(bin)
+	return hex.EncodeToString(sum[:])
+}
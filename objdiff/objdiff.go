@@ -0,0 +1,506 @@
+package objdiff
+
+import (
+	"fmt"
+	"io"
+	coff "sym-exposer/coff"
+	elf "sym-exposer/elf"
+)
+
+type Symbol struct {
+	Name       string `json:"name"`
+	Bind       string `json:"bind"`
+	Type       string `json:"type"`
+	Visibility string `json:"visibility,omitempty"`
+	Section    string `json:"section"`
+	Value      uint64 `json:"value"`
+	Size       uint64 `json:"size"`
+}
+
+type Reloc struct {
+	Section string `json:"section"`
+	Offset  uint64 `json:"offset"`
+	Type    uint32 `json:"type"`
+	Symbol  string `json:"symbol"`
+	Addend  int64  `json:"addend"`
+}
+
+type Section struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
+// Object is the format independent view of an object file used for comparison
+type Object struct {
+	Path     string
+	Format   string
+	Symbols  []Symbol
+	Sections []Section // in section header order, names repeat like .text of COMDAT groups
+	Relocs   []Reloc
+}
+
+type SymbolChange struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type SectionChange struct {
+	Name    string `json:"name"`
+	OldSize uint64 `json:"old_size"`
+	NewSize uint64 `json:"new_size"`
+}
+
+type Report struct {
+	OldPath         string          `json:"old"`
+	NewPath         string          `json:"new"`
+	AddedSymbols    []Symbol        `json:"added_symbols"`
+	RemovedSymbols  []Symbol        `json:"removed_symbols"`
+	ChangedSymbols  []SymbolChange  `json:"changed_symbols"`
+	AddedSections   []SectionChange `json:"added_sections"`
+	RemovedSections []SectionChange `json:"removed_sections"`
+	ChangedSections []SectionChange `json:"changed_sections"`
+	AddedRelocs     []Reloc         `json:"added_relocs"`
+	RemovedRelocs   []Reloc         `json:"removed_relocs"`
+}
+
+func FromElf64(elfObj *elf.Elf64Object) Object {
+	obj := Object{}
+	obj.Path = elfObj.Path
+	obj.Format = "ELF64"
+	obj.Sections = []Section{}
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		obj.Sections = append(obj.Sections, Section{elfObj.GetSectionName(i), sh.Sh_size})
+	}
+
+	symNames := make([]string, len(elfObj.SymTbl))
+	obj.Symbols = []Symbol{}
+	for i, sym := range elfObj.SymTbl {
+		s := Symbol{}
+		s.Name = elfObj.GetStrFromStrTbl(sym.St_name)
+		s.Bind = elf.GetSymBindName(sym.St_info)
+		s.Type = elf.GetSymTypeName(sym.St_info)
+		s.Visibility = elf.GetSymVisibilityName(sym.St_other)
+		s.Value = sym.St_value
+		s.Size = sym.St_size
+		switch sym.St_shndx {
+		case elf.SHN_UNDEF:
+			s.Section = "UND"
+		case elf.SHN_ABS:
+			s.Section = "ABS"
+		case elf.SHN_COMMON:
+			s.Section = "COM"
+		default:
+			shndx := elfObj.GetSymShndx(i)
+			if elfObj.IsSymInSection(i) && int(shndx) < len(elfObj.Shdrs) {
+				s.Section = elfObj.GetSectionName(int(shndx))
+			}
+		}
+		// section symbols have no name, show them by their section like readelf
+		if s.Name == "" && sym.St_info&0x0F == elf.STT_SECTION {
+			s.Name = s.Section
+		}
+		symNames[i] = s.Name
+		if i == 0 {
+			continue
+		}
+		obj.Symbols = append(obj.Symbols, s)
+	}
+
+	obj.Relocs = []Reloc{}
+	for symTabIdx, sh := range elfObj.Shdrs {
+		if sh.Sh_type != elf.SHT_SYMTAB {
+			continue
+		}
+		for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+			secName := elfObj.GetSectionName(relIdx)
+			for _, rela := range elfObj.GetRelocations(relIdx) {
+				r := Reloc{}
+				r.Section = secName
+				r.Offset = rela.R_offset
+				r.Type = elf.ELF64_R_TYPE(rela.R_info)
+				symIdx := elf.ELF64_R_SYM(rela.R_info)
+				if int(symIdx) < len(symNames) {
+					r.Symbol = symNames[symIdx]
+				}
+				r.Addend = rela.R_addend
+				obj.Relocs = append(obj.Relocs, r)
+			}
+		}
+	}
+	return obj
+}
+
+// FromElf32 is the ELF32 version of FromElf64
+func FromElf32(elfObj *elf.Elf32Object) Object {
+	obj := Object{}
+	obj.Path = elfObj.Path
+	obj.Format = "ELF32"
+	obj.Sections = []Section{}
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		obj.Sections = append(obj.Sections, Section{elfObj.GetSectionName(i), uint64(sh.Sh_size)})
+	}
+
+	symNames := make([]string, len(elfObj.SymTbl))
+	obj.Symbols = []Symbol{}
+	for i, sym := range elfObj.SymTbl {
+		s := Symbol{}
+		s.Name = elfObj.GetStrFromStrTbl(elf.Elf64_Word(sym.St_name))
+		s.Bind = elf.GetSymBindName(sym.St_info)
+		s.Type = elf.GetSymTypeName(sym.St_info)
+		s.Visibility = elf.GetSymVisibilityName(sym.St_other)
+		s.Value = uint64(sym.St_value)
+		s.Size = uint64(sym.St_size)
+		switch sym.St_shndx {
+		case elf.SHN_UNDEF:
+			s.Section = "UND"
+		case elf.SHN_ABS:
+			s.Section = "ABS"
+		case elf.SHN_COMMON:
+			s.Section = "COM"
+		default:
+			shndx := elfObj.GetSymShndx(i)
+			if elfObj.IsSymInSection(i) && int(shndx) < len(elfObj.Shdrs) {
+				s.Section = elfObj.GetSectionName(int(shndx))
+			}
+		}
+		// section symbols have no name, show them by their section like readelf
+		if s.Name == "" && sym.St_info&0x0F == elf.STT_SECTION {
+			s.Name = s.Section
+		}
+		symNames[i] = s.Name
+		if i == 0 {
+			continue
+		}
+		obj.Symbols = append(obj.Symbols, s)
+	}
+
+	obj.Relocs = []Reloc{}
+	for symTabIdx, sh := range elfObj.Shdrs {
+		if sh.Sh_type != elf.SHT_SYMTAB {
+			continue
+		}
+		for _, relIdx := range elfObj.GetRelocSectionIdxs(symTabIdx) {
+			secName := elfObj.GetSectionName(relIdx)
+			for _, rela := range elfObj.GetRelocations(relIdx) {
+				r := Reloc{}
+				r.Section = secName
+				r.Offset = uint64(rela.R_offset)
+				r.Type = elf.ELF32_R_TYPE(rela.R_info)
+				symIdx := elf.ELF32_R_SYM(rela.R_info)
+				if int(symIdx) < len(symNames) {
+					r.Symbol = symNames[symIdx]
+				}
+				r.Addend = int64(rela.R_addend)
+				obj.Relocs = append(obj.Relocs, r)
+			}
+		}
+	}
+	return obj
+}
+
+// dynamicSymbols converts a dynamic symbol table, symbols are named with their version like nm -D
+func dynamicSymbols(tab *elf.DynSymTable, sectionName func(shndx int) string) []Symbol {
+	syms := []Symbol{}
+	for i, sym := range tab.Symbols {
+		if i == 0 {
+			continue
+		}
+		s := Symbol{}
+		s.Name = sym.VersionedName()
+		s.Bind = elf.GetSymBindName(sym.Info)
+		s.Type = elf.GetSymTypeName(sym.Info)
+		s.Visibility = elf.GetSymVisibilityName(sym.Other)
+		s.Value = sym.Value
+		s.Size = sym.Size
+		switch sym.Shndx {
+		case elf.SHN_UNDEF:
+			s.Section = "UND"
+		case elf.SHN_ABS:
+			s.Section = "ABS"
+		case elf.SHN_COMMON:
+			s.Section = "COM"
+		default:
+			s.Section = sectionName(int(sym.Shndx))
+		}
+		syms = append(syms, s)
+	}
+	return syms
+}
+
+// FromElf64Dynamic is the view of a shared library or executable through .dynsym instead of .symtab
+func FromElf64Dynamic(elfObj *elf.Elf64Object) (Object, error) {
+	tab, err := elfObj.ReadDynSymbols()
+	if err != nil {
+		return Object{}, err
+	}
+	obj := Object{}
+	obj.Path = elfObj.Path
+	obj.Format = "ELF64"
+	obj.Sections = []Section{}
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		obj.Sections = append(obj.Sections, Section{elfObj.GetSectionName(i), sh.Sh_size})
+	}
+	obj.Symbols = dynamicSymbols(tab, func(shndx int) string {
+		if len(elfObj.Shdrs) <= shndx {
+			return ""
+		}
+		return elfObj.GetSectionName(shndx)
+	})
+	obj.Relocs = []Reloc{}
+	return obj, nil
+}
+
+// FromElf32Dynamic is the ELF32 version of FromElf64Dynamic
+func FromElf32Dynamic(elfObj *elf.Elf32Object) (Object, error) {
+	tab, err := elfObj.ReadDynSymbols()
+	if err != nil {
+		return Object{}, err
+	}
+	obj := Object{}
+	obj.Path = elfObj.Path
+	obj.Format = "ELF32"
+	obj.Sections = []Section{}
+	for i, sh := range elfObj.Shdrs {
+		if i == 0 {
+			continue
+		}
+		obj.Sections = append(obj.Sections, Section{elfObj.GetSectionName(i), uint64(sh.Sh_size)})
+	}
+	obj.Symbols = dynamicSymbols(tab, func(shndx int) string {
+		if len(elfObj.Shdrs) <= shndx {
+			return ""
+		}
+		return elfObj.GetSectionName(shndx)
+	})
+	obj.Relocs = []Reloc{}
+	return obj, nil
+}
+
+func FromCoff(coffObj *coff.CoffObject) Object {
+	obj := Object{}
+	obj.Path = coffObj.Path
+	obj.Format = "COFF"
+	obj.Sections = []Section{}
+	for _, secHdr := range coffObj.SecHdrs {
+		obj.Sections = append(obj.Sections, Section{secHdr.Name, uint64(secHdr.SizeOfRawData)})
+	}
+
+	obj.Symbols = []Symbol{}
+	for _, sym := range coffObj.Symbols {
+		s := Symbol{}
+		s.Name = sym.Name
+		switch sym.StorageClass {
+		case coff.IMAGE_SYM_CLASS_EXTERNAL:
+			s.Bind = "GLOBAL"
+		case coff.IMAGE_SYM_CLASS_WEAK_EXTERNAL:
+			s.Bind = "WEAK"
+		default:
+			s.Bind = "LOCAL"
+		}
+		s.Type = "NOTYPE"
+		if sym.IsFunction() {
+			s.Type = "FUNC"
+		}
+		switch sym.SectionNumber {
+		case coff.IMAGE_SYM_UNDEFINED:
+			s.Section = "UND"
+		case coff.IMAGE_SYM_ABSOLUTE:
+			s.Section = "ABS"
+		case coff.IMAGE_SYM_DEBUG:
+			s.Section = "DEBUG"
+		default:
+			if 0 < sym.SectionNumber && int(sym.SectionNumber) <= len(coffObj.SecHdrs) {
+				s.Section = coffObj.SecHdrs[sym.SectionNumber-1].Name
+			}
+		}
+		s.Value = uint64(sym.Value)
+		obj.Symbols = append(obj.Symbols, s)
+	}
+
+	obj.Relocs = []Reloc{}
+	for i, secHdr := range coffObj.SecHdrs {
+		for _, reloc := range coffObj.GetRelocations(i) {
+			r := Reloc{}
+			r.Section = secHdr.Name
+			r.Offset = uint64(reloc.VirtualAddress)
+			r.Type = uint32(reloc.Type)
+			sym := coffObj.GetSymbolByIndex(reloc.SymbolTableIndex)
+			if sym != nil {
+				r.Symbol = sym.Name
+			}
+			obj.Relocs = append(obj.Relocs, r)
+		}
+	}
+	return obj
+}
+
+// IsExported reports whether other objects can bind to the symbol
+func (sym Symbol) IsExported() bool {
+	if sym.Section == "UND" || (sym.Bind != "GLOBAL" && sym.Bind != "WEAK") {
+		return false
+	}
+	return sym.Visibility != "HIDDEN" && sym.Visibility != "INTERNAL"
+}
+
+// symbols with the same name (e.g. statics of different scopes) are paired in order
+func symbolKeys(syms []Symbol) ([]string, map[string]Symbol) {
+	keys := []string{}
+	keyMap := map[string]Symbol{}
+	count := map[string]int{}
+	for _, sym := range syms {
+		key := sym.Name
+		if 0 < count[sym.Name] {
+			key = fmt.Sprintf("%s#%d", sym.Name, count[sym.Name]+1)
+		}
+		count[sym.Name]++
+		keys = append(keys, key)
+		keyMap[key] = sym
+	}
+	return keys, keyMap
+}
+
+// sections with the same name (e.g. .text of COMDAT groups) are paired in order like symbols
+func sectionKeys(secs []Section) ([]string, map[string]Section) {
+	keys := []string{}
+	keyMap := map[string]Section{}
+	count := map[string]int{}
+	for _, sec := range secs {
+		key := sec.Name
+		if 0 < count[sec.Name] {
+			key = fmt.Sprintf("%s#%d", sec.Name, count[sec.Name]+1)
+		}
+		count[sec.Name]++
+		keys = append(keys, key)
+		keyMap[key] = sec
+	}
+	return keys, keyMap
+}
+
+func Compare(oldObj Object, newObj Object) Report {
+	report := Report{}
+	report.OldPath = oldObj.Path
+	report.NewPath = newObj.Path
+	report.AddedSymbols = []Symbol{}
+	report.RemovedSymbols = []Symbol{}
+	report.ChangedSymbols = []SymbolChange{}
+	report.AddedSections = []SectionChange{}
+	report.RemovedSections = []SectionChange{}
+	report.ChangedSections = []SectionChange{}
+	report.AddedRelocs = []Reloc{}
+	report.RemovedRelocs = []Reloc{}
+
+	// symbols
+	oldKeys, oldSyms := symbolKeys(oldObj.Symbols)
+	newKeys, newSyms := symbolKeys(newObj.Symbols)
+	for _, key := range oldKeys {
+		oldSym := oldSyms[key]
+		newSym, exist := newSyms[key]
+		if !exist {
+			report.RemovedSymbols = append(report.RemovedSymbols, oldSym)
+			continue
+		}
+		fields := [][3]string{
+			{"bind", oldSym.Bind, newSym.Bind},
+			{"type", oldSym.Type, newSym.Type},
+			{"visibility", oldSym.Visibility, newSym.Visibility},
+			{"section", oldSym.Section, newSym.Section},
+			{"value", fmt.Sprintf("0x%x", oldSym.Value), fmt.Sprintf("0x%x", newSym.Value)},
+			{"size", fmt.Sprintf("%d", oldSym.Size), fmt.Sprintf("%d", newSym.Size)},
+		}
+		for _, field := range fields {
+			if field[1] != field[2] {
+				change := SymbolChange{Name: oldSym.Name, Field: field[0], Old: field[1], New: field[2]}
+				report.ChangedSymbols = append(report.ChangedSymbols, change)
+			}
+		}
+	}
+	for _, key := range newKeys {
+		if _, exist := oldSyms[key]; !exist {
+			report.AddedSymbols = append(report.AddedSymbols, newSyms[key])
+		}
+	}
+
+	// sections
+	oldSecKeys, oldSecs := sectionKeys(oldObj.Sections)
+	newSecKeys, newSecs := sectionKeys(newObj.Sections)
+	for _, key := range oldSecKeys {
+		oldSize := oldSecs[key].Size
+		newSec, exist := newSecs[key]
+		if !exist {
+			report.RemovedSections = append(report.RemovedSections, SectionChange{key, oldSize, 0})
+		} else if oldSize != newSec.Size {
+			report.ChangedSections = append(report.ChangedSections, SectionChange{key, oldSize, newSec.Size})
+		}
+	}
+	for _, key := range newSecKeys {
+		if _, exist := oldSecs[key]; !exist {
+			report.AddedSections = append(report.AddedSections, SectionChange{key, 0, newSecs[key].Size})
+		}
+	}
+
+	// relocations are compared by what they refer to, not by symbol index
+	oldRelocs := map[Reloc]int{}
+	for _, r := range oldObj.Relocs {
+		oldRelocs[r]++
+	}
+	for _, r := range newObj.Relocs {
+		if 0 < oldRelocs[r] {
+			oldRelocs[r]--
+		} else {
+			report.AddedRelocs = append(report.AddedRelocs, r)
+		}
+	}
+	for _, r := range oldObj.Relocs {
+		if 0 < oldRelocs[r] {
+			oldRelocs[r]--
+			report.RemovedRelocs = append(report.RemovedRelocs, r)
+		}
+	}
+	return report
+}
+
+func (report *Report) HasDiff() bool {
+	return 0 < len(report.AddedSymbols)+len(report.RemovedSymbols)+len(report.ChangedSymbols)+
+		len(report.AddedSections)+len(report.RemovedSections)+len(report.ChangedSections)+
+		len(report.AddedRelocs)+len(report.RemovedRelocs)
+}
+
+func (report *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "--- %s\n", report.OldPath)
+	fmt.Fprintf(w, "+++ %s\n", report.NewPath)
+	for _, s := range report.RemovedSymbols {
+		fmt.Fprintf(w, "- symbol %s (%s %s %s)\n", s.Name, s.Bind, s.Type, s.Section)
+	}
+	for _, s := range report.AddedSymbols {
+		fmt.Fprintf(w, "+ symbol %s (%s %s %s)\n", s.Name, s.Bind, s.Type, s.Section)
+	}
+	for _, c := range report.ChangedSymbols {
+		fmt.Fprintf(w, "~ symbol %s %s: %s -> %s\n", c.Name, c.Field, c.Old, c.New)
+	}
+	for _, c := range report.RemovedSections {
+		fmt.Fprintf(w, "- section %s (%d bytes)\n", c.Name, c.OldSize)
+	}
+	for _, c := range report.AddedSections {
+		fmt.Fprintf(w, "+ section %s (%d bytes)\n", c.Name, c.NewSize)
+	}
+	for _, c := range report.ChangedSections {
+		fmt.Fprintf(w, "~ section %s size: %d -> %d\n", c.Name, c.OldSize, c.NewSize)
+	}
+	for _, r := range report.RemovedRelocs {
+		fmt.Fprintf(w, "- reloc %s+0x%x type %d %s%+d\n", r.Section, r.Offset, r.Type, r.Symbol, r.Addend)
+	}
+	for _, r := range report.AddedRelocs {
+		fmt.Fprintf(w, "+ reloc %s+0x%x type %d %s%+d\n", r.Section, r.Offset, r.Type, r.Symbol, r.Addend)
+	}
+}
@@ -0,0 +1,23 @@
+struct point {
+	int x;
+	int y;
+};
+
+static int counter;
+
+static int add(int a, int b)
+{
+	return a + b + counter;
+}
+
+static int dist(const struct point *p)
+{
+	return p->x * p->x + p->y * p->y;
+}
+
+int pub(int x)
+{
+	struct point p = {x, 1};
+	counter++;
+	return add(x, 2) + dist(&p);
+}
@@ -0,0 +1,32 @@
+namespace outer {
+namespace inner {
+
+struct vec {
+	double x;
+	double y;
+};
+
+struct scale {
+	vec factor;
+	int steps;
+};
+
+}
+}
+
+struct pair {
+	int first;
+	int second;
+};
+
+static double apply(const outer::inner::scale *s, pair *p)
+{
+	p->first += s->steps;
+	return s->factor.x * p->first + s->factor.y * p->second;
+}
+
+double run(const outer::inner::scale *s)
+{
+	pair p = {1, 2};
+	return apply(s, &p);
+}
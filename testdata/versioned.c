@@ -0,0 +1,24 @@
+// gcc -shared -nostdlib -fPIC -Wl,--version-script=versioned.map -Wl,-z,noseparate-code -o versioned_x86_64.so versioned.c
+
+int foo_v1(void)
+{
+	return 1;
+}
+
+int foo_v2(void)
+{
+	return 2;
+}
+
+__asm__(".symver foo_v1,foo@VERS_1");
+__asm__(".symver foo_v2,foo@@VERS_2");
+
+static int helper(void)
+{
+	return 3;
+}
+
+int bar(void)
+{
+	return helper();
+}